@@ -2,7 +2,7 @@ import { useState, useEffect } from 'react'
 import Header from './components/Header'
 import ArticleList from './components/ArticleList'
 import ArticleModal from './components/ArticleModal'
-import { fetchArticles } from './api'
+import { fetchArticles, streamEvents } from './api'
 
 const WINDOWS = ['24h', '3days', '7days']
 
@@ -20,6 +20,7 @@ export default function App() {
   const [loading, setLoading] = useState(true)
   const [error, setError] = useState(null)
   const [selectedId, setSelectedId] = useState(null)
+  const [refresh, setRefresh] = useState(0)
 
   useEffect(() => {
     setLoading(true)
@@ -33,6 +34,21 @@ export default function App() {
       .finally(() => setLoading(false))
   }, [timeWindow])
 
+  // Reload quietly when the pipeline finishes, instead of polling.
+  useEffect(() => {
+    return streamEvents(['pipeline.finished'], () => setRefresh(n => n + 1))
+  }, [])
+
+  useEffect(() => {
+    if (refresh === 0) return
+    fetchArticles(timeWindow)
+      .then(data => {
+        setArticles(data.articles ?? [])
+        setCount(data.count ?? 0)
+      })
+      .catch(() => {})
+  }, [refresh])
+
   function handleWindowChange(win) {
     setTimeWindow(win)
     history.pushState({}, '', `?window=${win}`)
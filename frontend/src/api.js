@@ -12,6 +12,21 @@ export async function fetchArticle(id) {
   return res.json()
 }
 
+// articleLink returns a link to the article that records the click first.
+export function articleLink(id) {
+  return `${BASE}/articles/${id}/open`
+}
+
+export async function sendFeedback(id, action) {
+  const res = await fetch(`${BASE}/articles/${id}/feedback`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ action }),
+  })
+  if (!res.ok) throw new Error(`HTTP ${res.status}`)
+  return res.json()
+}
+
 export async function subscribe(email) {
   const res = await fetch(`${BASE}/subscribe`, {
     method: 'POST',
@@ -24,3 +39,14 @@ export async function subscribe(email) {
   }
   return res.json()
 }
+
+// streamEvents opens the server-sent event stream and calls onEvent(type, data)
+// for each event. EventSource reconnects and resumes by itself. Returns a
+// function that closes the stream.
+export function streamEvents(types, onEvent) {
+  const source = new EventSource(`${BASE}/stream?types=${types.join(',')}`)
+  for (const type of types) {
+    source.addEventListener(type, e => onEvent(type, JSON.parse(e.data)))
+  }
+  return () => source.close()
+}
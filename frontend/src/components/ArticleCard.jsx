@@ -1,3 +1,5 @@
+import { articleLink } from '../api'
+
 function scoreClass(score) {
   return score >= 24 ? 'high' : score >= 15 ? 'medium' : 'low'
 }
@@ -39,7 +41,7 @@ export default function ArticleCard({ article, rank, onSelect }) {
       <div className="card-body">
         <h2 className="title">
           <a
-            href={article.url}
+            href={articleLink(article.id)}
             target="_blank"
             rel="noopener noreferrer"
             onClick={e => e.stopPropagation()}
@@ -67,7 +69,7 @@ export default function ArticleCard({ article, rank, onSelect }) {
           </>
         )}
         <a
-          href={article.url}
+          href={articleLink(article.id)}
           target="_blank"
           rel="noopener noreferrer"
           className="read-link"
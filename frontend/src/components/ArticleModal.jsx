@@ -1,5 +1,5 @@
 import { useState, useEffect, useCallback } from 'react'
-import { fetchArticle } from '../api'
+import { fetchArticle, articleLink, sendFeedback } from '../api'
 
 function scoreClass(score) {
   return score >= 24 ? 'high' : score >= 15 ? 'medium' : 'low'
@@ -23,11 +23,13 @@ export default function ArticleModal({ id, onClose }) {
   const [article, setArticle] = useState(null)
   const [loading, setLoading] = useState(true)
   const [error, setError] = useState(null)
+  const [vote, setVote] = useState(null)
 
   useEffect(() => {
     setLoading(true)
     setError(null)
     setArticle(null)
+    setVote(null)
     fetchArticle(id)
       .then(data => setArticle(data.article))
       .catch(err => setError(err.message))
@@ -43,6 +45,12 @@ export default function ArticleModal({ id, onClose }) {
     return () => document.removeEventListener('keydown', handleKeyDown)
   }, [handleKeyDown])
 
+  const handleVote = action => {
+    sendFeedback(id, action)
+      .then(() => setVote(action))
+      .catch(() => {})
+  }
+
   const cls = article ? scoreClass(article.total_score) : ''
   const keywords = article?.keywords
     ? article.keywords.split(',').map(k => k.trim()).filter(Boolean)
@@ -68,7 +76,7 @@ export default function ArticleModal({ id, onClose }) {
               </div>
 
               <h2 className="modal-title">
-                <a href={article.url} target="_blank" rel="noopener noreferrer">
+                <a href={articleLink(article.id)} target="_blank" rel="noopener noreferrer">
                   {article.title} ↗
                 </a>
               </h2>
@@ -119,6 +127,18 @@ export default function ArticleModal({ id, onClose }) {
                 {article.published_at && (
                   <span>发布: {formatDate(article.published_at)}</span>
                 )}
+                <span className="vote">
+                  <button
+                    className={vote === 'up' ? 'active' : ''}
+                    onClick={() => handleVote('up')}
+                    aria-label="有用"
+                  >👍</button>
+                  <button
+                    className={vote === 'down' ? 'active' : ''}
+                    onClick={() => handleVote('down')}
+                    aria-label="没用"
+                  >👎</button>
+                </span>
               </div>
             </>
           )}
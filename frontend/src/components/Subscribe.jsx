@@ -13,7 +13,7 @@ export default function Subscribe() {
     try {
       await subscribe(email)
       setStatus('ok')
-      setMessage('订阅成功！后续资讯将发送到您的邮箱。')
+      setMessage('确认邮件已发送，请点击邮件中的链接完成订阅。')
       setEmail('')
     } catch (err) {
       setStatus('error')
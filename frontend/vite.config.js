@@ -7,6 +7,9 @@ export default defineConfig({
     proxy: {
       '/api': 'http://localhost:8080',
       '/health': 'http://localhost:8080',
+      '/feed.xml': 'http://localhost:8080',
+      '/atom.xml': 'http://localhost:8080',
+      '/feed.json': 'http://localhost:8080',
     },
   },
 })
@@ -3,12 +3,26 @@ package ai
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"regexp"
 	"strings"
 	"time"
+
+	"github.com/chyiyaqing/newsbot/internal/logging"
+	"github.com/chyiyaqing/newsbot/internal/metrics"
+	"github.com/chyiyaqing/newsbot/internal/store"
+)
+
+// provider and temperature are part of every cache key.
+const (
+	provider    = "ollama"
+	temperature = 0.3
 )
 
 type Client struct {
@@ -17,8 +31,25 @@ type Client struct {
 	username   string
 	password   string
 	httpClient *http.Client
+
+	cache    *store.Store
+	cacheTTL time.Duration
+	refresh  bool
+
+	usage       *store.Store
+	dailyBudget int64
+
+	prompts *Prompts
 }
 
+// ErrBudgetExceeded is returned instead of calling the model once the daily
+// token budget is used up.
+var ErrBudgetExceeded = errors.New("daily LLM token budget exceeded")
+
+// ErrInvalidJSON is wrapped by errors for model responses that are not the
+// JSON the prompt asked for.
+var ErrInvalidJSON = errors.New("invalid JSON response")
+
 func NewClient(baseURL, model, username, password string) *Client {
 	return &Client{
 		baseURL:    strings.TrimRight(baseURL, "/"),
@@ -26,7 +57,47 @@ func NewClient(baseURL, model, username, password string) *Client {
 		username:   username,
 		password:   password,
 		httpClient: &http.Client{Timeout: 120 * time.Second},
+		prompts:    DefaultPrompts(),
+	}
+}
+
+// WithPrompts makes c use p instead of the default prompts.
+func (c *Client) WithPrompts(p *Prompts) *Client {
+	c.prompts = p
+	return c
+}
+
+// Model is the name of the model c calls.
+func (c *Client) Model() string {
+	return c.model
+}
+
+// PromptVersion identifies the prompts c scores and summarizes with.
+func (c *Client) PromptVersion() string {
+	return c.prompts.Version
+}
+
+// WithCache makes c answer repeated prompts from the llm_cache table and
+// store new responses there for ttl. A ttl of zero leaves caching off.
+func (c *Client) WithCache(db *store.Store, ttl time.Duration) *Client {
+	if ttl > 0 {
+		c.cache, c.cacheTTL = db, ttl
 	}
+	return c
+}
+
+// WithUsage makes c record the token usage of every model call in db. With a
+// positive dailyBudget, calls fail with ErrBudgetExceeded once that many
+// tokens have been used since midnight UTC; cached responses are still served.
+func (c *Client) WithUsage(db *store.Store, dailyBudget int64) *Client {
+	c.usage, c.dailyBudget = db, dailyBudget
+	return c
+}
+
+// Refresh makes c ignore cached responses while still caching new ones.
+func (c *Client) Refresh() *Client {
+	c.refresh = true
+	return c
 }
 
 type chatRequest struct {
@@ -46,28 +117,139 @@ type chatResponse struct {
 			Content string `json:"content"`
 		} `json:"message"`
 	} `json:"choices"`
+	Usage tokenUsage `json:"usage"`
+}
+
+type tokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
 }
 
+// LLM operations, used to label metrics.
+const (
+	OpScore     = "score"
+	OpSummarize = "summarize"
+	OpTrends    = "trends"
+	OpTranslate = "translate"
+	OpChat      = "chat"
+)
+
 // ChatCompletion sends a prompt to the Ollama OpenAI-compatible endpoint
 // and returns the assistant's response text.
 func (c *Client) ChatCompletion(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	return c.chat(ctx, OpChat, 0, systemPrompt, userPrompt)
+}
+
+// chat is ChatCompletion recording metrics and usage under operation op and
+// articleID (0 if none). Responses are served from and saved to the cache
+// when one is configured.
+func (c *Client) chat(ctx context.Context, op string, articleID int64, systemPrompt, userPrompt string) (string, error) {
+	key := c.cacheKey(systemPrompt, userPrompt)
+	if c.cache != nil && !c.refresh {
+		resp, ok, err := c.cache.CachedLLMResponse(key)
+		if err != nil {
+			slog.WarnContext(ctx, "read llm cache", "operation", op, "err", err)
+		} else if ok {
+			metrics.LLMCacheLookups.Inc(op, "hit")
+			return resp, nil
+		}
+		metrics.LLMCacheLookups.Inc(op, "miss")
+	}
+
+	if err := c.checkBudget(); err != nil {
+		return "", err
+	}
+
+	start := time.Now()
+	resp, usage, err := c.chatCompletion(ctx, op, systemPrompt, userPrompt)
+	metrics.LLMDuration.Observe(time.Since(start).Seconds(), op)
+	metrics.LLMRequests.Inc(op, metrics.Result(err))
+
+	if c.usage != nil && (usage.PromptTokens > 0 || usage.CompletionTokens > 0) {
+		if err := c.usage.SaveLLMUsage(store.LLMUsage{
+			RunID:            logging.RunID(ctx),
+			Operation:        op,
+			Model:            c.model,
+			ArticleID:        articleID,
+			PromptTokens:     usage.PromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+		}); err != nil {
+			slog.WarnContext(ctx, "record llm usage", "operation", op, "err", err)
+		}
+	}
+
+	if err == nil && c.cache != nil {
+		now := time.Now()
+		if err := c.cache.SaveLLMResponse(store.LLMCacheEntry{
+			Key:       key,
+			Model:     c.model,
+			Operation: op,
+			Response:  resp,
+			CreatedAt: now,
+			ExpiresAt: now.Add(c.cacheTTL),
+		}); err != nil {
+			slog.WarnContext(ctx, "write llm cache", "operation", op, "err", err)
+		}
+	}
+	return resp, err
+}
+
+// checkBudget returns ErrBudgetExceeded if today's usage reached the budget.
+func (c *Client) checkBudget() error {
+	if c.usage == nil || c.dailyBudget <= 0 {
+		return nil
+	}
+	used, err := c.usage.LLMTokensSince(time.Now().UTC().Truncate(24 * time.Hour))
+	if err != nil {
+		return fmt.Errorf("check token budget: %w", err)
+	}
+	if used >= c.dailyBudget {
+		return fmt.Errorf("%w (%d of %d tokens used today)", ErrBudgetExceeded, used, c.dailyBudget)
+	}
+	return nil
+}
+
+// uncache drops a cached response that turned out to be unusable, so that a
+// retry asks the model again.
+func (c *Client) uncache(ctx context.Context, systemPrompt, userPrompt string) {
+	if c.cache == nil {
+		return
+	}
+	if err := c.cache.DeleteLLMResponse(c.cacheKey(systemPrompt, userPrompt)); err != nil {
+		slog.WarnContext(ctx, "delete llm cache entry", "err", err)
+	}
+}
+
+// cacheKey hashes everything that determines the model's answer.
+func (c *Client) cacheKey(systemPrompt, userPrompt string) string {
+	h := sha256.New()
+	for _, part := range []string{provider, c.model, systemPrompt, userPrompt, fmt.Sprint(temperature)} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// chatCompletion sends one request and returns the cleaned response text
+// together with the token usage the server reported.
+func (c *Client) chatCompletion(ctx context.Context, op, systemPrompt, userPrompt string) (string, tokenUsage, error) {
 	req := chatRequest{
 		Model: c.model,
 		Messages: []chatMessage{
 			{Role: "system", Content: systemPrompt},
 			{Role: "user", Content: userPrompt},
 		},
-		Temperature: 0.3,
+		Temperature: temperature,
 	}
 
 	body, err := json.Marshal(req)
 	if err != nil {
-		return "", fmt.Errorf("marshal request: %w", err)
+		return "", tokenUsage{}, fmt.Errorf("marshal request: %w", err)
 	}
 
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/v1/chat/completions", bytes.NewReader(body))
 	if err != nil {
-		return "", fmt.Errorf("create request: %w", err)
+		return "", tokenUsage{}, fmt.Errorf("create request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
 	if c.username != "" {
@@ -76,28 +258,31 @@ func (c *Client) ChatCompletion(ctx context.Context, systemPrompt, userPrompt st
 
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
-		return "", fmt.Errorf("send request: %w", err)
+		return "", tokenUsage{}, fmt.Errorf("send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		var buf bytes.Buffer
 		buf.ReadFrom(resp.Body)
-		return "", fmt.Errorf("ollama returned %d: %s", resp.StatusCode, buf.String())
+		return "", tokenUsage{}, fmt.Errorf("ollama returned %d: %s", resp.StatusCode, buf.String())
 	}
 
 	var chatResp chatResponse
 	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
-		return "", fmt.Errorf("decode response: %w", err)
+		return "", tokenUsage{}, fmt.Errorf("decode response: %w", err)
 	}
 
+	metrics.LLMTokens.Add(float64(chatResp.Usage.PromptTokens), op, "prompt")
+	metrics.LLMTokens.Add(float64(chatResp.Usage.CompletionTokens), op, "completion")
+
 	if len(chatResp.Choices) == 0 {
-		return "", fmt.Errorf("no choices in response")
+		return "", chatResp.Usage, fmt.Errorf("no choices in response")
 	}
 
 	cleaned := stripCodeFence(chatResp.Choices[0].Message.Content)
 	cleaned = sanitizeJSON(cleaned)
-	return cleaned, nil
+	return cleaned, chatResp.Usage, nil
 }
 
 var codeFenceRe = regexp.MustCompile("(?s)^```(?:json)?\\s*\n?(.*?)\\s*```$")
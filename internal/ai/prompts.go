@@ -0,0 +1,112 @@
+package ai
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/chyiyaqing/newsbot/internal/config"
+	"github.com/chyiyaqing/newsbot/internal/interest"
+)
+
+//go:embed prompts/*.tmpl
+var embeddedPrompts embed.FS
+
+// DefaultAudience and DefaultCategories fill the prompt templates when the
+// deployment does not configure its own.
+var (
+	DefaultAudience   = "software engineers and tech professionals"
+	DefaultCategories = []string{
+		"AI/ML", "Systems", "Web", "Security", "DevOps",
+		"Programming", "Data", "Cloud", "Open Source", "Career",
+	}
+)
+
+// Prompts are the rendered system prompts a Client sends.
+type Prompts struct {
+	Score     string
+	Summary   string
+	Trends    string
+	Translate string
+	// Version identifies the score and summary prompts; it is recorded on
+	// every analysis made with them.
+	Version string
+}
+
+// promptData is what the prompt templates can refer to.
+type promptData struct {
+	Audience   string
+	Categories []string
+	// Boosted and Penalized are the interest profile's topics.
+	Boosted, Penalized []string
+}
+
+var promptFuncs = template.FuncMap{"join": strings.Join}
+
+// LoadPrompts renders the prompt templates named in cfg, using the embedded
+// default for any that is not set. The interest profile, which may be nil,
+// is passed to the templates.
+func LoadPrompts(cfg config.PromptsConfig, profile *interest.Profile) (*Prompts, error) {
+	data := promptData{Audience: cfg.Audience, Categories: cfg.Categories}
+	data.Boosted, data.Penalized = profile.Topics()
+	if data.Audience == "" {
+		data.Audience = DefaultAudience
+	}
+	if len(data.Categories) == 0 {
+		data.Categories = DefaultCategories
+	}
+
+	var p Prompts
+	for _, t := range []struct {
+		path, name string
+		out        *string
+	}{
+		{cfg.Score, "score.tmpl", &p.Score},
+		{cfg.Summary, "summary.tmpl", &p.Summary},
+		{cfg.Trends, "trends.tmpl", &p.Trends},
+		{cfg.Translate, "translate.tmpl", &p.Translate},
+	} {
+		var src []byte
+		var err error
+		if t.path != "" {
+			src, err = os.ReadFile(t.path)
+		} else {
+			src, err = embeddedPrompts.ReadFile("prompts/" + t.name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read prompt %s: %w", t.name, err)
+		}
+		tmpl, err := template.New(t.name).Funcs(promptFuncs).Option("missingkey=error").Parse(string(src))
+		if err != nil {
+			return nil, fmt.Errorf("parse prompt %s: %w", t.name, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("render prompt %s: %w", t.name, err)
+		}
+		*t.out = strings.TrimSpace(buf.String())
+	}
+
+	p.Version = cfg.Version
+	if p.Version == "" {
+		sum := sha256.Sum256([]byte(p.Score + "\x00" + p.Summary))
+		p.Version = hex.EncodeToString(sum[:4])
+	}
+	return &p, nil
+}
+
+// DefaultPrompts returns the embedded prompts with the default audience and
+// categories.
+var DefaultPrompts = sync.OnceValue(func() *Prompts {
+	p, err := LoadPrompts(config.PromptsConfig{}, nil)
+	if err != nil {
+		panic(err)
+	}
+	return p
+})
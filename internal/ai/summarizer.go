@@ -3,10 +3,12 @@ package ai
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
+	"github.com/chyiyaqing/newsbot/internal/metrics"
 	"github.com/chyiyaqing/newsbot/internal/store"
 )
 
@@ -16,15 +18,6 @@ type SummaryResult struct {
 	RecommendReason string `json:"recommend_reason"`
 }
 
-const summarySystemPrompt = `You are a bilingual (English/Chinese) tech content summarizer.
-For the given article, produce:
-1. A structured summary in English (4-6 sentences covering the key points)
-2. A Chinese translation of the article title
-3. A recommendation reason in Chinese (1-2 sentences explaining why this article is worth reading)
-
-Respond ONLY with valid JSON using standard ASCII double quotes. No other text:
-{"summary":"...","title_cn":"...","recommend_reason":"..."}`
-
 const maxRetries = 3
 
 // SummarizeArticle generates a structured summary, Chinese title, and recommendation reason.
@@ -35,18 +28,23 @@ func (c *Client) SummarizeArticle(ctx context.Context, article store.Article) (*
 
 	var lastErr error
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		resp, err := c.ChatCompletion(ctx, summarySystemPrompt, userPrompt)
+		resp, err := c.chat(ctx, OpSummarize, article.ID, c.prompts.Summary, userPrompt)
+		if errors.Is(err, ErrBudgetExceeded) {
+			return nil, err
+		}
 		if err != nil {
 			lastErr = fmt.Errorf("attempt %d: %w", attempt, err)
-			log.Printf("  Summarize retry %d/%d for %q: %v", attempt, maxRetries, article.Title, err)
+			slog.WarnContext(ctx, "summarize retry", "attempt", attempt, "max", maxRetries, "article_id", article.ID, "title", article.Title, "err", err)
 			time.Sleep(time.Duration(attempt) * 2 * time.Second)
 			continue
 		}
 
 		var result SummaryResult
 		if err := json.Unmarshal([]byte(resp), &result); err != nil {
-			lastErr = fmt.Errorf("attempt %d parse: %w (raw: %s)", attempt, err, resp)
-			log.Printf("  Summarize retry %d/%d for %q: JSON parse error", attempt, maxRetries, article.Title)
+			metrics.LLMParseFailures.Inc(OpSummarize)
+			c.uncache(ctx, c.prompts.Summary, userPrompt)
+			lastErr = fmt.Errorf("attempt %d parse: %w: %w (raw: %s)", attempt, ErrInvalidJSON, err, resp)
+			slog.WarnContext(ctx, "summarize retry: invalid JSON", "attempt", attempt, "max", maxRetries, "article_id", article.ID, "title", article.Title)
 			time.Sleep(time.Duration(attempt) * 2 * time.Second)
 			continue
 		}
@@ -0,0 +1,40 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/chyiyaqing/newsbot/internal/i18n"
+	"github.com/chyiyaqing/newsbot/internal/metrics"
+	"github.com/chyiyaqing/newsbot/internal/store"
+)
+
+type TranslationResult struct {
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+	Reason  string `json:"reason"`
+}
+
+// TranslateArticle translates an analyzed article's title, summary and
+// recommendation reason into lang, a language code such as "ja".
+func (c *Client) TranslateArticle(ctx context.Context, a store.ArticleWithAnalysis, lang string) (*TranslationResult, error) {
+	userPrompt := fmt.Sprintf("Target language: %s\nTitle: %s\nSummary: %s\nReason: %s",
+		i18n.EnglishName(lang), a.Article.Title, a.ArticleAnalysis.AISummary, a.ArticleAnalysis.RecommendReason)
+
+	resp, err := c.chat(ctx, OpTranslate, a.Article.ID, c.prompts.Translate, userPrompt)
+	if err != nil {
+		return nil, fmt.Errorf("translate %q: %w", a.Article.Title, err)
+	}
+
+	var result TranslationResult
+	if err := json.Unmarshal([]byte(resp), &result); err != nil {
+		metrics.LLMParseFailures.Inc(OpTranslate)
+		c.uncache(ctx, c.prompts.Translate, userPrompt)
+		return nil, fmt.Errorf("parse translation for %q: %w: %w (raw: %s)", a.Article.Title, ErrInvalidJSON, err, resp)
+	}
+	if result.Title == "" {
+		return nil, fmt.Errorf("translate %q: empty title", a.Article.Title)
+	}
+	return &result, nil
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"strings"
+	"time"
 
+	"github.com/chyiyaqing/newsbot/internal/metrics"
 	"github.com/chyiyaqing/newsbot/internal/store"
 )
 
@@ -19,16 +22,6 @@ type Trend struct {
 	Articles    []string `json:"articles"`
 }
 
-const trendsSystemPrompt = `You are a technology trend analyst. Based on the following list of recently scored tech articles, identify 2-3 macro technology trends.
-
-For each trend, provide:
-- A concise title in Chinese (no pinyin, no parenthetical notes)
-- A 2-3 sentence description in Chinese explaining the trend
-- A list of related article titles from the input (use the exact original English titles)
-
-CRITICAL: Respond ONLY with valid JSON. Do NOT add any text outside JSON string values. Do NOT add pinyin or annotations after closing quotes. Use only standard ASCII double quotes ("), never smart quotes. Example format:
-{"trends":[{"title":"中文标题","description":"中文描述...","articles":["Article Title 1"]}]}`
-
 // AnalyzeTrends identifies macro technology trends from scored articles.
 func (c *Client) AnalyzeTrends(ctx context.Context, analyses []store.ArticleWithAnalysis) (*TrendReport, error) {
 	var sb strings.Builder
@@ -38,14 +31,79 @@ func (c *Client) AnalyzeTrends(ctx context.Context, analyses []store.ArticleWith
 			a.ArticleAnalysis.TotalScore, a.ArticleAnalysis.Category, a.ArticleAnalysis.Keywords)
 	}
 
-	resp, err := c.ChatCompletion(ctx, trendsSystemPrompt, sb.String())
+	userPrompt := sb.String()
+	resp, err := c.chat(ctx, OpTrends, 0, c.prompts.Trends, userPrompt)
 	if err != nil {
 		return nil, fmt.Errorf("analyze trends: %w", err)
 	}
 
 	var report TrendReport
 	if err := json.Unmarshal([]byte(resp), &report); err != nil {
-		return nil, fmt.Errorf("parse trends: %w (raw: %s)", err, resp)
+		metrics.LLMParseFailures.Inc(OpTrends)
+		c.uncache(ctx, c.prompts.Trends, userPrompt)
+		return nil, fmt.Errorf("parse trends: %w: %w (raw: %s)", ErrInvalidJSON, err, resp)
 	}
 	return &report, nil
 }
+
+// trendReuseAge bounds how old a stored report may be to be reused.
+const trendReuseAge = 24 * time.Hour
+
+// TrendsFor returns the trends for analyses, reusing a recent stored report
+// generated from at least the same articles, or asking the model and storing
+// its answer otherwise. A failure to store the new report is only logged.
+func (c *Client) TrendsFor(ctx context.Context, db *store.Store, window string, analyses []store.ArticleWithAnalysis) (*TrendReport, error) {
+	ids := make([]int64, len(analyses))
+	for i, a := range analyses {
+		ids[i] = a.Article.ID
+	}
+	stored, err := db.TrendReportCovering(window, ids, trendReuseAge)
+	if err != nil {
+		slog.WarnContext(ctx, "load stored trend report", "err", err)
+	} else if stored != nil {
+		slog.InfoContext(ctx, "reusing trend report", "report_id", stored.ID, "created_at", stored.CreatedAt)
+		return FromStored(stored), nil
+	}
+
+	report, err := c.AnalyzeTrends(ctx, analyses)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.SaveTrendReport(c.toStored(report, window, analyses)); err != nil {
+		slog.WarnContext(ctx, "save trend report", "err", err)
+	}
+	return report, nil
+}
+
+// toStored links each trend's article titles to the analysed articles.
+func (c *Client) toStored(r *TrendReport, window string, analyses []store.ArticleWithAnalysis) *store.TrendReport {
+	byTitle := make(map[string]int64, len(analyses))
+	out := &store.TrendReport{Window: window, Model: c.model}
+	for _, a := range analyses {
+		byTitle[normalizeTitle(a.Article.Title)] = a.Article.ID
+		out.ArticleIDs = append(out.ArticleIDs, a.Article.ID)
+	}
+	for _, t := range r.Trends {
+		st := store.Trend{Title: t.Title, Description: t.Description, Articles: t.Articles}
+		for _, title := range t.Articles {
+			if id, ok := byTitle[normalizeTitle(title)]; ok {
+				st.ArticleIDs = append(st.ArticleIDs, id)
+			}
+		}
+		out.Trends = append(out.Trends, st)
+	}
+	return out
+}
+
+// FromStored converts a stored report back to the form notifiers render.
+func FromStored(r *store.TrendReport) *TrendReport {
+	out := &TrendReport{Trends: make([]Trend, len(r.Trends))}
+	for i, t := range r.Trends {
+		out.Trends[i] = Trend{Title: t.Title, Description: t.Description, Articles: t.Articles}
+	}
+	return out
+}
+
+func normalizeTitle(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
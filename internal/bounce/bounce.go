@@ -0,0 +1,60 @@
+package bounce
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/chyiyaqing/newsbot/internal/store"
+)
+
+// Process reads new messages from src and unsubscribes every address named
+// by a permanent-failure DSN or an ARF complaint. Messages that cannot be
+// parsed are logged and skipped. It returns the number of subscribers removed.
+func Process(ctx context.Context, db *store.Store, src Source) (int, error) {
+	removed := 0
+	err := src.Fetch(ctx, func(raw []byte) error {
+		rep, err := Parse(bytes.NewReader(raw))
+		if err != nil {
+			slog.WarnContext(ctx, "parse bounce message", "err", err)
+			return nil
+		}
+		if rep == nil {
+			return nil
+		}
+		for _, addr := range rep.Addresses {
+			ok, err := db.RemoveBouncedSubscriber(store.Bounce{
+				Email:     addr,
+				Kind:      rep.Kind,
+				Detail:    rep.Detail,
+				MessageID: rep.MessageID,
+			})
+			if err != nil {
+				return err
+			}
+			if ok {
+				removed++
+				slog.InfoContext(ctx, "unsubscribed bounced address", "email", addr, "kind", rep.Kind, "detail", rep.Detail)
+			}
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// Poll runs Process every interval until ctx is cancelled.
+func Poll(ctx context.Context, db *store.Store, src Source, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := Process(ctx, db, src); err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, "process bounces", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
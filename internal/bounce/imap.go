@@ -0,0 +1,147 @@
+package bounce
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// imapSource reads unseen messages from an IMAP mailbox over implicit TLS
+// and sets the \Seen flag on the ones processed. It implements only the few
+// IMAP4rev1 commands it needs.
+type imapSource struct {
+	addr     string
+	username string
+	password string
+	mailbox  string
+}
+
+// imapTimeout bounds a whole Fetch so a stalled server cannot block polling.
+const imapTimeout = 5 * time.Minute
+
+func (s *imapSource) Fetch(ctx context.Context, fn func(raw []byte) error) error {
+	host, _, err := net.SplitHostPort(s.addr)
+	if err != nil {
+		return fmt.Errorf("imap address: %w", err)
+	}
+	d := tls.Dialer{Config: &tls.Config{ServerName: host}}
+	conn, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("imap dial: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(imapTimeout))
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c := &imapConn{conn: conn, r: bufio.NewReader(conn)}
+	if _, err := c.r.ReadString('\n'); err != nil {
+		return fmt.Errorf("imap greeting: %w", err)
+	}
+	if _, _, err := c.cmd("LOGIN %s %s", imapQuote(s.username), imapQuote(s.password)); err != nil {
+		return err
+	}
+	defer c.cmd("LOGOUT") //nolint:errcheck
+	if _, _, err := c.cmd("SELECT %s", imapQuote(s.mailbox)); err != nil {
+		return err
+	}
+
+	lines, _, err := c.cmd("UID SEARCH UNSEEN")
+	if err != nil {
+		return err
+	}
+	var uids []string
+	for _, line := range lines {
+		if rest, ok := strings.CutPrefix(line, "* SEARCH"); ok {
+			uids = append(uids, strings.Fields(rest)...)
+		}
+	}
+
+	for _, uid := range uids {
+		_, literals, err := c.cmd("UID FETCH %s BODY.PEEK[]", uid)
+		if err != nil {
+			return err
+		}
+		if len(literals) == 0 {
+			continue
+		}
+		if err := fn(literals[0]); err != nil {
+			return err
+		}
+		if _, _, err := c.cmd(`UID STORE %s +FLAGS.SILENT (\Seen)`, uid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type imapConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	seq  int
+}
+
+var literalPattern = regexp.MustCompile(`\{(\d+)\}\r\n$`)
+
+// cmd sends a tagged command and reads until its completion, returning the
+// untagged response lines and any literals (such as message bodies) in them.
+func (c *imapConn) cmd(format string, args ...any) ([]string, [][]byte, error) {
+	c.seq++
+	tag := "n" + strconv.Itoa(c.seq)
+	command := fmt.Sprintf(format, args...)
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, command); err != nil {
+		return nil, nil, fmt.Errorf("imap write: %w", err)
+	}
+
+	var lines []string
+	var literals [][]byte
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return nil, nil, fmt.Errorf("imap read: %w", err)
+		}
+		// A line ending in {n} is followed by n bytes of literal data and
+		// then the rest of the line.
+		for {
+			m := literalPattern.FindStringSubmatch(line)
+			if m == nil {
+				break
+			}
+			n, _ := strconv.Atoi(m[1])
+			data := make([]byte, n)
+			if _, err := io.ReadFull(c.r, data); err != nil {
+				return nil, nil, fmt.Errorf("imap read literal: %w", err)
+			}
+			literals = append(literals, data)
+			rest, err := c.r.ReadString('\n')
+			if err != nil {
+				return nil, nil, fmt.Errorf("imap read: %w", err)
+			}
+			line = strings.TrimSuffix(line, m[0]) + rest
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if status, ok := strings.CutPrefix(line, tag+" "); ok {
+			if !strings.HasPrefix(status, "OK") {
+				verb, _, _ := strings.Cut(command, " ")
+				return nil, nil, fmt.Errorf("imap %s: %s", verb, status)
+			}
+			return lines, literals, nil
+		}
+		lines = append(lines, line)
+	}
+}
+
+// imapQuote renders s as an IMAP quoted string.
+func imapQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
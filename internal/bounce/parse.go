@@ -0,0 +1,146 @@
+// Package bounce reads delivery status notifications (RFC 3464) and abuse
+// feedback reports (RFC 5965) from a mailbox and unsubscribes the addresses
+// they name.
+package bounce
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	"github.com/chyiyaqing/newsbot/internal/store"
+)
+
+// Report is a parsed bounce or complaint.
+type Report struct {
+	MessageID string
+	Kind      string // store.BounceHard or store.BounceComplaint
+	Addresses []string
+	Detail    string
+}
+
+// Parse reads a raw message. It returns nil, nil for messages that are not
+// a permanent-failure DSN or an ARF complaint, such as delay notifications,
+// auto-replies or ordinary mail.
+func Parse(r io.Reader) (*Report, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("read message: %w", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" || params["boundary"] == "" {
+		return nil, nil
+	}
+
+	rep := &Report{MessageID: strings.Trim(msg.Header.Get("Message-Id"), "<> ")}
+	var originalTo []string
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read report part: %w", err)
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		switch partType {
+		case "message/delivery-status", "message/global-delivery-status":
+			if err := parseDeliveryStatus(part, rep); err != nil {
+				return nil, err
+			}
+		case "message/feedback-report":
+			if err := parseFeedbackReport(part, rep); err != nil {
+				return nil, err
+			}
+		case "message/rfc822", "text/rfc822-headers":
+			// The original message; its recipient identifies the subscriber
+			// when a complaint omits Original-Rcpt-To.
+			if h, err := textproto.NewReader(bufio.NewReader(part)).ReadMIMEHeader(); err == nil || len(h) > 0 {
+				if list, err := mail.ParseAddressList(h.Get("To")); err == nil {
+					for _, a := range list {
+						originalTo = append(originalTo, a.Address)
+					}
+				}
+			}
+		}
+	}
+
+	if rep.Kind == store.BounceComplaint && len(rep.Addresses) == 0 {
+		rep.Addresses = originalTo
+	}
+	if rep.Kind == "" || len(rep.Addresses) == 0 {
+		return nil, nil
+	}
+	return rep, nil
+}
+
+// parseDeliveryStatus collects recipients whose delivery failed permanently
+// (Action: failed with a 5.x.x status). The body is a per-message field block
+// followed by one block per recipient, separated by blank lines.
+func parseDeliveryStatus(r io.Reader, rep *Report) error {
+	tp := textproto.NewReader(bufio.NewReader(r))
+	for {
+		h, err := tp.ReadMIMEHeader()
+		if len(h) > 0 {
+			addr := addressField(h.Get("Final-Recipient"))
+			if addr == "" {
+				addr = addressField(h.Get("Original-Recipient"))
+			}
+			if addr != "" && strings.EqualFold(h.Get("Action"), "failed") && strings.HasPrefix(h.Get("Status"), "5") {
+				rep.Kind = store.BounceHard
+				rep.Addresses = append(rep.Addresses, addr)
+				if rep.Detail == "" {
+					rep.Detail = strings.TrimSpace(h.Get("Status") + " " + h.Get("Diagnostic-Code"))
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read delivery status: %w", err)
+		}
+	}
+}
+
+// parseFeedbackReport reads the machine-readable part of an ARF report.
+// Every feedback type except "not-spam" counts as a complaint.
+func parseFeedbackReport(r io.Reader, rep *Report) error {
+	h, err := textproto.NewReader(bufio.NewReader(r)).ReadMIMEHeader()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("read feedback report: %w", err)
+	}
+	feedbackType := strings.ToLower(strings.TrimSpace(h.Get("Feedback-Type")))
+	if feedbackType == "" || feedbackType == "not-spam" {
+		return nil
+	}
+	rep.Kind = store.BounceComplaint
+	rep.Detail = "feedback-type: " + feedbackType
+	for _, v := range h.Values("Original-Rcpt-To") {
+		if addr := addressField(v); addr != "" {
+			rep.Addresses = append(rep.Addresses, addr)
+		}
+	}
+	return nil
+}
+
+// addressField extracts the address from a field such as
+// "rfc822; user@example.com" or "<user@example.com>".
+func addressField(v string) string {
+	if _, after, ok := strings.Cut(v, ";"); ok {
+		v = after
+	}
+	v = strings.Trim(strings.TrimSpace(v), "<>")
+	if !strings.Contains(v, "@") {
+		return ""
+	}
+	return v
+}
@@ -0,0 +1,178 @@
+package bounce
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/chyiyaqing/newsbot/internal/config"
+)
+
+// Source is a mailbox holding bounce and complaint reports.
+type Source interface {
+	// Fetch calls fn with every message not yet processed. Messages for which
+	// fn returns nil are marked as processed and not returned again.
+	Fetch(ctx context.Context, fn func(raw []byte) error) error
+}
+
+// NewSource builds the source selected by cfg.Source.
+func NewSource(cfg config.BouncesConfig) (Source, error) {
+	switch cfg.Source {
+	case "maildir":
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("maildir source requires a path")
+		}
+		return maildirSource{dir: cfg.Path}, nil
+	case "mbox":
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("mbox source requires a path")
+		}
+		return &mboxSource{path: cfg.Path}, nil
+	case "imap":
+		if cfg.IMAPAddr == "" || cfg.IMAPUsername == "" {
+			return nil, fmt.Errorf("imap source requires an address and username")
+		}
+		mailbox := cfg.IMAPMailbox
+		if mailbox == "" {
+			mailbox = "INBOX"
+		}
+		return &imapSource{addr: cfg.IMAPAddr, username: cfg.IMAPUsername, password: cfg.IMAPPassword, mailbox: mailbox}, nil
+	}
+	return nil, fmt.Errorf("unknown bounce source: %q", cfg.Source)
+}
+
+// maildirSource reads a Maildir. Processed messages are moved from new/ to
+// cur/ with the Seen flag, like a mail client would.
+type maildirSource struct {
+	dir string
+}
+
+func (s maildirSource) Fetch(ctx context.Context, fn func(raw []byte) error) error {
+	for _, sub := range []string{"new", "cur"} {
+		entries, err := os.ReadDir(filepath.Join(s.dir, sub))
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+			base, flags, _ := strings.Cut(e.Name(), ":2,")
+			if strings.Contains(flags, "S") {
+				continue
+			}
+
+			path := filepath.Join(s.dir, sub, e.Name())
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if err := fn(raw); err != nil {
+				return err
+			}
+			seen := filepath.Join(s.dir, "cur", base+":2,"+addFlag(flags, 'S'))
+			if err := os.Rename(path, seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addFlag adds f to a Maildir flag string, keeping the flags sorted.
+func addFlag(flags string, f rune) string {
+	if strings.ContainsRune(flags, f) {
+		return flags
+	}
+	r := []rune(flags + string(f))
+	sort.Slice(r, func(i, j int) bool { return r[i] < r[j] })
+	return string(r)
+}
+
+// mboxSource reads an mbox file, remembering how far it got. The offset is
+// kept in memory only, so after a restart the file is read from the start;
+// reports already recorded are skipped by their Message-ID.
+type mboxSource struct {
+	path   string
+	offset int64
+}
+
+func (s *mboxSource) Fetch(ctx context.Context, fn func(raw []byte) error) error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if st.Size() < s.offset {
+		s.offset = 0 // truncated or rotated
+	}
+	if _, err := f.Seek(s.offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(f)
+	pos := s.offset
+	var msg bytes.Buffer
+	inMessage := false
+
+	// flush hands the buffered message to fn and advances the saved offset to
+	// the start of the next one.
+	flush := func(next int64) error {
+		if inMessage {
+			if err := fn(msg.Bytes()); err != nil {
+				return err
+			}
+		}
+		msg.Reset()
+		s.offset = next
+		return nil
+	}
+
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 && (err == nil || err == io.EOF) {
+			n := int64(len(line))
+			if bytes.HasPrefix(line, []byte("From ")) {
+				if ferr := flush(pos); ferr != nil {
+					return ferr
+				}
+				inMessage = true
+			} else if inMessage {
+				// mboxrd quoting: ">From " lines lose one '>'.
+				if q := bytes.TrimLeft(line, ">"); len(q) < len(line) && bytes.HasPrefix(q, []byte("From ")) {
+					line = line[1:]
+				}
+				msg.Write(line)
+			}
+			pos += n
+		}
+		if err == io.EOF {
+			// Only a message followed by a complete line can be final; a
+			// writer may still be appending to the last one otherwise.
+			if len(line) == 0 || line[len(line)-1] == '\n' {
+				return flush(pos)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -4,14 +4,162 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Ollama   OllamaConfig   `yaml:"ollama"`
-	Telegram TelegramConfig `yaml:"telegram"`
-	SMTP     SMTPConfig     `yaml:"smtp"`
+	Ollama    OllamaConfig    `yaml:"ollama"`
+	Telegram  TelegramConfig  `yaml:"telegram"`
+	SMTP      SMTPConfig      `yaml:"smtp"`
+	Render    RenderConfig    `yaml:"render"`
+	Bounces   BouncesConfig   `yaml:"bounces"`
+	Server    ServerConfig    `yaml:"server"`
+	Admin     AdminConfig     `yaml:"admin"`
+	Log       LogConfig       `yaml:"log"`
+	Usage     UsageConfig     `yaml:"usage"`
+	Prompts   PromptsConfig   `yaml:"prompts"`
+	Interests InterestsConfig `yaml:"interests"`
+	Ranker    RankerConfig    `yaml:"ranker"`
+	Sources   SourcesConfig   `yaml:"sources"`
+	// Languages lists the codes (e.g. "ja", "es") articles are translated
+	// into after they are summarized. Chinese and English need no entry:
+	// every summary already has them.
+	Languages []string `yaml:"languages"`
+}
+
+type SourcesConfig struct {
+	// Action is taken on blogs from the HN list that score chronically low
+	// or whose feed keeps failing: "demote" scrapes them only once per
+	// DemoteInterval, "mute" stops scraping and sending them, "" only
+	// reports them. Manually added blogs are never acted on.
+	Action string `yaml:"action"`
+	// Days of history the stats cover (default 90).
+	Days int `yaml:"days"`
+	// HitScore is the total score an article needs to count as a hit
+	// (default 20).
+	HitScore int `yaml:"hit_score"`
+	// A blog with at least MinAnalyzed analyzed articles (default 10) whose
+	// mean total score is below MinMeanScore (default 12) is low-scoring.
+	MinAnalyzed  int     `yaml:"min_analyzed"`
+	MinMeanScore float64 `yaml:"min_mean_score"`
+	// A blog whose feed failed more than MaxErrorRate (default 0.9) of at
+	// least MinFetches attempts (default 10) is dead.
+	MinFetches   int     `yaml:"min_fetches"`
+	MaxErrorRate float64 `yaml:"max_error_rate"`
+	// DemoteInterval is how often demoted blogs are scraped (default 24h).
+	DemoteInterval string `yaml:"demote_interval"`
+}
+
+// Window returns the start of the period the stats cover, Days (or 90)
+// before now.
+func (c SourcesConfig) Window(now time.Time) time.Time {
+	days := c.Days
+	if days <= 0 {
+		days = 90
+	}
+	return now.AddDate(0, 0, -days)
+}
+
+// ScrapeInterval returns the parsed DemoteInterval, or 24h if unset or
+// invalid.
+func (c SourcesConfig) ScrapeInterval() time.Duration {
+	if d, err := time.ParseDuration(c.DemoteInterval); err == nil && d > 0 {
+		return d
+	}
+	return 24 * time.Hour
+}
+
+type RankerConfig struct {
+	// Enabled orders digests by the latest model trained with
+	// `newsbot train-ranker` instead of the total score.
+	Enabled bool `yaml:"enabled"`
+	// MinExamples is the number of labeled articles training needs
+	// (default 30).
+	MinExamples int `yaml:"min_examples"`
+}
+
+type InterestsConfig struct {
+	// Boost and Penalize map topics to points added to or subtracted from
+	// an article's total score when its category or a keyword matches the
+	// topic (case-insensitive, whole words). They are also given to the
+	// model when it scores relevance.
+	Boost    map[string]int `yaml:"boost"`
+	Penalize map[string]int `yaml:"penalize"`
+	// MustRead lists source domains whose articles get MustReadBoost points
+	// (default 5).
+	MustRead      []string `yaml:"must_read"`
+	MustReadBoost int      `yaml:"must_read_boost"`
+}
+
+type PromptsConfig struct {
+	// Score, Summary, Trends and Translate are text/template files replacing
+	// the built-in system prompts; unset ones use the defaults.
+	Score     string `yaml:"score"`
+	Summary   string `yaml:"summary"`
+	Trends    string `yaml:"trends"`
+	Translate string `yaml:"translate"`
+	// Audience describes who articles are scored for, as {{.Audience}}.
+	Audience string `yaml:"audience"`
+	// Categories is the taxonomy articles are classified into, as
+	// {{.Categories}}.
+	Categories []string `yaml:"categories"`
+	// Version is recorded on each analysis; defaults to a hash of the
+	// rendered score and summary prompts.
+	Version string `yaml:"version"`
+}
+
+type UsageConfig struct {
+	// DailyTokenBudget pauses model calls once this many tokens were used
+	// since midnight UTC (0 = unlimited).
+	DailyTokenBudget int64 `yaml:"daily_token_budget"`
+	// Prices in any currency per million prompt and completion tokens, used
+	// to estimate cost in usage reports. Zero for a free local model.
+	PromptPrice     float64 `yaml:"prompt_price"`
+	CompletionPrice float64 `yaml:"completion_price"`
+}
+
+// Cost estimates the price of the given token counts.
+func (c UsageConfig) Cost(promptTokens, completionTokens int64) float64 {
+	return (float64(promptTokens)*c.PromptPrice + float64(completionTokens)*c.CompletionPrice) / 1e6
+}
+
+type LogConfig struct {
+	// Level is debug, info (default), warn or error.
+	Level string `yaml:"level"`
+	// Format is text (default) or json.
+	Format string `yaml:"format"`
+}
+
+type ServerConfig struct {
+	// CORSOrigins lists origins allowed to call the API from a browser, e.g.
+	// "https://news.example.com". "*" allows any origin; empty allows none
+	// (same-origin only).
+	CORSOrigins []string `yaml:"cors_origins"`
+	// TrustedProxies lists the addresses or CIDR ranges of reverse proxies
+	// whose X-Real-IP and X-Forwarded-Proto headers are believed. Requests
+	// from anywhere else are identified by their own address.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+type AdminConfig struct {
+	// APIKeys are static bearer tokens accepted on /api/admin/*.
+	APIKeys []string `yaml:"api_keys"`
+	// TokenSecret enables signed, expiring admin tokens issued with
+	// "newsbot admin-token".
+	TokenSecret string `yaml:"token_secret"`
+}
+
+// Enabled reports whether any admin credential is configured.
+func (c AdminConfig) Enabled() bool {
+	return len(c.APIKeys) > 0 || c.TokenSecret != ""
+}
+
+type RenderConfig struct {
+	// TemplatesDir holds digest templates overriding the embedded defaults;
+	// files missing from it fall back to the built-in versions.
+	TemplatesDir string `yaml:"templates_dir"`
 }
 
 type SMTPConfig struct {
@@ -21,11 +169,64 @@ type SMTPConfig struct {
 	Password string `yaml:"password"`
 	From     string `yaml:"from"`
 	SiteURL  string `yaml:"site_url"`
+	// Concurrency is the number of SMTP sessions used for bulk sends.
+	Concurrency int `yaml:"concurrency"`
+	// RatePerMinute caps bulk sends per minute (0 = unlimited).
+	RatePerMinute int `yaml:"rate_per_minute"`
+	// ConfirmSecret signs subscription confirmation links.
+	ConfirmSecret string `yaml:"confirm_secret"`
+	// ConfirmTTL is how long a confirmation link stays valid; unconfirmed
+	// subscribers older than this are removed. Defaults to 48h.
+	ConfirmTTL string `yaml:"confirm_ttl"`
+}
+
+// ConfirmWindow returns the parsed ConfirmTTL, or 48h if unset or invalid.
+func (c SMTPConfig) ConfirmWindow() time.Duration {
+	if d, err := time.ParseDuration(c.ConfirmTTL); err == nil && d > 0 {
+		return d
+	}
+	return 48 * time.Hour
+}
+
+type BouncesConfig struct {
+	// Threshold suspends a subscriber after this many permanent delivery
+	// failures without a successful send in between (0 = never). Defaults to 3.
+	Threshold int `yaml:"threshold"`
+	// Source is where DSN bounce and ARF complaint reports are read from:
+	// "imap", "mbox", "maildir", or "" to disable polling.
+	Source string `yaml:"source"`
+	// Path is the mbox file or Maildir directory.
+	Path string `yaml:"path"`
+	// IMAP server (host:port, implicit TLS) and mailbox (default INBOX).
+	IMAPAddr     string `yaml:"imap_addr"`
+	IMAPUsername string `yaml:"imap_username"`
+	IMAPPassword string `yaml:"imap_password"`
+	IMAPMailbox  string `yaml:"imap_mailbox"`
+	// PollInterval is how often the source is checked. Defaults to 10m.
+	PollInterval string `yaml:"poll_interval"`
+}
+
+// Interval returns the parsed PollInterval, or 10m if unset or invalid.
+func (c BouncesConfig) Interval() time.Duration {
+	if d, err := time.ParseDuration(c.PollInterval); err == nil && d > 0 {
+		return d
+	}
+	return 10 * time.Minute
 }
 
 type TelegramConfig struct {
 	BotToken string `yaml:"bot_token"`
 	ChatID   string `yaml:"chat_id"`
+	// Updates selects how feedback button presses are received:
+	// "polling", "webhook", or "" to disable inline keyboards.
+	Updates       string `yaml:"updates"`
+	WebhookSecret string `yaml:"webhook_secret"`
+	// AdminIDs are the Telegram user IDs whose 🔇 presses mute a source for
+	// everyone; other readers' presses are only recorded as feedback.
+	AdminIDs []string `yaml:"admin_ids"`
+	// Language of the channel digest: "bilingual" (default), "zh", "en" or
+	// one of the translation languages.
+	Language string `yaml:"language"`
 }
 
 type OllamaConfig struct {
@@ -33,6 +234,17 @@ type OllamaConfig struct {
 	Model    string `yaml:"model"`
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
+	// CacheTTL is how long model responses are reused for identical prompts.
+	// Defaults to 168h; "0" disables the cache.
+	CacheTTL string `yaml:"cache_ttl"`
+}
+
+// CacheWindow returns the parsed CacheTTL, or 168h if unset or invalid.
+func (c OllamaConfig) CacheWindow() time.Duration {
+	if d, err := time.ParseDuration(c.CacheTTL); err == nil && d >= 0 {
+		return d
+	}
+	return 7 * 24 * time.Hour
 }
 
 func Load(path string) (*Config, error) {
@@ -43,6 +255,23 @@ func Load(path string) (*Config, error) {
 			Address: "http://localhost:11434",
 			Model:   "gemma3:4b",
 		},
+		Bounces: BouncesConfig{
+			Threshold: 3,
+		},
+		Interests: InterestsConfig{
+			MustReadBoost: 5,
+		},
+		Ranker: RankerConfig{
+			MinExamples: 30,
+		},
+		Sources: SourcesConfig{
+			Days:         90,
+			HitScore:     20,
+			MinAnalyzed:  10,
+			MinMeanScore: 12,
+			MinFetches:   10,
+			MaxErrorRate: 0.9,
+		},
 	}
 
 	data, err := os.ReadFile(path)
@@ -77,12 +306,53 @@ func applyEnv(cfg *Config) {
 	if v := os.Getenv("OLLAMA_PASSWORD"); v != "" {
 		cfg.Ollama.Password = v
 	}
+	if v := os.Getenv("LLM_CACHE_TTL"); v != "" {
+		cfg.Ollama.CacheTTL = v
+	}
+	if v := os.Getenv("RANKER_ENABLED"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.Ranker.Enabled = b
+		}
+	}
+	if v := os.Getenv("SOURCES_ACTION"); v != "" {
+		cfg.Sources.Action = v
+	}
+	if v := os.Getenv("LLM_DAILY_TOKEN_BUDGET"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			cfg.Usage.DailyTokenBudget = n
+		}
+	}
+	if v := os.Getenv("LLM_PROMPT_PRICE"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			cfg.Usage.PromptPrice = f
+		}
+	}
+	if v := os.Getenv("LLM_COMPLETION_PRICE"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			cfg.Usage.CompletionPrice = f
+		}
+	}
 	if v := os.Getenv("TG_BOT_TOKEN"); v != "" {
 		cfg.Telegram.BotToken = v
 	}
 	if v := os.Getenv("TG_CHAT_ID"); v != "" {
 		cfg.Telegram.ChatID = v
 	}
+	if v := os.Getenv("TG_UPDATES"); v != "" {
+		cfg.Telegram.Updates = v
+	}
+	if v := os.Getenv("TG_WEBHOOK_SECRET"); v != "" {
+		cfg.Telegram.WebhookSecret = v
+	}
+	if v := os.Getenv("TG_ADMIN_IDS"); v != "" {
+		cfg.Telegram.AdminIDs = splitList(v)
+	}
+	if v := os.Getenv("TG_LANGUAGE"); v != "" {
+		cfg.Telegram.Language = v
+	}
+	if v := os.Getenv("LANGUAGES"); v != "" {
+		cfg.Languages = splitList(v)
+	}
 	if v := os.Getenv("SMTP_HOST"); v != "" {
 		cfg.SMTP.Host = v
 	}
@@ -103,6 +373,80 @@ func applyEnv(cfg *Config) {
 	if v := os.Getenv("SITE_URL"); v != "" {
 		cfg.SMTP.SiteURL = v
 	}
+	if v := os.Getenv("SMTP_CONCURRENCY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.SMTP.Concurrency = n
+		}
+	}
+	if v := os.Getenv("SMTP_RATE_PER_MINUTE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.SMTP.RatePerMinute = n
+		}
+	}
+	if v := os.Getenv("CONFIRM_SECRET"); v != "" {
+		cfg.SMTP.ConfirmSecret = v
+	}
+	if v := os.Getenv("CONFIRM_TTL"); v != "" {
+		cfg.SMTP.ConfirmTTL = v
+	}
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		cfg.Log.Level = v
+	}
+	if v := os.Getenv("LOG_FORMAT"); v != "" {
+		cfg.Log.Format = v
+	}
+	if v := os.Getenv("TEMPLATES_DIR"); v != "" {
+		cfg.Render.TemplatesDir = v
+	}
+	if v := os.Getenv("BOUNCE_THRESHOLD"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.Bounces.Threshold = n
+		}
+	}
+	if v := os.Getenv("BOUNCE_SOURCE"); v != "" {
+		cfg.Bounces.Source = v
+	}
+	if v := os.Getenv("BOUNCE_PATH"); v != "" {
+		cfg.Bounces.Path = v
+	}
+	if v := os.Getenv("BOUNCE_POLL_INTERVAL"); v != "" {
+		cfg.Bounces.PollInterval = v
+	}
+	if v := os.Getenv("IMAP_ADDR"); v != "" {
+		cfg.Bounces.IMAPAddr = v
+	}
+	if v := os.Getenv("IMAP_USERNAME"); v != "" {
+		cfg.Bounces.IMAPUsername = v
+	}
+	if v := os.Getenv("IMAP_PASSWORD"); v != "" {
+		cfg.Bounces.IMAPPassword = v
+	}
+	if v := os.Getenv("IMAP_MAILBOX"); v != "" {
+		cfg.Bounces.IMAPMailbox = v
+	}
+	if v := os.Getenv("CORS_ORIGINS"); v != "" {
+		cfg.Server.CORSOrigins = splitList(v)
+	}
+	if v := os.Getenv("TRUSTED_PROXIES"); v != "" {
+		cfg.Server.TrustedProxies = splitList(v)
+	}
+	if v := os.Getenv("ADMIN_API_KEYS"); v != "" {
+		cfg.Admin.APIKeys = splitList(v)
+	}
+	if v := os.Getenv("ADMIN_TOKEN_SECRET"); v != "" {
+		cfg.Admin.TokenSecret = v
+	}
+}
+
+// splitList parses a comma-separated env value, dropping empty items.
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
 }
 
 // loadEnvFile reads a .env file and sets environment variables
@@ -0,0 +1,282 @@
+// Package eval compares models and prompt versions on a fixture set of
+// articles labeled by hand. Every variant scores and summarizes every
+// fixture; the report covers JSON validity, agreement with the labels,
+// latency and the summaries side by side.
+package eval
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/chyiyaqing/newsbot/internal/ai"
+	"github.com/chyiyaqing/newsbot/internal/store"
+)
+
+// Fixture is one labeled article, a line of the fixture JSONL file. Score
+// labels of zero and an empty category mean unlabeled.
+type Fixture struct {
+	Title      string `json:"title"`
+	Source     string `json:"source"`
+	Summary    string `json:"summary"`
+	Relevance  int    `json:"relevance"`
+	Quality    int    `json:"quality"`
+	Timeliness int    `json:"timeliness"`
+	Category   string `json:"category"`
+}
+
+// Labeled reports whether all three score dimensions are labeled.
+func (f Fixture) Labeled() bool {
+	return f.Relevance > 0 && f.Quality > 0 && f.Timeliness > 0
+}
+
+// LoadFixtures reads fixtures from a JSONL file, skipping blank lines.
+func LoadFixtures(path string) ([]Fixture, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var fixtures []Fixture
+	sc := bufio.NewScanner(file)
+	sc.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+		var f Fixture
+		if err := json.Unmarshal([]byte(text), &f); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if f.Title == "" {
+			return nil, fmt.Errorf("%s:%d: missing title", path, line)
+		}
+		fixtures = append(fixtures, f)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(fixtures) == 0 {
+		return nil, fmt.Errorf("%s: no fixtures", path)
+	}
+	return fixtures, nil
+}
+
+// Variant is a model and prompt combination under evaluation.
+type Variant struct {
+	Name   string
+	Client *ai.Client
+}
+
+// Result is the outcome of one variant on one fixture.
+type Result struct {
+	Score          *ai.ScoreResult
+	ScoreErr       error
+	ScoreLatency   time.Duration
+	Summary        *ai.SummaryResult
+	SummaryErr     error
+	SummaryLatency time.Duration
+}
+
+// Total is the sum of the model's score dimensions, or 0 without a score.
+func (r Result) Total() int {
+	if r.Score == nil {
+		return 0
+	}
+	return r.Score.Relevance + r.Score.Quality + r.Score.Timeliness
+}
+
+// Stats summarizes a variant over all fixtures. Correlations are Pearson
+// coefficients against the labels and NaN when undefined.
+type Stats struct {
+	Name          string
+	Model         string
+	PromptVersion string
+
+	Scored       int     // valid score responses
+	InvalidJSON  int     // score responses that were not valid JSON
+	ScoreErrors  int     // failed score calls, including invalid JSON
+	ScoreValid   float64 // share of fixtures with a valid score
+	Summarized   int
+	SummaryValid float64 // share of fixtures summarized (up to 3 attempts)
+
+	Labeled        int // fixtures with labels and a valid score
+	RelevanceCorr  float64
+	QualityCorr    float64
+	TimelinessCorr float64
+	TotalCorr      float64
+
+	Categorized      int // fixtures with a category label and a valid score
+	CategoryAccuracy float64
+
+	ScoreLatencyMean   time.Duration
+	ScoreLatencyP95    time.Duration
+	SummaryLatencyMean time.Duration
+	SummaryLatencyP95  time.Duration
+}
+
+// Report is the outcome of an evaluation run.
+type Report struct {
+	GeneratedAt time.Time
+	Fixtures    []Fixture
+	Variants    []Stats
+	// Results[i][j] is variant j on fixture i.
+	Results [][]Result
+}
+
+// Run scores and summarizes every fixture with every variant. It stops
+// early only when ctx is cancelled or the token budget runs out.
+func Run(ctx context.Context, fixtures []Fixture, variants []Variant) (*Report, error) {
+	rep := &Report{
+		GeneratedAt: time.Now(),
+		Fixtures:    fixtures,
+		Results:     make([][]Result, len(fixtures)),
+	}
+	for i := range rep.Results {
+		rep.Results[i] = make([]Result, len(variants))
+	}
+
+	for j, v := range variants {
+		slog.InfoContext(ctx, "evaluating variant", "variant", v.Name, "model", v.Client.Model(),
+			"prompt_version", v.Client.PromptVersion(), "fixtures", len(fixtures))
+		for i, f := range fixtures {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			article := store.Article{Title: f.Title, BlogDomain: f.Source, Summary: f.Summary}
+			res := &rep.Results[i][j]
+
+			start := time.Now()
+			res.Score, res.ScoreErr = v.Client.ScoreArticle(ctx, article)
+			res.ScoreLatency = time.Since(start)
+
+			start = time.Now()
+			res.Summary, res.SummaryErr = v.Client.SummarizeArticle(ctx, article)
+			res.SummaryLatency = time.Since(start)
+
+			if errors.Is(res.ScoreErr, ai.ErrBudgetExceeded) || errors.Is(res.SummaryErr, ai.ErrBudgetExceeded) {
+				return nil, ai.ErrBudgetExceeded
+			}
+			if res.ScoreErr != nil {
+				slog.WarnContext(ctx, "eval score", "variant", v.Name, "title", f.Title, "err", res.ScoreErr)
+			}
+			if res.SummaryErr != nil {
+				slog.WarnContext(ctx, "eval summary", "variant", v.Name, "title", f.Title, "err", res.SummaryErr)
+			}
+		}
+		rep.Variants = append(rep.Variants, stats(v, fixtures, rep.Results, j))
+	}
+	return rep, nil
+}
+
+func stats(v Variant, fixtures []Fixture, results [][]Result, j int) Stats {
+	st := Stats{Name: v.Name, Model: v.Client.Model(), PromptVersion: v.Client.PromptVersion()}
+
+	var rel, qual, tim, total [2][]float64 // model, label
+	var scoreLat, summaryLat []time.Duration
+	correct := 0
+	for i, f := range fixtures {
+		r := results[i][j]
+		scoreLat = append(scoreLat, r.ScoreLatency)
+		summaryLat = append(summaryLat, r.SummaryLatency)
+		if r.SummaryErr == nil {
+			st.Summarized++
+		}
+		if r.ScoreErr != nil {
+			st.ScoreErrors++
+			if errors.Is(r.ScoreErr, ai.ErrInvalidJSON) {
+				st.InvalidJSON++
+			}
+			continue
+		}
+		st.Scored++
+
+		if f.Labeled() {
+			st.Labeled++
+			for _, p := range []struct {
+				dst          *[2][]float64
+				model, label int
+			}{
+				{&rel, r.Score.Relevance, f.Relevance},
+				{&qual, r.Score.Quality, f.Quality},
+				{&tim, r.Score.Timeliness, f.Timeliness},
+				{&total, r.Total(), f.Relevance + f.Quality + f.Timeliness},
+			} {
+				p.dst[0] = append(p.dst[0], float64(p.model))
+				p.dst[1] = append(p.dst[1], float64(p.label))
+			}
+		}
+		if f.Category != "" {
+			st.Categorized++
+			if strings.EqualFold(strings.TrimSpace(r.Score.Category), f.Category) {
+				correct++
+			}
+		}
+	}
+
+	n := float64(len(fixtures))
+	st.ScoreValid = float64(st.Scored) / n
+	st.SummaryValid = float64(st.Summarized) / n
+	st.RelevanceCorr = pearson(rel[0], rel[1])
+	st.QualityCorr = pearson(qual[0], qual[1])
+	st.TimelinessCorr = pearson(tim[0], tim[1])
+	st.TotalCorr = pearson(total[0], total[1])
+	st.CategoryAccuracy = math.NaN()
+	if st.Categorized > 0 {
+		st.CategoryAccuracy = float64(correct) / float64(st.Categorized)
+	}
+	st.ScoreLatencyMean, st.ScoreLatencyP95 = latency(scoreLat)
+	st.SummaryLatencyMean, st.SummaryLatencyP95 = latency(summaryLat)
+	return st
+}
+
+// pearson returns the correlation coefficient of x and y, or NaN when it is
+// undefined (fewer than two points or no variance).
+func pearson(x, y []float64) float64 {
+	n := float64(len(x))
+	if len(x) < 2 {
+		return math.NaN()
+	}
+	var mx, my float64
+	for i := range x {
+		mx += x[i]
+		my += y[i]
+	}
+	mx, my = mx/n, my/n
+	var cov, vx, vy float64
+	for i := range x {
+		dx, dy := x[i]-mx, y[i]-my
+		cov += dx * dy
+		vx += dx * dx
+		vy += dy * dy
+	}
+	if vx == 0 || vy == 0 {
+		return math.NaN()
+	}
+	return cov / math.Sqrt(vx*vy)
+}
+
+// latency returns the mean and 95th percentile (nearest rank) of d.
+func latency(d []time.Duration) (mean, p95 time.Duration) {
+	if len(d) == 0 {
+		return 0, 0
+	}
+	var sum time.Duration
+	for _, v := range d {
+		sum += v
+	}
+	sorted := slices.Clone(d)
+	slices.Sort(sorted)
+	rank := int(math.Ceil(0.95*float64(len(sorted)))) - 1
+	return sum / time.Duration(len(d)), sorted[max(rank, 0)]
+}
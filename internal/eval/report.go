@@ -0,0 +1,73 @@
+package eval
+
+import (
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"math"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates/*
+var embedded embed.FS
+
+// Report formats.
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+var funcs = map[string]any{
+	"pct":  pct,
+	"corr": corr,
+	"dur":  dur,
+	"inc":  func(i int) int { return i + 1 },
+	"cell": strings.NewReplacer("|", `\|`, "\r", " ", "\n", " ").Replace,
+	"errtext": func(err error) string {
+		if err == nil {
+			return ""
+		}
+		msg := err.Error()
+		if r := []rune(msg); len(r) > 120 {
+			msg = string(r[:120]) + "…"
+		}
+		return msg
+	},
+}
+
+var (
+	markdownTmpl = texttemplate.Must(texttemplate.New("report.md").Funcs(funcs).ParseFS(embedded, "templates/report.md"))
+	htmlTmpl     = htmltemplate.Must(htmltemplate.New("report.html").Funcs(funcs).ParseFS(embedded, "templates/report.html"))
+)
+
+// Write renders the report to w in format (FormatMarkdown or FormatHTML).
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatMarkdown, "md", "":
+		return markdownTmpl.Execute(w, r)
+	case FormatHTML:
+		return htmlTmpl.Execute(w, r)
+	}
+	return fmt.Errorf("unknown report format %q", format)
+}
+
+func pct(v float64) string {
+	if math.IsNaN(v) {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.1f%%", v*100)
+}
+
+func corr(v float64) string {
+	if math.IsNaN(v) {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.2f", v)
+}
+
+func dur(d time.Duration) string {
+	return d.Round(10 * time.Millisecond).String()
+}
@@ -0,0 +1,105 @@
+// Package events is an in-process publish/subscribe bus that carries pipeline
+// events to live clients. Recent events are kept so that reconnecting
+// clients can resume where they left off.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types published by the pipeline.
+const (
+	ArticleAnalyzed  = "article.analyzed"
+	TrendsUpdated    = "trends.updated"
+	PipelineFinished = "pipeline.finished"
+)
+
+const (
+	// historySize is how many events are kept for Last-Event-ID resume.
+	historySize = 256
+	// subscriberBuffer is how far a subscriber may fall behind before it is
+	// dropped; it can reconnect and resume from the history.
+	subscriberBuffer = 64
+)
+
+// Event is one message on the bus. Category and Score are set for article
+// events so subscribers can filter without decoding Data.
+type Event struct {
+	ID       int64
+	Type     string
+	Time     time.Time
+	Category string
+	Score    int
+	Data     any
+}
+
+// Bus fans published events out to subscribers. A nil *Bus discards events.
+type Bus struct {
+	mu      sync.Mutex
+	nextID  int64
+	history []Event
+	subs    map[chan Event]struct{}
+}
+
+// NewBus creates an empty bus.
+func NewBus() *Bus {
+	return &Bus{nextID: 1, subs: make(map[chan Event]struct{})}
+}
+
+// Publish assigns e an ID and delivers it to every subscriber. Subscribers
+// that are too far behind are disconnected rather than blocking the pipeline.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e.ID = b.nextID
+	b.nextID++
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if len(b.history) == historySize {
+		b.history = append(b.history[:0], b.history[1:]...)
+	}
+	b.history = append(b.history, e)
+
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe returns the retained events after lastID, followed on the
+// channel by every new event. The channel is closed when cancel is called
+// or the subscriber falls behind.
+func (b *Bus) Subscribe(lastID int64) (replay []Event, ch <-chan Event, cancel func()) {
+	c := make(chan Event, subscriberBuffer)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if lastID > 0 {
+		for _, e := range b.history {
+			if e.ID > lastID {
+				replay = append(replay, e)
+			}
+		}
+	}
+	b.subs[c] = struct{}{}
+
+	cancel = func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[c]; ok {
+			delete(b.subs, c)
+			close(c)
+		}
+	}
+	return replay, c, cancel
+}
@@ -3,7 +3,7 @@ package hnpopular
 import (
 	"encoding/csv"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"sort"
 	"strconv"
@@ -17,14 +17,14 @@ const cdnBase = "https://hn-popularity.cdn.refactoringenglish.com"
 // FetchTopBlogs downloads CSV data from the HN Popularity CDN and returns the top blogs by score.
 func FetchTopBlogs(limit int) ([]store.Blog, error) {
 	// 1. Download and aggregate scores from hn-data.csv
-	log.Printf("Fetching HN data from %s/hn-data.csv", cdnBase)
+	slog.Info("fetching HN data", "url", cdnBase+"/hn-data.csv")
 	scores, err := fetchScores()
 	if err != nil {
 		return nil, fmt.Errorf("fetch scores: %w", err)
 	}
 
 	// 2. Download author metadata from domains-meta.csv
-	log.Printf("Fetching domain metadata from %s/domains-meta.csv", cdnBase)
+	slog.Info("fetching domain metadata", "url", cdnBase+"/domains-meta.csv")
 	meta, err := fetchMeta()
 	if err != nil {
 		return nil, fmt.Errorf("fetch meta: %w", err)
@@ -58,7 +58,7 @@ func FetchTopBlogs(limit int) ([]store.Blog, error) {
 		})
 	}
 
-	log.Printf("Found %d blogs", len(blogs))
+	slog.Info("found blogs", "count", len(blogs))
 	return blogs, nil
 }
 
@@ -0,0 +1,232 @@
+// Package i18n holds the label catalogs of the digest formatters and the
+// names of the languages articles can be translated into.
+package i18n
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Bilingual digests show English articles with Chinese titles and labels.
+const Bilingual = "bilingual"
+
+type language struct {
+	english string // name used in model prompts
+	native  string // name shown to readers
+}
+
+var languages = map[string]language{
+	"en": {"English", "English"},
+	"zh": {"Simplified Chinese", "中文"},
+	"ja": {"Japanese", "日本語"},
+	"ko": {"Korean", "한국어"},
+	"es": {"Spanish", "Español"},
+	"fr": {"French", "Français"},
+	"de": {"German", "Deutsch"},
+	"pt": {"Portuguese", "Português"},
+	"ru": {"Russian", "Русский"},
+}
+
+// catalogs maps a language to its labels. Labels with verbs are formatted
+// with the arguments given to Label. Bilingual digests use the Chinese
+// labels except where listed; English is the fallback for missing languages
+// and keys.
+var catalogs = map[string]map[string]string{
+	Bilingual: {
+		"top_articles": "Top Articles",
+	},
+	"en": {
+		"new_articles":       "%d new articles",
+		"recent":             "Tech news from the past %s",
+		"window_24h":         "24 hours",
+		"window_3days":       "3 days",
+		"window_7days":       "7 days",
+		"top_articles":       "Top Articles",
+		"score":              "Score",
+		"reason":             "Why read",
+		"summary":            "Summary",
+		"link":               "Link",
+		"translated_title":   "Title",
+		"trends":             "Tech Trends",
+		"trends_summary":     "Tech Trend Summary",
+		"related":            "Related",
+		"manage_preferences": "Manage preferences",
+		"unsubscribe":        "Unsubscribe",
+		"unsubscribe_prompt": "Don't want these emails?",
+		"email_subject":      "NewsBot Tech Digest — %d picks",
+	},
+	"zh": {
+		"new_articles":       "%d 篇新文章",
+		"recent":             "过去 %s 的技术动态",
+		"window_24h":         "24小时",
+		"window_3days":       "3天",
+		"window_7days":       "7天",
+		"top_articles":       "精选文章",
+		"score":              "评分",
+		"reason":             "推荐",
+		"summary":            "摘要",
+		"link":               "链接",
+		"translated_title":   "中文",
+		"trends":             "技术趋势",
+		"trends_summary":     "技术趋势总结",
+		"related":            "相关",
+		"manage_preferences": "管理订阅偏好",
+		"unsubscribe":        "取消订阅",
+		"unsubscribe_prompt": "不想再收到邮件？",
+		"email_subject":      "NewsBot 技术资讯 — 最新 %d 篇精选",
+	},
+	"ja": {
+		"new_articles":       "新着記事 %d 件",
+		"recent":             "過去 %s の技術動向",
+		"window_24h":         "24時間",
+		"window_3days":       "3日間",
+		"window_7days":       "7日間",
+		"top_articles":       "注目の記事",
+		"score":              "スコア",
+		"reason":             "おすすめ理由",
+		"summary":            "要約",
+		"link":               "リンク",
+		"translated_title":   "タイトル",
+		"trends":             "技術トレンド",
+		"trends_summary":     "技術トレンドのまとめ",
+		"related":            "関連",
+		"manage_preferences": "配信設定",
+		"unsubscribe":        "配信停止",
+		"unsubscribe_prompt": "メールが不要になりましたか？",
+		"email_subject":      "NewsBot 技術ダイジェスト — 厳選 %d 件",
+	},
+	"ko": {
+		"new_articles":       "새 글 %d개",
+		"recent":             "지난 %s의 기술 소식",
+		"window_24h":         "24시간",
+		"window_3days":       "3일",
+		"window_7days":       "7일",
+		"top_articles":       "추천 글",
+		"score":              "점수",
+		"reason":             "추천 이유",
+		"summary":            "요약",
+		"link":               "링크",
+		"translated_title":   "제목",
+		"trends":             "기술 트렌드",
+		"trends_summary":     "기술 트렌드 요약",
+		"related":            "관련",
+		"manage_preferences": "구독 설정",
+		"unsubscribe":        "구독 취소",
+		"unsubscribe_prompt": "더 이상 메일을 받고 싶지 않으신가요?",
+		"email_subject":      "NewsBot 기술 다이제스트 — 엄선 %d개",
+	},
+	"es": {
+		"new_articles":       "%d artículos nuevos",
+		"recent":             "Noticias tecnológicas de las últimas %s",
+		"window_24h":         "24 horas",
+		"window_3days":       "3 días",
+		"window_7days":       "7 días",
+		"top_articles":       "Artículos destacados",
+		"score":              "Puntuación",
+		"reason":             "Por qué leerlo",
+		"summary":            "Resumen",
+		"link":               "Enlace",
+		"translated_title":   "Título",
+		"trends":             "Tendencias tecnológicas",
+		"trends_summary":     "Resumen de tendencias",
+		"related":            "Relacionado",
+		"manage_preferences": "Gestionar preferencias",
+		"unsubscribe":        "Darse de baja",
+		"unsubscribe_prompt": "¿No quieres recibir estos correos?",
+		"email_subject":      "NewsBot resumen tecnológico — %d destacados",
+	},
+	"fr": {
+		"new_articles":       "%d nouveaux articles",
+		"recent":             "L'actualité tech des dernières %s",
+		"window_24h":         "24 heures",
+		"window_3days":       "3 jours",
+		"window_7days":       "7 jours",
+		"top_articles":       "Articles à la une",
+		"score":              "Note",
+		"reason":             "Pourquoi le lire",
+		"summary":            "Résumé",
+		"link":               "Lien",
+		"translated_title":   "Titre",
+		"trends":             "Tendances tech",
+		"trends_summary":     "Synthèse des tendances",
+		"related":            "Voir aussi",
+		"manage_preferences": "Gérer les préférences",
+		"unsubscribe":        "Se désabonner",
+		"unsubscribe_prompt": "Vous ne souhaitez plus recevoir ces e-mails ?",
+		"email_subject":      "NewsBot digest tech — %d sélections",
+	},
+	"de": {
+		"new_articles":       "%d neue Artikel",
+		"recent":             "Tech-News der letzten %s",
+		"window_24h":         "24 Stunden",
+		"window_3days":       "3 Tage",
+		"window_7days":       "7 Tage",
+		"top_articles":       "Top-Artikel",
+		"score":              "Bewertung",
+		"reason":             "Warum lesen",
+		"summary":            "Zusammenfassung",
+		"link":               "Link",
+		"translated_title":   "Titel",
+		"trends":             "Tech-Trends",
+		"trends_summary":     "Trend-Überblick",
+		"related":            "Verwandt",
+		"manage_preferences": "Einstellungen verwalten",
+		"unsubscribe":        "Abmelden",
+		"unsubscribe_prompt": "Keine E-Mails mehr erhalten?",
+		"email_subject":      "NewsBot Tech-Digest — %d ausgewählte Artikel",
+	},
+}
+
+// base returns the catalog language for lang: the primary subtag, with
+// bilingual digests using Chinese labels.
+func base(lang string) string {
+	if lang == Bilingual {
+		return "zh"
+	}
+	lang = strings.ToLower(lang)
+	if i := strings.IndexAny(lang, "-_"); i > 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
+
+// Label returns the label for key in lang, formatted with args if given.
+func Label(lang, key string, args ...any) string {
+	s, ok := catalogs[lang][key]
+	if !ok {
+		s, ok = catalogs[base(lang)][key]
+	}
+	if !ok {
+		s, ok = catalogs["en"][key]
+	}
+	if !ok {
+		return key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(s, args...)
+	}
+	return s
+}
+
+// Name returns the native name of a language, or its code if unknown.
+func Name(code string) string {
+	if l, ok := languages[base(code)]; ok {
+		return l.native
+	}
+	return code
+}
+
+// EnglishName returns the English name of a language for model prompts, or
+// its code if unknown.
+func EnglishName(code string) string {
+	if l, ok := languages[base(code)]; ok {
+		return l.english
+	}
+	return code
+}
+
+// Builtin reports whether code is a language every analysis already has:
+// English, Chinese or both. Other languages need stored translations.
+func Builtin(code string) bool {
+	return code == "en" || code == "zh" || code == Bilingual
+}
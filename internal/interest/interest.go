@@ -0,0 +1,117 @@
+// Package interest adjusts model scores to a deployment's interest profile:
+// topics to boost or penalize and must-read sources. The adjustment is
+// deterministic, so it can be recomputed for stored analyses at any time.
+package interest
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/chyiyaqing/newsbot/internal/config"
+	"github.com/chyiyaqing/newsbot/internal/store"
+)
+
+// Profile holds normalized topic weights and must-read sources. A nil
+// Profile adjusts nothing.
+type Profile struct {
+	topics        map[string]int // normalized topic -> points, negative to penalize
+	mustRead      map[string]bool
+	mustReadBoost int
+}
+
+// New builds a profile from cfg. Returns nil if cfg is empty.
+func New(cfg config.InterestsConfig) *Profile {
+	if len(cfg.Boost) == 0 && len(cfg.Penalize) == 0 && len(cfg.MustRead) == 0 {
+		return nil
+	}
+	p := &Profile{
+		topics:        make(map[string]int),
+		mustRead:      make(map[string]bool),
+		mustReadBoost: cfg.MustReadBoost,
+	}
+	for topic, points := range cfg.Boost {
+		p.topics[normalize(topic)] += points
+	}
+	for topic, points := range cfg.Penalize {
+		p.topics[normalize(topic)] -= points
+	}
+	delete(p.topics, "")
+	for _, d := range cfg.MustRead {
+		p.mustRead[strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "www."))] = true
+	}
+	return p
+}
+
+// Adjustment returns the points to add to the score of an article from
+// domain with the given category and comma-separated keywords. Every topic
+// counts once, however many keywords match it.
+func (p *Profile) Adjustment(domain, category, keywords string) int {
+	if p == nil {
+		return 0
+	}
+	terms := []string{normalize(category)}
+	for _, k := range strings.Split(keywords, ",") {
+		terms = append(terms, normalize(k))
+	}
+
+	adj := 0
+	for topic, points := range p.topics {
+		for _, term := range terms {
+			if matches(term, topic) {
+				adj += points
+				break
+			}
+		}
+	}
+	if p.mustRead[strings.ToLower(strings.TrimPrefix(domain, "www."))] {
+		adj += p.mustReadBoost
+	}
+	return adj
+}
+
+// Apply sets a's score adjustment for an article from domain and recomputes
+// its total score from the model's dimensions plus the adjustment.
+func (p *Profile) Apply(a *store.ArticleAnalysis, domain string) {
+	a.ScoreAdjustment = p.Adjustment(domain, a.Category, a.Keywords)
+	a.TotalScore = store.AdjustedTotal(a.Relevance, a.Quality, a.Timeliness, a.ScoreAdjustment)
+}
+
+// Topics returns the boosted and the penalized topics, sorted, for the
+// scoring prompt.
+func (p *Profile) Topics() (boosted, penalized []string) {
+	if p == nil {
+		return nil, nil
+	}
+	for topic, points := range p.topics {
+		switch {
+		case points > 0:
+			boosted = append(boosted, topic)
+		case points < 0:
+			penalized = append(penalized, topic)
+		}
+	}
+	sort.Strings(boosted)
+	sort.Strings(penalized)
+	return boosted, penalized
+}
+
+// normalize lower-cases s and replaces punctuation other than the symbols
+// common in technology names with spaces.
+func normalize(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("+#./", r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// matches reports whether topic occurs in term as whole words.
+func matches(term, topic string) bool {
+	if term == "" {
+		return false
+	}
+	return term == topic || strings.Contains(" "+term+" ", " "+topic+" ")
+}
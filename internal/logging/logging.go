@@ -0,0 +1,88 @@
+// Package logging configures the default slog logger and carries a pipeline
+// run ID through contexts so every line logged during a run can be found.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/chyiyaqing/newsbot/internal/config"
+)
+
+// Setup installs the default logger described by cfg, writing to stderr.
+// The standard log package is redirected to it as well.
+func Setup(cfg config.LogConfig) error {
+	h, err := newHandler(os.Stderr, cfg)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+func newHandler(w io.Writer, cfg config.LogConfig) (slog.Handler, error) {
+	var level slog.Level
+	if cfg.Level != "" {
+		if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+			return nil, fmt.Errorf("log level %q: use debug, info, warn or error", cfg.Level)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	var h slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("log format %q: use text or json", cfg.Format)
+	}
+	return runIDHandler{h}, nil
+}
+
+type runIDKey struct{}
+
+// WithRunID returns a context whose log lines carry run_id=id.
+func WithRunID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, runIDKey{}, id)
+}
+
+// RunID returns the run ID carried by ctx, or "" outside a run.
+func RunID(ctx context.Context) string {
+	id, _ := ctx.Value(runIDKey{}).(string)
+	return id
+}
+
+// NewRunID returns a short random identifier for one pipeline run.
+func NewRunID() string {
+	b := make([]byte, 4)
+	rand.Read(b) //nolint:errcheck
+	return hex.EncodeToString(b)
+}
+
+// runIDHandler adds the run ID from the record's context, if any.
+type runIDHandler struct {
+	slog.Handler
+}
+
+func (h runIDHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RunID(ctx); id != "" {
+		r.AddAttrs(slog.String("run_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h runIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return runIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h runIDHandler) WithGroup(name string) slog.Handler {
+	return runIDHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// LLMBuckets are latency buckets in seconds for model calls, which take
+// seconds to minutes on a local Ollama.
+var LLMBuckets = []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120}
+
+// Newsbot metrics.
+var (
+	FeedScrapes = NewCounterVec("newsbot_feed_scrapes_total",
+		"Blog feed scrapes by domain and result (ok, error).", "domain", "result")
+
+	LLMRequests = NewCounterVec("newsbot_llm_requests_total",
+		"LLM calls by operation and outcome (ok, error).", "operation", "outcome")
+	LLMDuration = NewHistogramVec("newsbot_llm_request_duration_seconds",
+		"LLM call latency by operation.", LLMBuckets, "operation")
+	LLMTokens = NewCounterVec("newsbot_llm_tokens_total",
+		"LLM tokens used by operation and type (prompt, completion).", "operation", "type")
+	LLMParseFailures = NewCounterVec("newsbot_llm_parse_failures_total",
+		"LLM responses that were not valid JSON, by operation.", "operation")
+	LLMCacheLookups = NewCounterVec("newsbot_llm_cache_lookups_total",
+		"LLM response cache lookups by operation and result (hit, miss).", "operation", "result")
+
+	Notifications = NewCounterVec("newsbot_notifications_total",
+		"Notifications sent by channel (telegram, email) and result (ok, error).", "channel", "result")
+	ArticleClicks = NewCounterVec("newsbot_article_clicks_total",
+		"Article link clicks by channel (web, telegram, email).", "channel")
+
+	HTTPDuration = NewHistogramVec("newsbot_http_request_duration_seconds",
+		"HTTP request latency by route, method and status code.", DefBuckets, "route", "method", "code")
+)
+
+var lastPipelineSuccess atomic.Int64 // unix seconds, 0 = never
+
+func init() {
+	NewGaugeFunc("newsbot_pipeline_last_success_timestamp_seconds",
+		"Unix time of the last successful full pipeline run.", func() (float64, bool) {
+			t := lastPipelineSuccess.Load()
+			return float64(t), t > 0
+		})
+	NewGaugeFunc("newsbot_pipeline_last_success_age_seconds",
+		"Seconds since the last successful full pipeline run.", func() (float64, bool) {
+			t := lastPipelineSuccess.Load()
+			return time.Since(time.Unix(t, 0)).Seconds(), t > 0
+		})
+}
+
+// PipelineSucceeded records the completion of a full pipeline run.
+func PipelineSucceeded(at time.Time) {
+	lastPipelineSuccess.Store(at.Unix())
+}
+
+// Result maps an error to the "ok"/"error" result label.
+func Result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
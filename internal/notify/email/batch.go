@@ -0,0 +1,157 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chyiyaqing/newsbot/internal/metrics"
+)
+
+// Outgoing is a single message of a batch.
+type Outgoing struct {
+	To               string
+	Subject          string
+	HTML             string
+	Text             string // plain-text part; generated from HTML when empty
+	UnsubscribeToken string // adds List-Unsubscribe headers when set
+}
+
+// Result is the delivery outcome for one recipient of a batch.
+type Result struct {
+	To     string
+	Err    error
+	SentAt time.Time
+}
+
+// BatchOptions tunes SendBatch.
+type BatchOptions struct {
+	// Concurrency is the number of SMTP sessions kept open in parallel (default 3).
+	Concurrency int
+	// PerMinute caps messages sent per minute across all sessions (0 = no cap).
+	PerMinute int
+	// MessagesPerSession reconnects after this many messages (default 100),
+	// staying below per-connection limits of providers such as Gmail.
+	MessagesPerSession int
+}
+
+// SendBatch delivers msgs over a small pool of authenticated SMTP sessions,
+// reusing each connection for many messages. It returns one Result per
+// message, in the same order as msgs. Messages not attempted because ctx was
+// cancelled carry ctx.Err().
+func (c *Client) SendBatch(ctx context.Context, msgs []Outgoing, opts BatchOptions) []Result {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 3
+	}
+	if opts.Concurrency > len(msgs) {
+		opts.Concurrency = len(msgs)
+	}
+	if opts.MessagesPerSession <= 0 {
+		opts.MessagesPerSession = 100
+	}
+
+	results := make([]Result, len(msgs))
+	for i, m := range msgs {
+		results[i] = Result{To: m.To}
+	}
+
+	var throttle <-chan time.Time
+	if opts.PerMinute > 0 {
+		ticker := time.NewTicker(time.Minute / time.Duration(opts.PerMinute))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.batchWorker(jobs, msgs, results, opts.MessagesPerSession)
+		}()
+	}
+
+	first := true
+feed:
+	for i := range msgs {
+		// The first message goes out immediately; later ones wait for the throttle.
+		if throttle != nil && !first {
+			select {
+			case <-ctx.Done():
+				break feed
+			case <-throttle:
+			}
+		}
+		first = false
+
+		select {
+		case <-ctx.Done():
+			break feed
+		case jobs <- i:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		for i := range results {
+			if results[i].Err == nil && results[i].SentAt.IsZero() {
+				results[i].Err = err
+			}
+		}
+	}
+	for _, r := range results {
+		metrics.Notifications.Inc("email", metrics.Result(r.Err))
+	}
+	return results
+}
+
+// batchWorker sends the messages it receives over one SMTP session, dialing
+// lazily and reconnecting when the session breaks or reaches maxPerSession.
+func (c *Client) batchWorker(jobs <-chan int, msgs []Outgoing, results []Result, maxPerSession int) {
+	var sess *session
+	sent := 0
+	defer func() {
+		if sess != nil {
+			sess.quit()
+		}
+	}()
+
+	from := extractAddress(c.from)
+	for i := range jobs {
+		m := msgs[i]
+		raw, err := buildMessage(c.from, m.To, m.Subject, m.HTML, m.Text, c.unsubscribeURL(m.UnsubscribeToken))
+		if err != nil {
+			results[i].Err = fmt.Errorf("build message: %w", err)
+			continue
+		}
+
+		if sess != nil && sent >= maxPerSession {
+			sess.quit()
+			sess = nil
+		}
+		if sess == nil {
+			if sess, err = c.dial(); err != nil {
+				results[i].Err = err
+				continue
+			}
+			sent = 0
+		}
+
+		err = sess.send(from, m.To, raw)
+		var se *sessionError
+		if errors.As(err, &se) {
+			sess.client.Close()
+			sess = nil
+		}
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		sent++
+		results[i].SentAt = time.Now()
+	}
+}
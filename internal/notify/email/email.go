@@ -2,15 +2,14 @@ package email
 
 import (
 	"crypto/rand"
-	"crypto/tls"
 	"encoding/base64"
 	"fmt"
+	"html"
 	"net/mail"
-	"net/smtp"
+	"net/url"
 	"strings"
 
-	"github.com/chyiyaqing/newsbot/internal/ai"
-	"github.com/chyiyaqing/newsbot/internal/store"
+	"github.com/chyiyaqing/newsbot/internal/metrics"
 )
 
 // Client sends HTML emails via SMTP.
@@ -43,83 +42,60 @@ func GenerateToken() (string, error) {
 	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
-// SendHTML sends an HTML email to a single recipient.
+// SendHTML sends an HTML email, with a generated plain-text alternative, to a
+// single recipient.
 func (c *Client) SendHTML(to, subject, htmlBody string) error {
-	msg := buildMessage(c.from, to, subject, htmlBody)
-	addr := fmt.Sprintf("%s:%d", c.host, c.port)
-	fromAddr := extractAddress(c.from)
-
-	if c.port == 465 {
-		return c.sendTLS(addr, fromAddr, to, msg)
-	}
+	return c.send(to, subject, htmlBody, "", "")
+}
 
-	var auth smtp.Auth
-	if c.username != "" {
-		auth = smtp.PlainAuth("", c.username, c.password, c.host)
-	}
-	return smtp.SendMail(addr, auth, fromAddr, []string{to}, msg)
+// SendDigest is like SendHTML but uses textBody as the plain-text part (when
+// set) and adds RFC 8058 one-click List-Unsubscribe headers for the
+// subscriber owning unsubscribeToken.
+func (c *Client) SendDigest(to, subject, htmlBody, textBody, unsubscribeToken string) error {
+	return c.send(to, subject, htmlBody, textBody, c.unsubscribeURL(unsubscribeToken))
 }
 
-// extractAddress returns just the email address from a "Name <addr>" string.
-func extractAddress(from string) string {
-	if addr, err := mail.ParseAddress(from); err == nil {
-		return addr.Address
-	}
-	return from
+func (c *Client) send(to, subject, htmlBody, textBody, unsubURL string) error {
+	err := c.sendOne(to, subject, htmlBody, textBody, unsubURL)
+	metrics.Notifications.Inc("email", metrics.Result(err))
+	return err
 }
 
-func (c *Client) sendTLS(addr, from, to string, msg []byte) error {
-	conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: c.host})
+func (c *Client) sendOne(to, subject, htmlBody, textBody, unsubURL string) error {
+	msg, err := buildMessage(c.from, to, subject, htmlBody, textBody, unsubURL)
 	if err != nil {
-		return fmt.Errorf("tls dial: %w", err)
+		return fmt.Errorf("build message: %w", err)
 	}
-	client, err := smtp.NewClient(conn, c.host)
-	if err != nil {
-		return fmt.Errorf("smtp client: %w", err)
-	}
-	defer client.Quit() //nolint:errcheck
 
-	if c.username != "" {
-		auth := smtp.PlainAuth("", c.username, c.password, c.host)
-		if err := client.Auth(auth); err != nil {
-			return fmt.Errorf("smtp auth: %w", err)
-		}
-	}
-	if err := client.Mail(from); err != nil {
-		return err
-	}
-	if err := client.Rcpt(to); err != nil {
-		return err
-	}
-	w, err := client.Data()
+	sess, err := c.dial()
 	if err != nil {
 		return err
 	}
-	if _, err := w.Write(msg); err != nil {
-		return err
+	defer sess.quit()
+	return sess.send(extractAddress(c.from), to, msg)
+}
+
+// unsubscribeURL returns the unsubscribe link for a token, or "" if the site
+// URL or token is not set.
+func (c *Client) unsubscribeURL(token string) string {
+	if c.siteURL == "" || token == "" {
+		return ""
 	}
-	return w.Close()
+	return strings.TrimRight(c.siteURL, "/") + "/api/unsubscribe?token=" + url.QueryEscape(token)
 }
 
-func buildMessage(from, to, subject, htmlBody string) []byte {
-	var sb strings.Builder
-	sb.WriteString("From: " + from + "\r\n")
-	sb.WriteString("To: " + to + "\r\n")
-	sb.WriteString("Subject: " + subject + "\r\n")
-	sb.WriteString("MIME-Version: 1.0\r\n")
-	sb.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
-	sb.WriteString("\r\n")
-	sb.WriteString(htmlBody)
-	return []byte(sb.String())
+// extractAddress returns just the email address from a "Name <addr>" string.
+func extractAddress(from string) string {
+	if addr, err := mail.ParseAddress(from); err == nil {
+		return addr.Address
+	}
+	return from
 }
 
-// SendWelcome sends a welcome / subscription-confirmation email.
+// SendWelcome sends a welcome email once a subscription is confirmed.
 func (c *Client) SendWelcome(to, token string) error {
 	subject := "欢迎订阅 NewsBot 技术资讯"
-	var unsubURL string
-	if c.siteURL != "" && token != "" {
-		unsubURL = strings.TrimRight(c.siteURL, "/") + "/api/unsubscribe?token=" + token
-	}
+	unsubURL := c.unsubscribeURL(token)
 
 	var sb strings.Builder
 	sb.WriteString(`<!DOCTYPE html><html><head><meta charset="UTF-8"></head>`)
@@ -129,95 +105,34 @@ func (c *Client) SendWelcome(to, token string) error {
 	if unsubURL != "" {
 		sb.WriteString(fmt.Sprintf(
 			`<p style="margin-top:32px;font-size:11px;color:#94a3b8;border-top:1px solid #e2e8f0;padding-top:16px">不想再收到邮件？<a href="%s" style="color:#94a3b8">取消订阅</a></p>`,
-			escapeAttr(unsubURL),
+			html.EscapeString(unsubURL),
 		))
 	}
 	sb.WriteString(`</body></html>`)
 
-	return c.SendHTML(to, subject, sb.String())
+	return c.send(to, subject, sb.String(), "", unsubURL)
 }
 
-// FormatEmailReport builds an HTML email body from analyzed articles and trends.
-func FormatEmailReport(articles []store.ArticleWithAnalysis, trends *ai.TrendReport, window, unsubscribeToken, siteURL string) string {
-	var sb strings.Builder
-
-	sb.WriteString(`<!DOCTYPE html><html><head><meta charset="UTF-8"></head>`)
-	sb.WriteString(`<body style="font-family:-apple-system,BlinkMacSystemFont,'Segoe UI',sans-serif;max-width:640px;margin:0 auto;padding:20px;color:#0f172a;line-height:1.6">`)
-
-	sb.WriteString(fmt.Sprintf(`<h1 style="font-size:20px;margin-bottom:4px">NewsBot — %d 篇新文章</h1>`, len(articles)))
-	sb.WriteString(fmt.Sprintf(`<p style="color:#94a3b8;font-size:13px;margin:0 0 24px">过去 %s 的技术动态</p>`, windowLabel(window)))
-
-	limit := 20
-	if len(articles) < limit {
-		limit = len(articles)
-	}
-
-	if limit > 0 {
-		sb.WriteString(`<h2 style="font-size:15px;font-weight:600;border-bottom:1px solid #e2e8f0;padding-bottom:8px;margin-bottom:16px">Top Articles</h2>`)
-		sb.WriteString(`<ol style="padding-left:20px;margin:0">`)
-		for i := 0; i < limit; i++ {
-			a := articles[i]
-			sb.WriteString(`<li style="margin-bottom:16px">`)
-			sb.WriteString(fmt.Sprintf(`<a href="%s" style="font-size:15px;font-weight:600;color:#0f172a;text-decoration:none">%s</a>`,
-				escapeAttr(a.Article.URL), escapeHTML(a.Article.Title)))
-			if a.ArticleAnalysis.TitleCN != "" {
-				sb.WriteString(fmt.Sprintf(`<br><span style="font-size:13px;color:#475569">%s</span>`, escapeHTML(a.ArticleAnalysis.TitleCN)))
-			}
-			if a.ArticleAnalysis.RecommendReason != "" {
-				sb.WriteString(fmt.Sprintf(`<br><span style="font-size:12px;color:#64748b">%s</span>`, escapeHTML(a.ArticleAnalysis.RecommendReason)))
-			}
-			sb.WriteString(fmt.Sprintf(`<br><span style="font-size:11px;color:#94a3b8">评分: %d | %s | %s</span>`,
-				a.ArticleAnalysis.TotalScore, escapeHTML(a.ArticleAnalysis.Category), escapeHTML(a.Article.BlogDomain)))
-			sb.WriteString(`</li>`)
-		}
-		sb.WriteString(`</ol>`)
-	}
-
-	if trends != nil && len(trends.Trends) > 0 {
-		sb.WriteString(`<h2 style="font-size:15px;font-weight:600;border-bottom:1px solid #e2e8f0;padding-bottom:8px;margin:28px 0 16px">技术趋势</h2>`)
-		sb.WriteString(`<ol style="padding-left:20px;margin:0">`)
-		for _, t := range trends.Trends {
-			sb.WriteString(`<li style="margin-bottom:12px">`)
-			sb.WriteString(fmt.Sprintf(`<strong>%s</strong><br>`, escapeHTML(t.Title)))
-			sb.WriteString(fmt.Sprintf(`<span style="font-size:13px;color:#475569">%s</span>`, escapeHTML(t.Description)))
-			sb.WriteString(`</li>`)
-		}
-		sb.WriteString(`</ol>`)
-	}
-
-	if unsubscribeToken != "" && siteURL != "" {
-		unsubURL := strings.TrimRight(siteURL, "/") + "/api/unsubscribe?token=" + unsubscribeToken
-		sb.WriteString(fmt.Sprintf(
-			`<p style="margin-top:32px;font-size:11px;color:#94a3b8;border-top:1px solid #e2e8f0;padding-top:16px">不想再收到邮件？<a href="%s" style="color:#94a3b8">取消订阅</a></p>`,
-			escapeAttr(unsubURL),
-		))
+// SendConfirmation sends a double opt-in email with a link that confirms the
+// subscription. confirmToken must be a signed token accepted by /api/confirm.
+func (c *Client) SendConfirmation(to, confirmToken string) error {
+	if c.siteURL == "" {
+		return fmt.Errorf("site URL not configured, cannot build confirmation link")
 	}
+	subject := "请确认订阅 NewsBot 技术资讯"
+	confirmURL := strings.TrimRight(c.siteURL, "/") + "/api/confirm?token=" + url.QueryEscape(confirmToken)
 
+	var sb strings.Builder
+	sb.WriteString(`<!DOCTYPE html><html><head><meta charset="UTF-8"></head>`)
+	sb.WriteString(`<body style="font-family:-apple-system,BlinkMacSystemFont,'Segoe UI',sans-serif;max-width:640px;margin:0 auto;padding:40px 20px;color:#0f172a;line-height:1.6">`)
+	sb.WriteString(`<h1 style="font-size:20px;margin-bottom:8px">请确认您的订阅</h1>`)
+	sb.WriteString(`<p style="color:#475569">我们收到了使用此邮箱订阅 NewsBot 技术资讯的请求。点击下方按钮完成订阅：</p>`)
+	sb.WriteString(fmt.Sprintf(
+		`<p style="margin:24px 0"><a href="%s" style="background:#0f172a;color:#fff;padding:10px 20px;border-radius:6px;text-decoration:none">确认订阅</a></p>`,
+		html.EscapeString(confirmURL),
+	))
+	sb.WriteString(`<p style="font-size:12px;color:#94a3b8">如果这不是您本人的操作，请忽略此邮件，您不会收到任何后续邮件。</p>`)
 	sb.WriteString(`</body></html>`)
-	return sb.String()
-}
 
-func windowLabel(w string) string {
-	switch w {
-	case "24h":
-		return "24小时"
-	case "3days":
-		return "3天"
-	case "7days":
-		return "7天"
-	}
-	return w
-}
-
-func escapeHTML(s string) string {
-	s = strings.ReplaceAll(s, "&", "&amp;")
-	s = strings.ReplaceAll(s, "<", "&lt;")
-	s = strings.ReplaceAll(s, ">", "&gt;")
-	return s
-}
-
-func escapeAttr(s string) string {
-	s = escapeHTML(s)
-	s = strings.ReplaceAll(s, `"`, "&quot;")
-	return s
+	return c.SendHTML(to, subject, sb.String())
 }
@@ -0,0 +1,189 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// buildMessage assembles a multipart/alternative message. The plain-text
+// part is textBody, or is generated from htmlBody when textBody is empty.
+// When unsubURL is set, RFC 8058 one-click List-Unsubscribe headers pointing
+// at it are added.
+func buildMessage(from, to, subject, htmlBody, textBody, unsubURL string) ([]byte, error) {
+	if textBody == "" {
+		textBody = htmlToText(htmlBody)
+	}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if err := writePart(mw, "text/plain; charset=UTF-8", textBody); err != nil {
+		return nil, err
+	}
+	if err := writePart(mw, "text/html; charset=UTF-8", htmlBody); err != nil {
+		return nil, err
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("From: " + encodeAddress(from) + "\r\n")
+	sb.WriteString("To: " + encodeAddress(to) + "\r\n")
+	sb.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", subject) + "\r\n")
+	sb.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	sb.WriteString("Message-ID: " + newMessageID(from) + "\r\n")
+	if unsubURL != "" {
+		sb.WriteString("List-Unsubscribe: <" + unsubURL + ">\r\n")
+		sb.WriteString("List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+	}
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: multipart/alternative; boundary=\"" + mw.Boundary() + "\"\r\n")
+	sb.WriteString("\r\n")
+	sb.Write(body.Bytes())
+	return []byte(sb.String()), nil
+}
+
+func writePart(mw *multipart.Writer, contentType, content string) error {
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Transfer-Encoding", "quoted-printable")
+	pw, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	qw := quotedprintable.NewWriter(pw)
+	if _, err := io.WriteString(qw, content); err != nil {
+		return err
+	}
+	return qw.Close()
+}
+
+// encodeAddress RFC 2047-encodes the display name of a "Name <addr>" string.
+func encodeAddress(s string) string {
+	addr, err := mail.ParseAddress(s)
+	if err != nil {
+		return s
+	}
+	return addr.String()
+}
+
+// newMessageID returns a unique Message-ID in the sender's domain.
+func newMessageID(from string) string {
+	domain := "newsbot.local"
+	if _, d, ok := strings.Cut(extractAddress(from), "@"); ok && d != "" {
+		domain = d
+	}
+	b := make([]byte, 16)
+	rand.Read(b) //nolint:errcheck
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().Unix(), hex.EncodeToString(b), domain)
+}
+
+// htmlToText renders an HTML email body as readable plain text: block
+// elements become line breaks, list items get a bullet, and links are
+// followed by their URL.
+func htmlToText(s string) string {
+	var sb strings.Builder
+	var href string
+	skip := 0
+
+	z := html.NewTokenizer(strings.NewReader(s))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return tidyText(sb.String())
+
+		case html.TextToken:
+			if skip == 0 {
+				sb.WriteString(collapseSpace(string(z.Text())))
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "head", "style", "script":
+				skip++
+			case "br":
+				sb.WriteString("\n")
+			case "p", "div", "h1", "h2", "h3", "ol", "ul":
+				sb.WriteString("\n\n")
+			case "li":
+				sb.WriteString("\n- ")
+			case "a":
+				href = ""
+				for hasAttr {
+					var key, val []byte
+					key, val, hasAttr = z.TagAttr()
+					if string(key) == "href" {
+						href = string(val)
+					}
+				}
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "head", "style", "script":
+				if skip > 0 {
+					skip--
+				}
+			case "p", "div", "h1", "h2", "h3", "ol", "ul":
+				sb.WriteString("\n\n")
+			case "a":
+				if href != "" && !strings.HasPrefix(href, "#") {
+					sb.WriteString(" (" + href + ")")
+				}
+				href = ""
+			}
+		}
+	}
+}
+
+// collapseSpace squeezes whitespace runs to a single space, keeping one
+// leading/trailing space so adjacent inline elements stay separated.
+func collapseSpace(s string) string {
+	collapsed := strings.Join(strings.Fields(s), " ")
+	if collapsed == "" {
+		if s != "" {
+			return " "
+		}
+		return ""
+	}
+	if strings.TrimLeft(s, " \t\r\n") != s {
+		collapsed = " " + collapsed
+	}
+	if strings.TrimRight(s, " \t\r\n") != s {
+		collapsed += " "
+	}
+	return collapsed
+}
+
+// tidyText trims each line and collapses runs of blank lines.
+func tidyText(s string) string {
+	var out []string
+	blank := false
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		out = append(out, line)
+		blank = false
+	}
+	return strings.TrimSpace(strings.Join(out, "\r\n")) + "\r\n"
+}
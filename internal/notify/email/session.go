@@ -0,0 +1,121 @@
+package email
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/smtp"
+	"net/textproto"
+)
+
+// session is an authenticated SMTP connection that can deliver several
+// messages in a row.
+type session struct {
+	client *smtp.Client
+}
+
+// dial opens an SMTP connection, upgrades it to TLS (implicit TLS on port
+// 465, STARTTLS otherwise when offered) and authenticates if a username is set.
+func (c *Client) dial() (*session, error) {
+	addr := fmt.Sprintf("%s:%d", c.host, c.port)
+	tlsConfig := &tls.Config{ServerName: c.host}
+
+	var client *smtp.Client
+	if c.port == 465 {
+		conn, err := tls.Dial("tcp", addr, tlsConfig)
+		if err != nil {
+			return nil, fmt.Errorf("tls dial: %w", err)
+		}
+		client, err = smtp.NewClient(conn, c.host)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("smtp client: %w", err)
+		}
+	} else {
+		var err error
+		client, err = smtp.Dial(addr)
+		if err != nil {
+			return nil, fmt.Errorf("smtp dial: %w", err)
+		}
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				client.Close()
+				return nil, fmt.Errorf("starttls: %w", err)
+			}
+		}
+	}
+
+	if c.username != "" {
+		auth := smtp.PlainAuth("", c.username, c.password, c.host)
+		if err := client.Auth(auth); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	return &session{client: client}, nil
+}
+
+// send delivers one message. After a failed transaction the session is reset
+// so it can be reused; if the reset fails the session should be discarded.
+func (s *session) send(from, to string, msg []byte) error {
+	if err := s.transaction(from, to, msg); err != nil {
+		if rerr := s.client.Reset(); rerr != nil {
+			return &sessionError{err: err}
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *session) transaction(from, to string, msg []byte) error {
+	if err := s.client.Mail(from); err != nil {
+		return fmt.Errorf("mail from: %w", err)
+	}
+	if err := s.client.Rcpt(to); err != nil {
+		return &recipientError{err: err}
+	}
+	w, err := s.client.Data()
+	if err != nil {
+		return fmt.Errorf("data: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("write body: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("end data: %w", err)
+	}
+	return nil
+}
+
+func (s *session) quit() {
+	if err := s.client.Quit(); err != nil {
+		s.client.Close()
+	}
+}
+
+// sessionError marks a send failure that left the connection unusable.
+type sessionError struct {
+	err error
+}
+
+func (e *sessionError) Error() string { return e.err.Error() }
+func (e *sessionError) Unwrap() error { return e.err }
+
+// recipientError is a rejection of the recipient address at RCPT TO.
+type recipientError struct {
+	err error
+}
+
+func (e *recipientError) Error() string { return "rcpt to: " + e.err.Error() }
+func (e *recipientError) Unwrap() error { return e.err }
+
+// IsPermanentFailure reports whether err is a permanent (5xx) rejection of
+// the recipient address, so retrying the same address is pointless.
+func IsPermanentFailure(err error) bool {
+	var re *recipientError
+	if !errors.As(err, &re) {
+		return false
+	}
+	var te *textproto.Error
+	return errors.As(re.err, &te) && te.Code >= 500 && te.Code < 600
+}
@@ -0,0 +1,171 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chyiyaqing/newsbot/internal/store"
+)
+
+// InlineKeyboardMarkup is an inline keyboard attached to a message.
+type InlineKeyboardMarkup struct {
+	InlineKeyboard [][]InlineKeyboardButton `json:"inline_keyboard"`
+}
+
+// InlineKeyboardButton is a single button of an inline keyboard.
+type InlineKeyboardButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data"`
+}
+
+// Update is an incoming update from the Bot API. Only callback queries are used.
+type Update struct {
+	UpdateID      int64          `json:"update_id"`
+	CallbackQuery *CallbackQuery `json:"callback_query,omitempty"`
+}
+
+// CallbackQuery is sent when a user presses an inline keyboard button.
+type CallbackQuery struct {
+	ID   string `json:"id"`
+	Data string `json:"data"`
+	From struct {
+		ID int64 `json:"id"`
+	} `json:"from"`
+}
+
+const callbackPrefix = "fb"
+
+// feedbackButtons returns the keyboard row for the n-th article of a digest.
+func feedbackButtons(n int, articleID int64) []InlineKeyboardButton {
+	btn := func(label, action string) InlineKeyboardButton {
+		return InlineKeyboardButton{
+			Text:         fmt.Sprintf("%d %s", n, label),
+			CallbackData: fmt.Sprintf("%s:%s:%d", callbackPrefix, action, articleID),
+		}
+	}
+	return []InlineKeyboardButton{
+		btn("👍", store.FeedbackUp),
+		btn("👎", store.FeedbackDown),
+		btn("➕", store.FeedbackMore),
+		btn("🔇", store.FeedbackMute),
+	}
+}
+
+// parseCallbackData decodes "fb:<action>:<article-id>".
+func parseCallbackData(data string) (action string, articleID int64, ok bool) {
+	parts := strings.Split(data, ":")
+	if len(parts) != 3 || parts[0] != callbackPrefix || !store.ValidFeedbackAction(parts[1]) {
+		return "", 0, false
+	}
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || id <= 0 {
+		return "", 0, false
+	}
+	return parts[1], id, true
+}
+
+var feedbackReplies = map[string]string{
+	store.FeedbackUp:   "👍 已记录，感谢反馈",
+	store.FeedbackDown: "👎 已记录，感谢反馈",
+	store.FeedbackMore: "➕ 将推荐更多类似文章",
+	store.FeedbackMute: "🔇 已记录，将减少推荐该来源（屏蔽来源需管理员操作）",
+}
+
+// HandleUpdate stores the feedback carried by a callback query and
+// acknowledges the button press. A 🔇 press from an admin also mutes the
+// article's source; from anyone else it only counts against the source in
+// ranking, and the reply says so. Updates without a callback query are
+// ignored.
+func (c *Client) HandleUpdate(ctx context.Context, db *store.Store, u Update) error {
+	cq := u.CallbackQuery
+	if cq == nil {
+		return nil
+	}
+
+	action, articleID, ok := parseCallbackData(cq.Data)
+	if !ok {
+		return c.answerCallback(ctx, cq.ID, "未知操作")
+	}
+
+	article, err := db.GetArticle(articleID)
+	if err != nil {
+		return fmt.Errorf("get article %d: %w", articleID, err)
+	}
+	if article == nil {
+		return c.answerCallback(ctx, cq.ID, "文章不存在")
+	}
+
+	userRef := strconv.FormatInt(cq.From.ID, 10)
+	if err := db.SaveFeedback(store.Feedback{
+		ArticleID: articleID,
+		Action:    action,
+		Channel:   "telegram",
+		UserRef:   userRef,
+	}); err != nil {
+		return fmt.Errorf("save feedback: %w", err)
+	}
+	if action == store.FeedbackMute && slices.Contains(c.admins, userRef) {
+		if err := db.MuteBlog(article.BlogDomain); err != nil {
+			return fmt.Errorf("mute %s: %w", article.BlogDomain, err)
+		}
+		slog.InfoContext(ctx, "muted source via feedback", "channel", "telegram", "domain", article.BlogDomain, "user", userRef)
+		return c.answerCallback(ctx, cq.ID, "🔇 已屏蔽该来源")
+	}
+
+	return c.answerCallback(ctx, cq.ID, feedbackReplies[action])
+}
+
+func (c *Client) answerCallback(ctx context.Context, callbackID, text string) error {
+	return c.call(ctx, "answerCallbackQuery", map[string]string{
+		"callback_query_id": callbackID,
+		"text":              text,
+	}, nil)
+}
+
+// Poll receives callback queries with long polling and handles them until
+// ctx is cancelled. It must not be used while a webhook is registered.
+func (c *Client) Poll(ctx context.Context, db *store.Store) {
+	var offset int64
+	for ctx.Err() == nil {
+		var updates []Update
+		err := c.call(ctx, "getUpdates", map[string]any{
+			"offset":          offset,
+			"timeout":         30,
+			"allowed_updates": []string{"callback_query"},
+		}, &updates)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.WarnContext(ctx, "telegram getUpdates", "channel", "telegram", "err", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if err := c.HandleUpdate(ctx, db, u); err != nil {
+				slog.WarnContext(ctx, "telegram update", "channel", "telegram", "update_id", u.UpdateID, "err", err)
+			}
+		}
+	}
+}
+
+// SetWebhook registers url as the webhook for callback queries. Telegram
+// echoes secret in the X-Telegram-Bot-Api-Secret-Token header of each request.
+func (c *Client) SetWebhook(ctx context.Context, url, secret string) error {
+	return c.call(ctx, "setWebhook", map[string]any{
+		"url":             url,
+		"secret_token":    secret,
+		"allowed_updates": []string{"callback_query"},
+	}, nil)
+}
@@ -1,58 +1,66 @@
 package telegram
 
 import (
-	"fmt"
 	"strings"
 
-	"github.com/chyiyaqing/newsbot/internal/ai"
-	"github.com/chyiyaqing/newsbot/internal/store"
+	"github.com/chyiyaqing/newsbot/internal/render"
 )
 
-// FormatReport builds an HTML-formatted Telegram message from analyzed articles and trends.
-func FormatReport(articles []store.ArticleWithAnalysis, trends *ai.TrendReport, window string) string {
-	var sb strings.Builder
-
-	sb.WriteString(fmt.Sprintf("<b>📡 Newsbot (%d new articles)</b>\n\n", len(articles)))
+// Message is a single Telegram message with an optional inline keyboard.
+type Message struct {
+	Text     string
+	Keyboard *InlineKeyboardMarkup
+}
 
-	// Top articles
-	limit := 20
-	if len(articles) < limit {
-		limit = len(articles)
+// FormatReportMessages renders a digest with r and packs it into messages
+// that fit Telegram's length limit. When feedback is true every article
+// carries a row of feedback buttons attached to the message it appears in.
+func FormatReportMessages(r *render.Renderer, d render.Digest, feedback bool) ([]Message, error) {
+	parts, err := r.TelegramParts(d)
+	if err != nil {
+		return nil, err
 	}
 
-	if limit > 0 {
-		sb.WriteString("<b>Top Articles</b>\n\n")
-	}
+	var msgs []Message
+	var sb strings.Builder
+	var rows [][]InlineKeyboardButton
 
-	for i := 0; i < limit; i++ {
-		a := articles[i]
-		sb.WriteString(fmt.Sprintf("<b>%d.</b> [%d | %s] %s\n",
-			i+1,
-			a.ArticleAnalysis.TotalScore,
-			escapeHTML(a.ArticleAnalysis.Category),
-			escapeHTML(a.Article.Title)))
+	flush := func() {
+		if sb.Len() == 0 {
+			return
+		}
+		m := Message{Text: sb.String()}
+		if len(rows) > 0 {
+			m.Keyboard = &InlineKeyboardMarkup{InlineKeyboard: rows}
+		}
+		msgs = append(msgs, m)
+		sb.Reset()
+		rows = nil
+	}
 
-		if a.ArticleAnalysis.TitleCN != "" {
-			sb.WriteString(fmt.Sprintf("   中文: %s\n", escapeHTML(a.ArticleAnalysis.TitleCN)))
+	sb.WriteString(parts.Header)
+	for i, block := range parts.Articles {
+		if sb.Len()+len(block) > maxMessageLen {
+			flush()
 		}
-		if a.ArticleAnalysis.RecommendReason != "" {
-			sb.WriteString(fmt.Sprintf("   推荐: %s\n", escapeHTML(a.ArticleAnalysis.RecommendReason)))
+		sb.WriteString(block)
+		if feedback {
+			a := d.Articles[i]
+			rows = append(rows, feedbackButtons(a.N, a.ID))
 		}
-		sb.WriteString(fmt.Sprintf("   🔗 %s\n\n", a.Article.URL))
 	}
 
-	// Trends
-	if trends != nil && len(trends.Trends) > 0 {
-		sb.WriteString("<b>技术趋势</b>\n\n")
-		for i, t := range trends.Trends {
-			sb.WriteString(fmt.Sprintf("<b>%d. %s</b>\n", i+1, escapeHTML(t.Title)))
-			sb.WriteString(fmt.Sprintf("   %s\n", escapeHTML(t.Description)))
-			if len(t.Articles) > 0 {
-				sb.WriteString(fmt.Sprintf("   相关: %s\n", escapeHTML(strings.Join(t.Articles, "; "))))
+	if t := parts.Trends; t != "" {
+		if sb.Len()+len(t) > maxMessageLen {
+			flush()
+			for _, chunk := range splitMessage(t, maxMessageLen) {
+				msgs = append(msgs, Message{Text: chunk})
 			}
-			sb.WriteString("\n")
+		} else {
+			sb.WriteString(t)
 		}
 	}
+	flush()
 
-	return sb.String()
+	return msgs, nil
 }
@@ -5,39 +5,56 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"html"
 	"io"
 	"net/http"
 	"strings"
+
+	"github.com/chyiyaqing/newsbot/internal/metrics"
+	"github.com/chyiyaqing/newsbot/internal/render"
 )
 
 // Client sends messages via the Telegram Bot API.
 type Client struct {
 	botToken   string
 	chatID     string
+	feedback   bool
+	admins     []string
 	httpClient *http.Client
 }
 
 // New creates a Telegram notifier. Returns nil if token or chatID is empty.
-func New(botToken, chatID string) *Client {
+// When feedback is true, digests carry inline feedback buttons.
+func New(botToken, chatID string, feedback bool) *Client {
 	if botToken == "" || chatID == "" {
 		return nil
 	}
 	return &Client{
 		botToken:   botToken,
 		chatID:     chatID,
+		feedback:   feedback,
 		httpClient: &http.Client{},
 	}
 }
 
+// WithAdmins lets the given Telegram user IDs mute sources with the 🔇
+// button.
+func (c *Client) WithAdmins(ids []string) *Client {
+	c.admins = ids
+	return c
+}
+
 type sendMessageRequest struct {
-	ChatID    string `json:"chat_id"`
-	Text      string `json:"text"`
-	ParseMode string `json:"parse_mode"`
+	ChatID      string                `json:"chat_id"`
+	Text        string                `json:"text"`
+	ParseMode   string                `json:"parse_mode"`
+	ReplyMarkup *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
 }
 
 type apiResponse struct {
-	OK          bool   `json:"ok"`
-	Description string `json:"description"`
+	OK          bool            `json:"ok"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
 }
 
 const maxMessageLen = 4096
@@ -47,26 +64,49 @@ const maxMessageLen = 4096
 func (c *Client) Send(ctx context.Context, title, body string) error {
 	text := body
 	if title != "" {
-		text = "<b>" + escapeHTML(title) + "</b>\n\n" + body
+		text = "<b>" + html.EscapeString(title) + "</b>\n\n" + body
 	}
 
 	chunks := splitMessage(text, maxMessageLen)
 	for _, chunk := range chunks {
-		if err := c.sendRaw(ctx, chunk); err != nil {
+		if err := c.sendRaw(ctx, chunk, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendReport renders a digest with r and sends it. When feedback is enabled
+// every article carries a row of inline feedback buttons.
+func (c *Client) SendReport(ctx context.Context, r *render.Renderer, d render.Digest) error {
+	msgs, err := FormatReportMessages(r, d, c.feedback)
+	if err != nil {
+		return err
+	}
+	for _, m := range msgs {
+		if err := c.sendRaw(ctx, m.Text, m.Keyboard); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (c *Client) sendRaw(ctx context.Context, text string) error {
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", c.botToken)
+func (c *Client) sendRaw(ctx context.Context, text string, keyboard *InlineKeyboardMarkup) error {
+	err := c.call(ctx, "sendMessage", sendMessageRequest{
+		ChatID:      c.chatID,
+		Text:        text,
+		ParseMode:   "HTML",
+		ReplyMarkup: keyboard,
+	}, nil)
+	metrics.Notifications.Inc("telegram", metrics.Result(err))
+	return err
+}
+
+// call invokes a Bot API method and decodes its result into out (if non-nil).
+func (c *Client) call(ctx context.Context, method string, params, out any) error {
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/%s", c.botToken, method)
 
-	payload, err := json.Marshal(sendMessageRequest{
-		ChatID:    c.chatID,
-		Text:      text,
-		ParseMode: "HTML",
-	})
+	payload, err := json.Marshal(params)
 	if err != nil {
 		return err
 	}
@@ -79,17 +119,22 @@ func (c *Client) sendRaw(ctx context.Context, text string) error {
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("telegram send: %w", err)
+		return fmt.Errorf("telegram %s: %w", method, err)
 	}
 	defer resp.Body.Close()
 
 	respBody, _ := io.ReadAll(resp.Body)
 
+	var apiResp apiResponse
+	json.Unmarshal(respBody, &apiResp)
 	if resp.StatusCode != http.StatusOK {
-		var apiResp apiResponse
-		json.Unmarshal(respBody, &apiResp)
 		return fmt.Errorf("telegram API %d: %s", resp.StatusCode, apiResp.Description)
 	}
+	if out != nil && len(apiResp.Result) > 0 {
+		if err := json.Unmarshal(apiResp.Result, out); err != nil {
+			return fmt.Errorf("telegram %s: decode result: %w", method, err)
+		}
+	}
 	return nil
 }
 
@@ -120,10 +165,3 @@ func splitMessage(text string, maxLen int) []string {
 	}
 	return chunks
 }
-
-func escapeHTML(s string) string {
-	s = strings.ReplaceAll(s, "&", "&amp;")
-	s = strings.ReplaceAll(s, "<", "&lt;")
-	s = strings.ReplaceAll(s, ">", "&gt;")
-	return s
-}
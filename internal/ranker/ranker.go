@@ -0,0 +1,298 @@
+// Package ranker learns how to order digest articles from reader feedback.
+// A logistic regression over the model's score dimensions, category, source,
+// keywords and length is trained offline on articles readers voted on,
+// clicked or ignored, and its predicted probability of a positive reaction
+// replaces the fixed score sum when ordering digests.
+package ranker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chyiyaqing/newsbot/internal/store"
+)
+
+// Training parameters.
+const (
+	epochs       = 50
+	learningRate = 0.1
+	l2           = 0.001
+	// holdoutShare of the newest examples is kept back to evaluate a model
+	// trained on the rest before the final model is trained on everything.
+	holdoutShare = 0.2
+)
+
+// ErrTooFewExamples is returned by Train when there are fewer labeled
+// examples than required.
+var ErrTooFewExamples = errors.New("not enough labeled examples")
+
+// Model is a trained logistic regression. A nil Model leaves the order of
+// articles unchanged.
+type Model struct {
+	Weights   map[string]float64 `json:"weights"`
+	Bias      float64            `json:"bias"`
+	TrainedAt time.Time          `json:"trained_at"`
+	Examples  int                `json:"examples"`
+}
+
+// Report describes a training run. Accuracy and the AUCs are measured on the
+// holdout set; BaselineAUC is that of the fixed total score.
+type Report struct {
+	Examples    int     `json:"examples"`
+	Positives   int     `json:"positives"`
+	Holdout     int     `json:"holdout"`
+	Accuracy    float64 `json:"accuracy"`
+	AUC         float64 `json:"auc"`
+	BaselineAUC float64 `json:"baseline_auc"`
+}
+
+type example struct {
+	features map[string]float64
+	label    float64
+	total    int
+}
+
+// Label turns the reader signals of an example into a training label.
+// Thumbs up, "more like this" and clicks count for the article, thumbs down
+// and muting its source against it; a notified article nobody reacted to is
+// a negative. ok is false when the signals cancel out.
+func Label(e store.TrainingExample) (label float64, ok bool) {
+	pos := e.Up + e.More
+	if e.Clicks > 0 {
+		pos++
+	}
+	neg := e.Down + e.Mute
+	switch {
+	case pos > neg:
+		return 1, true
+	case neg > pos:
+		return 0, true
+	case pos == 0 && e.Notified:
+		return 0, true
+	}
+	return 0, false
+}
+
+// Features returns the sparse feature vector of an analyzed article.
+func Features(a store.ArticleWithAnalysis) map[string]float64 {
+	f := map[string]float64{
+		"relevance":  float64(a.Relevance) / 10,
+		"quality":    float64(a.Quality) / 10,
+		"timeliness": float64(a.Timeliness) / 10,
+		"adjustment": float64(a.ScoreAdjustment) / 10,
+		"length":     math.Log1p(float64(len([]rune(a.Article.Summary)))) / 10,
+	}
+	if c := strings.ToLower(strings.TrimSpace(a.Category)); c != "" {
+		f["category:"+c] = 1
+	}
+	if d := strings.ToLower(a.BlogDomain); d != "" {
+		f["source:"+d] = 1
+	}
+	for _, k := range strings.Split(a.Keywords, ",") {
+		if k = strings.ToLower(strings.TrimSpace(k)); k != "" {
+			f["keyword:"+k] = 1
+		}
+	}
+	return f
+}
+
+// Train fits a model to the labeled examples, which must be ordered oldest
+// first. It returns ErrTooFewExamples if fewer than minExamples are labeled.
+func Train(examples []store.TrainingExample, minExamples int) (*Model, Report, error) {
+	var set []example
+	var rep Report
+	for _, e := range examples {
+		label, ok := Label(e)
+		if !ok {
+			continue
+		}
+		set = append(set, example{Features(e.ArticleWithAnalysis), label, e.TotalScore})
+		if label == 1 {
+			rep.Positives++
+		}
+	}
+	rep.Examples = len(set)
+	if rep.Examples == 0 || rep.Examples < minExamples {
+		return nil, rep, fmt.Errorf("%w: %d labeled, need %d", ErrTooFewExamples, rep.Examples, minExamples)
+	}
+
+	if rep.Holdout = int(float64(len(set)) * holdoutShare); rep.Holdout > 0 {
+		train, holdout := set[:len(set)-rep.Holdout], set[len(set)-rep.Holdout:]
+		m := fit(train)
+		scores := make([]float64, len(holdout))
+		baseline := make([]float64, len(holdout))
+		correct := 0
+		for i, ex := range holdout {
+			scores[i] = m.predict(ex.features)
+			baseline[i] = float64(ex.total)
+			if (scores[i] >= 0.5) == (ex.label == 1) {
+				correct++
+			}
+		}
+		rep.Accuracy = float64(correct) / float64(len(holdout))
+		rep.AUC = auc(scores, holdout)
+		rep.BaselineAUC = auc(baseline, holdout)
+	}
+
+	m := fit(set)
+	m.TrainedAt = time.Now()
+	m.Examples = len(set)
+	return m, rep, nil
+}
+
+// fit runs stochastic gradient descent with L2 regularization. The shuffle
+// is seeded so that training on the same data gives the same model.
+func fit(set []example) *Model {
+	m := &Model{Weights: make(map[string]float64)}
+	order := make([]int, len(set))
+	for i := range order {
+		order[i] = i
+	}
+	rng := rand.New(rand.NewSource(1))
+	for range epochs {
+		rng.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+		for _, i := range order {
+			ex := set[i]
+			grad := m.predict(ex.features) - ex.label
+			for name, v := range ex.features {
+				w := m.Weights[name]
+				m.Weights[name] = w - learningRate*(grad*v+l2*w)
+			}
+			m.Bias -= learningRate * grad
+		}
+	}
+	for name, w := range m.Weights {
+		if math.Abs(w) < 1e-6 {
+			delete(m.Weights, name)
+		}
+	}
+	return m
+}
+
+func (m *Model) predict(features map[string]float64) float64 {
+	z := m.Bias
+	for name, v := range features {
+		z += m.Weights[name] * v
+	}
+	return 1 / (1 + math.Exp(-z))
+}
+
+// Score returns the predicted probability that a reader reacts positively
+// to the article.
+func (m *Model) Score(a store.ArticleWithAnalysis) float64 {
+	return m.predict(Features(a))
+}
+
+// Rank orders articles by learned score, highest first, keeping the
+// existing order for ties. It does nothing on a nil Model.
+func (m *Model) Rank(articles []store.ArticleWithAnalysis) {
+	if m == nil {
+		return
+	}
+	scores := make(map[int64]float64, len(articles))
+	for _, a := range articles {
+		scores[a.Article.ID] = m.Score(a)
+	}
+	sort.SliceStable(articles, func(i, j int) bool {
+		return scores[articles[i].Article.ID] > scores[articles[j].Article.ID]
+	})
+}
+
+// candidateFactor is how many more articles than it keeps a model ranks, so
+// it can promote articles the total score alone would have cut.
+const candidateFactor = 5
+
+// Candidates returns how many top-scored articles to select so that Top can
+// keep n: n on a nil Model, more otherwise.
+func (m *Model) Candidates(n int) int {
+	if m == nil {
+		return n
+	}
+	return n * candidateFactor
+}
+
+// Top ranks articles and keeps the first n.
+func (m *Model) Top(articles []store.ArticleWithAnalysis, n int) []store.ArticleWithAnalysis {
+	m.Rank(articles)
+	if len(articles) > n {
+		articles = articles[:n]
+	}
+	return articles
+}
+
+// Weight is a named model weight.
+type Weight struct {
+	Feature string
+	Value   float64
+}
+
+// TopWeights returns the n weights with the largest magnitude.
+func (m *Model) TopWeights(n int) []Weight {
+	weights := make([]Weight, 0, len(m.Weights))
+	for name, w := range m.Weights {
+		weights = append(weights, Weight{name, w})
+	}
+	sort.Slice(weights, func(i, j int) bool {
+		if a, b := math.Abs(weights[i].Value), math.Abs(weights[j].Value); a != b {
+			return a > b
+		}
+		return weights[i].Feature < weights[j].Feature
+	})
+	return weights[:min(n, len(weights))]
+}
+
+// Load returns the latest trained model, or nil if none has been trained.
+func Load(db *store.Store) (*Model, error) {
+	rm, err := db.LatestRankerModel()
+	if err != nil || rm == nil {
+		return nil, err
+	}
+	var m Model
+	if err := json.Unmarshal([]byte(rm.Model), &m); err != nil {
+		return nil, fmt.Errorf("decode ranker model %d: %w", rm.ID, err)
+	}
+	return &m, nil
+}
+
+// Save stores m as the latest model.
+func Save(db *store.Store, m *Model) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return db.SaveRankerModel(store.RankerModel{TrainedAt: m.TrainedAt, Examples: m.Examples, Model: string(b)})
+}
+
+// auc returns the area under the ROC curve of scores against the example
+// labels, counting ties as half. It is 0.5 when only one class is present.
+func auc(scores []float64, set []example) float64 {
+	var pos, neg []float64
+	for i, ex := range set {
+		if ex.label == 1 {
+			pos = append(pos, scores[i])
+		} else {
+			neg = append(neg, scores[i])
+		}
+	}
+	if len(pos) == 0 || len(neg) == 0 {
+		return 0.5
+	}
+	var sum float64
+	for _, p := range pos {
+		for _, n := range neg {
+			switch {
+			case p > n:
+				sum++
+			case p == n:
+				sum += 0.5
+			}
+		}
+	}
+	return sum / float64(len(pos)*len(neg))
+}
@@ -0,0 +1,193 @@
+package render
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/chyiyaqing/newsbot/internal/ai"
+	"github.com/chyiyaqing/newsbot/internal/i18n"
+	"github.com/chyiyaqing/newsbot/internal/store"
+)
+
+// MaxArticles is the number of articles listed in a digest.
+const MaxArticles = 20
+
+// Digest is the data every digest template renders.
+type Digest struct {
+	Window string
+	// Language is store.LanguageChinese, store.LanguageEnglish,
+	// store.LanguageBilingual or a translation language such as "ja". It
+	// selects the labels and the article fields templates show.
+	Language string
+	// Total counts all new articles, including those beyond MaxArticles.
+	Total    int
+	Articles []Article
+	Trends   []ai.Trend
+	// PrefsURL and UnsubscribeURL are set for subscriber emails only.
+	PrefsURL       string
+	UnsubscribeURL string
+
+	translations map[int64]store.Translation
+}
+
+// Article is one numbered digest entry.
+type Article struct {
+	N         int
+	ID        int64
+	Title     string
+	TitleCN   string
+	URL       string
+	Source    string
+	Category  string
+	Keywords  string
+	Score     int
+	Summary   string
+	Reason    string
+	Published string
+
+	lang string
+	tr   *store.Translation
+}
+
+// Language is the language of the digest the article is rendered in.
+func (a Article) Language() string { return a.lang }
+
+// LocalTitle is the title in the digest language, falling back to the
+// original title when there is no translation.
+func (a Article) LocalTitle() string {
+	switch {
+	case a.lang == store.LanguageChinese && a.TitleCN != "":
+		return a.TitleCN
+	case a.tr != nil && a.tr.Title != "":
+		return a.tr.Title
+	}
+	return a.Title
+}
+
+// LocalReason is the recommendation reason in the digest language, or ""
+// when there is none in that language. English digests have none.
+func (a Article) LocalReason() string {
+	switch {
+	case a.lang == store.LanguageChinese, a.lang == store.LanguageBilingual:
+		return a.Reason
+	case a.tr != nil:
+		return a.tr.Reason
+	}
+	return ""
+}
+
+// LocalSummary is the summary in the digest language, falling back to the
+// English summary when there is no translation. Chinese digests have none.
+func (a Article) LocalSummary() string {
+	switch {
+	case a.lang == store.LanguageChinese:
+		return ""
+	case a.tr != nil && a.tr.Summary != "":
+		return a.tr.Summary
+	}
+	return a.Summary
+}
+
+// NewDigest builds a bilingual digest of the top MaxArticles articles.
+func NewDigest(articles []store.ArticleWithAnalysis, trends *ai.TrendReport, window string) Digest {
+	d := Digest{
+		Window:   window,
+		Language: store.LanguageBilingual,
+		Total:    len(articles),
+	}
+	for i, a := range articles {
+		if i == MaxArticles {
+			break
+		}
+		d.Articles = append(d.Articles, Article{
+			N:         i + 1,
+			ID:        a.Article.ID,
+			Title:     a.Article.Title,
+			TitleCN:   a.ArticleAnalysis.TitleCN,
+			URL:       a.Article.URL,
+			Source:    a.Article.BlogDomain,
+			Category:  a.ArticleAnalysis.Category,
+			Keywords:  a.ArticleAnalysis.Keywords,
+			Score:     a.ArticleAnalysis.TotalScore,
+			Summary:   a.ArticleAnalysis.AISummary,
+			Reason:    a.ArticleAnalysis.RecommendReason,
+			Published: a.Article.PublishedAt.Format("2006-01-02"),
+		})
+	}
+	if trends != nil {
+		d.Trends = trends.Trends
+	}
+	return d
+}
+
+// ForSubscriber returns a copy of d in the subscriber's language, with
+// preference and unsubscribe links under siteURL (omitted if siteURL is empty).
+func (d Digest) ForSubscriber(siteURL, token, lang string) Digest {
+	if lang != "" {
+		d.Language = lang
+	}
+	if siteURL != "" && token != "" {
+		base := strings.TrimRight(siteURL, "/")
+		d.PrefsURL = base + "/api/subscription?token=" + url.QueryEscape(token)
+		d.UnsubscribeURL = base + "/api/unsubscribe?token=" + url.QueryEscape(token)
+	}
+	return d
+}
+
+// WithTranslations returns a copy of d that shows the given translations,
+// keyed by article ID, for articles that have one in the digest language.
+func (d Digest) WithTranslations(tr map[int64]store.Translation) Digest {
+	d.translations = tr
+	return d
+}
+
+// localized returns a copy of d whose articles know the digest language
+// and their translation into it.
+func (d Digest) localized() Digest {
+	articles := make([]Article, len(d.Articles))
+	for i, a := range d.Articles {
+		a.lang = d.Language
+		a.tr = nil
+		if t, ok := d.translations[a.ID]; ok && t.Language == d.Language {
+			a.tr = &t
+		}
+		articles[i] = a
+	}
+	d.Articles = articles
+	return d
+}
+
+// ArticleIDs returns the IDs of the listed articles, e.g. to load their
+// translations.
+func (d Digest) ArticleIDs() []int64 {
+	ids := make([]int64, len(d.Articles))
+	for i, a := range d.Articles {
+		ids[i] = a.ID
+	}
+	return ids
+}
+
+// WithLinks returns a copy of d whose article URLs are replaced by
+// link(articleID), such as click-tracking redirects. A nil link leaves the
+// URLs unchanged.
+func (d Digest) WithLinks(link func(articleID int64) string) Digest {
+	if link == nil {
+		return d
+	}
+	articles := make([]Article, len(d.Articles))
+	for i, a := range d.Articles {
+		a.URL = link(a.ID)
+		articles[i] = a
+	}
+	d.Articles = articles
+	return d
+}
+
+// WindowLabel is the window in the digest language, e.g. "24小时".
+func (d Digest) WindowLabel() string {
+	switch d.Window {
+	case "24h", "3days", "7days":
+		return i18n.Label(d.Language, "window_"+d.Window)
+	}
+	return d.Window
+}
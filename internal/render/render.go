@@ -0,0 +1,221 @@
+// Package render turns a digest of analyzed articles into email HTML,
+// Telegram HTML, Markdown and plain text using Go templates. Default
+// templates are embedded in the binary; any of them can be replaced by a file
+// with the same name in a templates directory.
+package render
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+
+	"github.com/chyiyaqing/newsbot/internal/i18n"
+)
+
+//go:embed templates/*
+var embedded embed.FS
+
+// Template file names, in the embedded defaults and in an override directory.
+const (
+	EmailTemplate    = "email.html"
+	TelegramTemplate = "telegram.html"
+	MarkdownTemplate = "digest.md"
+	TextTemplate     = "digest.txt"
+)
+
+// Renderer renders digests with a fixed set of parsed templates. The parsed
+// templates are never executed themselves: each render executes a clone
+// whose "t" function looks labels up in the digest language.
+type Renderer struct {
+	email    *htmltemplate.Template
+	telegram *htmltemplate.Template
+	markdown *texttemplate.Template
+	text     *texttemplate.Template
+}
+
+var funcs = map[string]any{
+	"inc":  func(i int) int { return i + 1 },
+	"join": strings.Join,
+	"md":   markdownEscaper.Replace,
+	"t":    labels(""),
+}
+
+// labels returns the "t" template function for lang: {{t "key" args...}}
+// is the i18n label for key.
+func labels(lang string) func(key string, args ...any) string {
+	return func(key string, args ...any) string {
+		return i18n.Label(lang, key, args...)
+	}
+}
+
+// htmlFor and textFor clone t with labels in lang.
+func htmlFor(t *htmltemplate.Template, lang string) (*htmltemplate.Template, error) {
+	c, err := t.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return c.Funcs(htmltemplate.FuncMap{"t": labels(lang)}), nil
+}
+
+func textFor(t *texttemplate.Template, lang string) (*texttemplate.Template, error) {
+	c, err := t.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return c.Funcs(texttemplate.FuncMap{"t": labels(lang)}), nil
+}
+
+// markdownEscaper backslash-escapes characters that would otherwise start
+// Markdown emphasis, links or code spans inside text.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`,
+	"[", `\[`, "]", `\]`, "<", `\<`, "#", `\#`,
+)
+
+// New parses the templates, preferring files in dir (if non-empty) over the
+// embedded defaults.
+func New(dir string) (*Renderer, error) {
+	read := func(name string) (string, error) {
+		if dir != "" {
+			b, err := os.ReadFile(filepath.Join(dir, name))
+			if err == nil {
+				return string(b), nil
+			}
+			if !os.IsNotExist(err) {
+				return "", err
+			}
+		}
+		b, err := embedded.ReadFile("templates/" + name)
+		return string(b), err
+	}
+
+	var r Renderer
+	for _, t := range []struct {
+		name string
+		html **htmltemplate.Template
+		text **texttemplate.Template
+	}{
+		{EmailTemplate, &r.email, nil},
+		{TelegramTemplate, &r.telegram, nil},
+		{MarkdownTemplate, nil, &r.markdown},
+		{TextTemplate, nil, &r.text},
+	} {
+		src, err := read(t.name)
+		if err != nil {
+			return nil, fmt.Errorf("read template %s: %w", t.name, err)
+		}
+		if t.html != nil {
+			*t.html, err = htmltemplate.New(t.name).Funcs(funcs).Parse(src)
+		} else {
+			*t.text, err = texttemplate.New(t.name).Funcs(funcs).Parse(src)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse template %s: %w", t.name, err)
+		}
+	}
+	return &r, nil
+}
+
+// Default returns a renderer using only the embedded templates.
+var Default = sync.OnceValue(func() *Renderer {
+	r, err := New("")
+	if err != nil {
+		panic(err)
+	}
+	return r
+})
+
+// EmailHTML renders the HTML email body.
+func (r *Renderer) EmailHTML(d Digest) (string, error) {
+	t, err := htmlFor(r.email, d.Language)
+	if err != nil {
+		return "", fmt.Errorf("render email: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, d.localized()); err != nil {
+		return "", fmt.Errorf("render email: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// Markdown renders the digest as Markdown.
+func (r *Renderer) Markdown(d Digest) (string, error) {
+	t, err := textFor(r.markdown, d.Language)
+	if err != nil {
+		return "", fmt.Errorf("render markdown: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, d.localized()); err != nil {
+		return "", fmt.Errorf("render markdown: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// Text renders the digest as plain text.
+func (r *Renderer) Text(d Digest) (string, error) {
+	t, err := textFor(r.text, d.Language)
+	if err != nil {
+		return "", fmt.Errorf("render text: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, d.localized()); err != nil {
+		return "", fmt.Errorf("render text: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// TelegramParts is a Telegram digest split into its header, one block per
+// article and the trends section, so callers can pack blocks into messages.
+type TelegramParts struct {
+	Header   string
+	Articles []string
+	Trends   string
+}
+
+// TelegramParts renders the "header", "article" and "trends" blocks of the
+// Telegram template.
+func (r *Renderer) TelegramParts(d Digest) (*TelegramParts, error) {
+	t, err := htmlFor(r.telegram, d.Language)
+	if err != nil {
+		return nil, fmt.Errorf("render telegram: %w", err)
+	}
+	d = d.localized()
+	exec := func(name string, data any) (string, error) {
+		var buf bytes.Buffer
+		if err := t.ExecuteTemplate(&buf, name, data); err != nil {
+			return "", fmt.Errorf("render telegram %s: %w", name, err)
+		}
+		return buf.String(), nil
+	}
+
+	var p TelegramParts
+	if p.Header, err = exec("header", d); err != nil {
+		return nil, err
+	}
+	for _, a := range d.Articles {
+		block, err := exec("article", a)
+		if err != nil {
+			return nil, err
+		}
+		p.Articles = append(p.Articles, block)
+	}
+	if p.Trends, err = exec("trends", d.Trends); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// TelegramHTML renders the whole Telegram digest as one HTML string.
+func (r *Renderer) TelegramHTML(d Digest) (string, error) {
+	p, err := r.TelegramParts(d)
+	if err != nil {
+		return "", err
+	}
+	return p.Header + strings.Join(p.Articles, "") + p.Trends, nil
+}
@@ -0,0 +1,190 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/chyiyaqing/newsbot/internal/ai"
+	"github.com/chyiyaqing/newsbot/internal/config"
+	"github.com/chyiyaqing/newsbot/internal/i18n"
+	"github.com/chyiyaqing/newsbot/internal/notify/email"
+	"github.com/chyiyaqing/newsbot/internal/ranker"
+	"github.com/chyiyaqing/newsbot/internal/render"
+	"github.com/chyiyaqing/newsbot/internal/store"
+	"github.com/chyiyaqing/newsbot/internal/track"
+)
+
+// LocalizeDigest returns d in lang, with the stored translations of its
+// articles when lang is a translation language. A failed lookup leaves the
+// articles in English.
+func LocalizeDigest(ctx context.Context, db *store.Store, d render.Digest, lang string) render.Digest {
+	if lang == "" {
+		return d
+	}
+	d.Language = lang
+	if i18n.Builtin(lang) {
+		return d
+	}
+	tr, err := db.Translations(d.ArticleIDs(), lang)
+	if err != nil {
+		slog.WarnContext(ctx, "load translations", "language", lang, "err", err)
+		return d
+	}
+	return d.WithTranslations(tr)
+}
+
+// digestSlack lets a daily or weekly digest go out on the run closest to its
+// due time instead of slipping a whole cron interval.
+const digestSlack = time.Hour
+
+// DigestSize is the most articles a Telegram or email digest carries.
+const DigestSize = 20
+
+// sendEmailDigests sends every confirmed subscriber whose digest is due the
+// articles first analyzed since their previous digest that they have not
+// received yet, filtered by their preferences. Digests go out as one batch over pooled SMTP sessions.
+// It returns the number of emails sent.
+func sendEmailDigests(ctx context.Context, db *store.Store, cfg *config.Config, emailCl *email.Client, r *render.Renderer, report *ai.TrendReport, model *ranker.Model) int {
+	subscribers, err := db.ListSubscribers()
+	if err != nil {
+		slog.WarnContext(ctx, "list subscribers", "err", err)
+		return 0
+	}
+
+	now := time.Now()
+	var msgs []email.Outgoing
+	var recipients []store.Subscriber
+	var sentArticles [][]int64
+	for _, sub := range subscribers {
+		since, due := digestSince(sub, now)
+		if !due {
+			continue
+		}
+
+		articles, err := db.DigestArticles(sub.ID, since, sub.Categories, sub.MinScore, model.Candidates(DigestSize))
+		if err != nil {
+			slog.WarnContext(ctx, "digest articles", "email", sub.Email, "err", err)
+			continue
+		}
+		if len(articles) == 0 {
+			continue
+		}
+		articles = model.Top(articles, DigestSize)
+
+		window := "24h"
+		if sub.Frequency == store.FrequencyWeekly {
+			window = "7days"
+		}
+		d := LocalizeDigest(ctx, db, render.NewDigest(articles, report, window).
+			ForSubscriber(cfg.SMTP.SiteURL, sub.Token, sub.Language).
+			WithLinks(track.ConfigLinker(cfg, "email", sub.Email)), sub.Language)
+		html, err := r.EmailHTML(d)
+		if err != nil {
+			slog.WarnContext(ctx, "render digest", "email", sub.Email, "err", err)
+			continue
+		}
+		text, err := r.Text(d)
+		if err != nil {
+			slog.WarnContext(ctx, "render digest", "email", sub.Email, "err", err)
+			continue
+		}
+		msgs = append(msgs, email.Outgoing{
+			To:               sub.Email,
+			Subject:          i18n.Label(d.Language, "email_subject", len(articles)),
+			HTML:             html,
+			Text:             text,
+			UnsubscribeToken: sub.Token,
+		})
+		recipients = append(recipients, sub)
+		ids := make([]int64, len(articles))
+		for i, a := range articles {
+			ids[i] = a.Article.ID
+		}
+		sentArticles = append(sentArticles, ids)
+	}
+	if len(msgs) == 0 {
+		return 0
+	}
+
+	slog.InfoContext(ctx, "sending email digests", "channel", "email", "count", len(msgs))
+	results := emailCl.SendBatch(ctx, msgs, email.BatchOptions{
+		Concurrency: cfg.SMTP.Concurrency,
+		PerMinute:   cfg.SMTP.RatePerMinute,
+	})
+
+	sent := 0
+	for i, res := range results {
+		sub := recipients[i]
+		if res.Err != nil {
+			slog.WarnContext(ctx, "send digest", "channel", "email", "email", sub.Email, "err", res.Err)
+			if email.IsPermanentFailure(res.Err) {
+				recordBounce(ctx, db, cfg, sub.Email, res.Err)
+			}
+			continue
+		}
+		sent++
+		if err := db.MarkSubscriberSent(sub.ID, res.SentAt, sentArticles[i]); err != nil {
+			slog.WarnContext(ctx, "mark digest sent", "email", sub.Email, "err", err)
+		}
+	}
+	slog.InfoContext(ctx, "email digests sent", "channel", "email", "sent", sent, "total", len(msgs))
+	return sent
+}
+
+// loadRanker returns the learned ranking model if ranking is enabled and a
+// model has been trained, or nil to keep the total score order.
+func loadRanker(ctx context.Context, db *store.Store, cfg *config.Config) *ranker.Model {
+	if !cfg.Ranker.Enabled {
+		return nil
+	}
+	model, err := ranker.Load(db)
+	if err != nil {
+		slog.WarnContext(ctx, "load ranker model", "err", err)
+		return nil
+	}
+	if model == nil {
+		slog.WarnContext(ctx, "ranker enabled but no model trained, run train-ranker")
+	}
+	return model
+}
+
+// recordBounce counts a permanent delivery failure against a subscriber,
+// suspending them once the configured threshold is reached.
+func recordBounce(ctx context.Context, db *store.Store, cfg *config.Config, addr string, sendErr error) {
+	suspended, err := db.RecordBounce(store.Bounce{
+		Email:  addr,
+		Kind:   store.BounceHard,
+		Detail: sendErr.Error(),
+	}, cfg.Bounces.Threshold)
+	if err != nil {
+		slog.WarnContext(ctx, "record bounce", "email", addr, "err", err)
+		return
+	}
+	if suspended {
+		slog.InfoContext(ctx, "suspended subscriber after permanent failures", "email", addr, "threshold", cfg.Bounces.Threshold)
+	}
+}
+
+// digestSince returns the start of the period a subscriber's next digest
+// covers, and whether that digest is due now.
+func digestSince(sub store.Subscriber, now time.Time) (time.Time, bool) {
+	var period time.Duration
+	switch sub.Frequency {
+	case store.FrequencyDaily:
+		period = 24 * time.Hour
+	case store.FrequencyWeekly:
+		period = 7 * 24 * time.Hour
+	}
+
+	if sub.LastSentAt == nil {
+		if period == 0 {
+			period = 24 * time.Hour
+		}
+		return now.Add(-period), true
+	}
+	if now.Sub(*sub.LastSentAt) < period-digestSlack {
+		return time.Time{}, false
+	}
+	return *sub.LastSentAt, true
+}
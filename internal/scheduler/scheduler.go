@@ -2,198 +2,456 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/chyiyaqing/newsbot/internal/ai"
 	"github.com/chyiyaqing/newsbot/internal/config"
+	"github.com/chyiyaqing/newsbot/internal/events"
 	"github.com/chyiyaqing/newsbot/internal/hnpopular"
+	"github.com/chyiyaqing/newsbot/internal/interest"
+	"github.com/chyiyaqing/newsbot/internal/logging"
+	"github.com/chyiyaqing/newsbot/internal/metrics"
 	"github.com/chyiyaqing/newsbot/internal/notify/email"
 	"github.com/chyiyaqing/newsbot/internal/notify/telegram"
+	"github.com/chyiyaqing/newsbot/internal/render"
 	"github.com/chyiyaqing/newsbot/internal/scraper"
+	"github.com/chyiyaqing/newsbot/internal/sources"
 	"github.com/chyiyaqing/newsbot/internal/store"
+	"github.com/chyiyaqing/newsbot/internal/track"
 	"github.com/robfig/cron/v3"
 )
 
-// Run executes the full pipeline immediately, then starts a cron scheduler
-// to repeat it periodically. It blocks until ctx is cancelled.
 // emailCl builds an email client from config, or returns nil if not configured.
 func newEmailClient(cfg *config.Config) *email.Client {
 	return email.New(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.From, cfg.SMTP.SiteURL)
 }
 
-func Run(ctx context.Context, db *store.Store, cfg *config.Config, schedule string) error {
+// Pipeline stages that can be triggered on their own.
+const (
+	StageFetchBlogs = "fetch-blogs"
+	StageScrape     = "scrape"
+	StageAnalyze    = "analyze"
+	StageNotify     = "notify"
+	StageAll        = "all"
+)
+
+// ValidStage reports whether stage can be passed to Trigger.
+func ValidStage(stage string) bool {
+	switch stage {
+	case StageFetchBlogs, StageScrape, StageAnalyze, StageNotify, StageAll:
+		return true
+	}
+	return false
+}
+
+// ErrBusy is returned by Trigger while another run is in progress.
+var ErrBusy = errors.New("pipeline already running")
+
+// Runner runs the pipeline on a schedule and on demand. Only one run or
+// stage executes at a time.
+type Runner struct {
+	ctx    context.Context
+	db     *store.Store
+	cfg    *config.Config
+	events *events.Bus
+	mu     sync.Mutex
+}
+
+// NewRunner creates a runner whose runs stop when ctx is cancelled. Progress
+// is published to bus, which may be nil.
+func NewRunner(ctx context.Context, db *store.Store, cfg *config.Config, bus *events.Bus) *Runner {
+	return &Runner{ctx: ctx, db: db, cfg: cfg, events: bus}
+}
+
+// Run executes the full pipeline immediately, then repeats it on schedule
+// until the runner's context is cancelled.
+func (r *Runner) Run(schedule string) error {
 	if schedule == "" {
 		schedule = "0 */6 * * *" // every 6 hours
 	}
 
 	// Run pipeline immediately on startup.
-	log.Println("Running initial pipeline...")
-	runPipeline(ctx, db, cfg)
+	slog.Info("running initial pipeline")
+	r.runLocked(StageAll)
 
 	c := cron.New()
 
 	_, err := c.AddFunc(schedule, func() {
-		runPipeline(ctx, db, cfg)
+		r.runLocked(StageAll)
 	})
 	if err != nil {
 		return err
 	}
 
 	c.Start()
-	log.Printf("Scheduler started with schedule: %s", schedule)
+	slog.Info("scheduler started", "schedule", schedule)
 
-	<-ctx.Done()
+	<-r.ctx.Done()
 	c.Stop()
 	return nil
 }
 
-func runPipeline(ctx context.Context, db *store.Store, cfg *config.Config) {
+// Trigger starts a stage in the background. It returns ErrBusy if a run is
+// already in progress.
+func (r *Runner) Trigger(stage string) error {
+	if !ValidStage(stage) {
+		return fmt.Errorf("unknown stage: %s", stage)
+	}
+	if !r.mu.TryLock() {
+		return ErrBusy
+	}
+	go func() {
+		defer r.mu.Unlock()
+		r.runStage(stage)
+	}()
+	return nil
+}
+
+func (r *Runner) runLocked(stage string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runStage(stage)
+}
+
+// runStage runs one stage, or the whole pipeline, under a new run ID that is
+// attached to every log line it emits.
+func (r *Runner) runStage(stage string) {
+	runID := logging.NewRunID()
+	ctx := logging.WithRunID(r.ctx, runID)
+	start := time.Now()
+	slog.InfoContext(ctx, "pipeline stage started", "stage", stage)
+	switch stage {
+	case StageFetchBlogs:
+		r.fetchBlogs(ctx) //nolint:errcheck
+	case StageScrape:
+		blogs, err := r.db.ListBlogs()
+		if err != nil {
+			slog.ErrorContext(ctx, "list blogs", "err", err)
+			return
+		}
+		r.scrape(ctx, blogs)
+	case StageAnalyze:
+		r.analyze(ctx)
+	case StageNotify:
+		r.notify(ctx)
+	case StageAll:
+		r.runAll(ctx)
+	}
+	slog.InfoContext(ctx, "pipeline stage done", "stage", stage, "duration", time.Since(start).Round(time.Millisecond))
+	r.events.Publish(events.Event{
+		Type: events.PipelineFinished,
+		Data: map[string]any{
+			"stage":       stage,
+			"run_id":      runID,
+			"started_at":  start.UTC().Format(time.RFC3339),
+			"duration_ms": time.Since(start).Milliseconds(),
+		},
+	})
+}
+
+func (r *Runner) runAll(ctx context.Context) {
+	// Step 0: Drop subscribers who never confirmed their address
+	if n, err := r.db.DeletePendingSubscribers(time.Now().Add(-r.cfg.SMTP.ConfirmWindow())); err != nil {
+		slog.WarnContext(ctx, "delete pending subscribers", "err", err)
+	} else if n > 0 {
+		slog.InfoContext(ctx, "removed unconfirmed subscribers", "count", n)
+	}
+
 	// Step 1: Fetch blogs
-	log.Println("Pipeline: fetching blogs...")
-	blogs, err := hnpopular.FetchTopBlogs(100)
+	blogs, err := r.fetchBlogs(ctx)
 	if err != nil {
-		log.Printf("ERROR: fetch blogs: %v", err)
-		return
-	}
-	if err := db.SaveBlogs(blogs); err != nil {
-		log.Printf("ERROR: save blogs: %v", err)
 		return
 	}
 
 	// Step 2: Scrape articles
-	log.Println("Pipeline: scraping articles...")
-	if err := scraper.ScrapeBlogs(ctx, blogs, db); err != nil {
-		log.Printf("ERROR: scrape: %v", err)
+	r.scrape(ctx, blogs)
+
+	// Step 3: Score and summarize
+	r.analyze(ctx)
+
+	// Step 3b: Demote or mute sources that keep scoring low or failing
+	if r.cfg.Sources.Action != "" {
+		if _, err := sources.Apply(ctx, r.db, r.cfg.Sources, false); err != nil {
+			slog.WarnContext(ctx, "apply source policy", "err", err)
+		}
 	}
 
-	// Step 3: Score and summarize (only articles not yet analyzed, 7days window)
-	log.Println("Pipeline: analyzing articles...")
-	articles, err := db.UnanalyzedArticles("7days")
+	// Step 4: Notify
+	r.notify(ctx)
+
+	if ctx.Err() == nil {
+		metrics.PipelineSucceeded(time.Now())
+	}
+}
+
+// fetchBlogs refreshes the HN popularity list and returns it together with
+// blogs added by hand.
+func (r *Runner) fetchBlogs(ctx context.Context) ([]store.Blog, error) {
+	slog.InfoContext(ctx, "fetching blogs")
+	blogs, err := hnpopular.FetchTopBlogs(100)
 	if err != nil {
-		log.Printf("ERROR: get articles: %v", err)
-		return
+		slog.ErrorContext(ctx, "fetch blogs", "err", err)
+		return nil, err
+	}
+	if err := r.db.SaveBlogs(blogs); err != nil {
+		slog.ErrorContext(ctx, "save blogs", "err", err)
+		return nil, err
 	}
 
-	client := ai.NewClient(cfg.Ollama.Address, cfg.Ollama.Model, cfg.Ollama.Username, cfg.Ollama.Password)
-	for _, article := range articles {
-		scoreResult, err := client.ScoreArticle(ctx, article)
-		if err != nil {
-			log.Printf("WARNING: score %q: %v", article.Title, err)
-			continue
+	manual, err := r.db.ManualBlogs()
+	if err != nil {
+		slog.WarnContext(ctx, "list manual blogs", "err", err)
+	}
+	for _, m := range manual {
+		if !slices.ContainsFunc(blogs, func(b store.Blog) bool { return b.Domain == m.Domain }) {
+			blogs = append(blogs, m)
 		}
+	}
+	return blogs, nil
+}
 
-		totalScore := scoreResult.Relevance + scoreResult.Quality + scoreResult.Timeliness
-		analysis := store.ArticleAnalysis{
-			ArticleID:  article.ID,
-			Relevance:  scoreResult.Relevance,
-			Quality:    scoreResult.Quality,
-			Timeliness: scoreResult.Timeliness,
-			TotalScore: totalScore,
-			Category:   scoreResult.Category,
-			Keywords:   strings.Join(scoreResult.Keywords, ", "),
-			AnalyzedAt: time.Now(),
+// scrape fetches the feeds of the blogs that are due: muted blogs are
+// skipped and demoted ones scraped only once per demote interval.
+func (r *Runner) scrape(ctx context.Context, blogs []store.Blog) {
+	due, err := sources.Due(r.db, blogs, r.cfg.Sources.ScrapeInterval(), time.Now())
+	if err != nil {
+		slog.WarnContext(ctx, "select blogs to scrape", "err", err)
+	} else {
+		if skipped := len(blogs) - len(due); skipped > 0 {
+			slog.InfoContext(ctx, "skipping muted and demoted blogs", "count", skipped)
 		}
+		blogs = due
+	}
+	slog.InfoContext(ctx, "scraping articles", "blogs", len(blogs))
+	if err := scraper.ScrapeBlogs(ctx, blogs, r.db); err != nil {
+		slog.ErrorContext(ctx, "scrape", "err", err)
+	}
+}
 
-		summaryResult, err := client.SummarizeArticle(ctx, article)
+// analyze scores and summarizes articles not yet analyzed (7days window),
+// retries summaries that failed earlier and translates summaries into the
+// configured languages.
+func (r *Runner) analyze(ctx context.Context) {
+	slog.InfoContext(ctx, "analyzing articles")
+	articles, err := r.db.UnanalyzedArticles("7days")
+	if err != nil {
+		slog.ErrorContext(ctx, "get unanalyzed articles", "err", err)
+		return
+	}
+
+	client, err := r.aiClient()
+	if err != nil {
+		slog.ErrorContext(ctx, "load prompts", "err", err)
+		return
+	}
+	profile := interest.New(r.cfg.Interests)
+	for _, article := range articles {
+		analysis, err := AnalyzeArticle(ctx, client, profile, article)
+		if errors.Is(err, ai.ErrBudgetExceeded) {
+			slog.WarnContext(ctx, "pausing analysis", "err", err)
+			return
+		}
 		if err != nil {
-			log.Printf("WARNING: summarize %q: %v", article.Title, err)
-		} else {
-			analysis.AISummary = summaryResult.Summary
-			analysis.TitleCN = summaryResult.TitleCN
-			analysis.RecommendReason = summaryResult.RecommendReason
+			slog.WarnContext(ctx, "score article", "article_id", article.ID, "title", article.Title, "err", err)
+			continue
 		}
-
-		if err := db.SaveArticleAnalysis(analysis); err != nil {
-			log.Printf("WARNING: save analysis: %v", err)
+		if err := r.db.SaveArticleAnalysis(analysis); err != nil {
+			slog.WarnContext(ctx, "save analysis", "article_id", article.ID, "err", err)
+			continue
 		}
+		r.publishArticle(store.ArticleWithAnalysis{Article: article, ArticleAnalysis: analysis})
 	}
 
-	// Step 3b: Retry summaries for high-score articles that failed previously
-	unsummarized, err := db.UnsummarizedHighScoreArticles("7days", 0)
+	// Retry summaries for high-score articles that failed previously
+	unsummarized, err := r.db.UnsummarizedHighScoreArticles("7days", 0)
 	if err != nil {
-		log.Printf("WARNING: get unsummarized articles: %v", err)
+		slog.WarnContext(ctx, "get unsummarized articles", "err", err)
 	} else if len(unsummarized) > 0 {
-		log.Printf("Pipeline: retrying summaries for %d high-score articles...", len(unsummarized))
+		slog.InfoContext(ctx, "retrying summaries for high-score articles", "count", len(unsummarized))
 		for _, item := range unsummarized {
 			summaryResult, err := client.SummarizeArticle(ctx, item.Article)
+			if errors.Is(err, ai.ErrBudgetExceeded) {
+				slog.WarnContext(ctx, "pausing analysis", "err", err)
+				return
+			}
 			if err != nil {
-				log.Printf("WARNING: retry summarize %q: %v", item.Article.Title, err)
+				slog.WarnContext(ctx, "retry summarize", "article_id", item.Article.ID, "title", item.Article.Title, "err", err)
 				continue
 			}
 			item.ArticleAnalysis.AISummary = summaryResult.Summary
 			item.ArticleAnalysis.TitleCN = summaryResult.TitleCN
 			item.ArticleAnalysis.RecommendReason = summaryResult.RecommendReason
-			if err := db.SaveArticleAnalysis(item.ArticleAnalysis); err != nil {
-				log.Printf("WARNING: save retry analysis: %v", err)
+			if err := r.db.SaveArticleAnalysis(item.ArticleAnalysis); err != nil {
+				slog.WarnContext(ctx, "save retry analysis", "article_id", item.Article.ID, "err", err)
+				continue
 			}
+			r.publishArticle(item)
 		}
 	}
 
+	if _, err := TranslateArticles(ctx, r.db, client, r.cfg.Languages, "7days"); err != nil {
+		slog.WarnContext(ctx, "pausing analysis", "err", err)
+	}
+}
+
+// Reanalyze scores and summarizes a single article again, replacing its
+// analysis. Returns nil if the article does not exist.
+func (r *Runner) Reanalyze(ctx context.Context, articleID int64) (*store.ArticleWithAnalysis, error) {
+	article, err := r.db.GetArticle(articleID)
+	if err != nil || article == nil {
+		return nil, err
+	}
+	client, err := r.aiClient()
+	if err != nil {
+		return nil, err
+	}
+	// An explicit re-analysis should not get the cached answer back.
+	analysis, err := AnalyzeArticle(ctx, client.Refresh(), interest.New(r.cfg.Interests), *article)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.db.SaveArticleAnalysis(analysis); err != nil {
+		return nil, err
+	}
+	updated, err := r.db.GetArticleWithAnalysis(articleID)
+	if err == nil && updated != nil {
+		r.publishArticle(*updated)
+	}
+	return updated, err
+}
+
+// publishArticle announces a new or updated analysis to live clients.
+func (r *Runner) publishArticle(a store.ArticleWithAnalysis) {
+	if a.ArticleAnalysis.HiddenAt != nil {
+		return
+	}
+	r.events.Publish(events.Event{
+		Type:     events.ArticleAnalyzed,
+		Category: a.ArticleAnalysis.Category,
+		Score:    a.ArticleAnalysis.TotalScore,
+		Data:     a,
+	})
+}
+
+// AnalyzeArticle scores an article, adjusts the score to the interest
+// profile and adds its summary. A failed summary only logs a warning; it is
+// retried on later runs.
+func AnalyzeArticle(ctx context.Context, client *ai.Client, profile *interest.Profile, article store.Article) (store.ArticleAnalysis, error) {
+	scoreResult, err := client.ScoreArticle(ctx, article)
+	if err != nil {
+		return store.ArticleAnalysis{}, err
+	}
+
+	analysis := store.ArticleAnalysis{
+		ArticleID:     article.ID,
+		Relevance:     scoreResult.Relevance,
+		Quality:       scoreResult.Quality,
+		Timeliness:    scoreResult.Timeliness,
+		Category:      scoreResult.Category,
+		Keywords:      strings.Join(scoreResult.Keywords, ", "),
+		AnalyzedAt:    time.Now(),
+		Model:         client.Model(),
+		PromptVersion: client.PromptVersion(),
+	}
+	profile.Apply(&analysis, article.BlogDomain)
+
+	summaryResult, err := client.SummarizeArticle(ctx, article)
+	if err != nil {
+		slog.WarnContext(ctx, "summarize article", "article_id", article.ID, "title", article.Title, "err", err)
+	} else {
+		analysis.AISummary = summaryResult.Summary
+		analysis.TitleCN = summaryResult.TitleCN
+		analysis.RecommendReason = summaryResult.RecommendReason
+	}
+	return analysis, nil
+}
+
+// aiClient returns a model client using the configured prompts, which are
+// reloaded on every call so edits apply without a restart.
+func (r *Runner) aiClient() (*ai.Client, error) {
+	prompts, err := ai.LoadPrompts(r.cfg.Prompts, interest.New(r.cfg.Interests))
+	if err != nil {
+		return nil, err
+	}
+	return ai.NewClient(r.cfg.Ollama.Address, r.cfg.Ollama.Model, r.cfg.Ollama.Username, r.cfg.Ollama.Password).
+		WithPrompts(prompts).
+		WithCache(r.db, r.cfg.Ollama.CacheWindow()).
+		WithUsage(r.db, r.cfg.Usage.DailyTokenBudget), nil
+}
+
+// notify sends new articles to Telegram and due digests to email subscribers.
+func (r *Runner) notify(ctx context.Context) {
+	db, cfg := r.db, r.cfg
+
+	// Templates are reloaded every run so edits apply without a restart.
+	renderer, err := render.New(cfg.Render.TemplatesDir)
+	if err != nil {
+		slog.ErrorContext(ctx, "load digest templates", "err", err)
+		return
+	}
+
 	// Step 4: Fetch unnotified articles (shared by Telegram and email)
-	newArticles, err := db.UnnotifiedAnalyses("7days")
+	model := loadRanker(ctx, db, cfg)
+	newArticles, err := db.UnnotifiedAnalyses("7days", model.Candidates(DigestSize))
 	if err != nil {
-		log.Printf("WARNING: get unnotified analyses: %v", err)
+		slog.WarnContext(ctx, "get unnotified analyses", "err", err)
 		newArticles = nil
 	}
+	newArticles = model.Top(newArticles, DigestSize)
+
+	var report *ai.TrendReport
+	notified := false
 
 	if len(newArticles) == 0 {
-		log.Println("Pipeline: no new articles to notify")
+		slog.InfoContext(ctx, "no new articles to notify")
 	} else {
 		// Generate trend report once for both channels
-		report, err := client.AnalyzeTrends(ctx, newArticles)
+		client, err := r.aiClient()
+		if err == nil {
+			report, err = client.TrendsFor(ctx, db, "7days", newArticles)
+		}
 		if err != nil {
-			log.Printf("WARNING: trend analysis for notification: %v", err)
+			slog.WarnContext(ctx, "trend analysis for notification", "err", err)
 			report = nil
+		} else {
+			r.events.Publish(events.Event{Type: events.TrendsUpdated, Data: report})
 		}
 
-		notified := false
-
 		// Step 4a: Send Telegram notification
-		tg := telegram.New(cfg.Telegram.BotToken, cfg.Telegram.ChatID)
+		tg := telegram.New(cfg.Telegram.BotToken, cfg.Telegram.ChatID, cfg.Telegram.Updates != "")
 		if tg != nil {
-			msg := telegram.FormatReport(newArticles, report, "7days")
-			if err := tg.Send(ctx, "", msg); err != nil {
-				log.Printf("WARNING: telegram send: %v", err)
+			d := LocalizeDigest(ctx, r.db, render.NewDigest(newArticles, report, "7days").
+				WithLinks(track.ConfigLinker(cfg, "telegram", cfg.Telegram.ChatID)), cfg.Telegram.Language)
+			if err := tg.SendReport(ctx, renderer, d); err != nil {
+				slog.WarnContext(ctx, "send report", "channel", "telegram", "err", err)
 			} else {
-				log.Printf("Pipeline: notified %d new articles via Telegram", len(newArticles))
+				slog.InfoContext(ctx, "notified new articles", "channel", "telegram", "count", len(newArticles))
 				notified = true
 			}
 		}
+	}
 
-		// Step 4b: Send email to subscribers
-		emailCl := newEmailClient(cfg)
-		if emailCl != nil {
-			subscribers, err := db.ListSubscribers()
-			if err != nil {
-				log.Printf("WARNING: list subscribers: %v", err)
-			} else if len(subscribers) > 0 {
-				subject := fmt.Sprintf("NewsBot 技术资讯 — 最新 %d 篇精选", len(newArticles))
-				for _, sub := range subscribers {
-					body := email.FormatEmailReport(newArticles, report, "7days", sub.Token, cfg.SMTP.SiteURL)
-					if err := emailCl.SendHTML(sub.Email, subject, body); err != nil {
-						log.Printf("WARNING: send email to %s: %v", sub.Email, err)
-					} else {
-						log.Printf("Pipeline: email sent to %s", sub.Email)
-						notified = true
-					}
-				}
-			}
+	// Step 4b: Send per-subscriber email digests that are due
+	if emailCl := newEmailClient(cfg); emailCl != nil {
+		if sendEmailDigests(ctx, db, cfg, emailCl, renderer, report, model) > 0 {
+			notified = true
 		}
+	}
 
-		// Mark articles as notified if at least one channel succeeded
-		if notified {
-			ids := make([]int64, len(newArticles))
-			for i, a := range newArticles {
-				ids[i] = a.Article.ID
-			}
-			if err := db.MarkNotified(ids); err != nil {
-				log.Printf("WARNING: mark notified: %v", err)
-			}
+	// Mark articles as notified if at least one channel succeeded
+	if notified && len(newArticles) > 0 {
+		ids := make([]int64, len(newArticles))
+		for i, a := range newArticles {
+			ids[i] = a.Article.ID
+		}
+		if err := db.MarkNotified(ids); err != nil {
+			slog.WarnContext(ctx, "mark notified", "err", err)
 		}
 	}
-
-	log.Println("Pipeline: done")
 }
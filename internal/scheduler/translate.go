@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/chyiyaqing/newsbot/internal/ai"
+	"github.com/chyiyaqing/newsbot/internal/i18n"
+	"github.com/chyiyaqing/newsbot/internal/store"
+)
+
+// TranslateArticles translates summarized articles within the window into
+// each of languages that they lack a translation for, and returns how many
+// translations were saved. Failed translations are retried on later runs;
+// only ai.ErrBudgetExceeded stops the loop and is returned.
+func TranslateArticles(ctx context.Context, db *store.Store, client *ai.Client, languages []string, window string) (int, error) {
+	saved := 0
+	for _, lang := range languages {
+		if i18n.Builtin(lang) {
+			continue
+		}
+		articles, err := db.UntranslatedArticles(window, lang)
+		if err != nil {
+			slog.WarnContext(ctx, "get untranslated articles", "language", lang, "err", err)
+			continue
+		}
+		if len(articles) > 0 {
+			slog.InfoContext(ctx, "translating articles", "language", lang, "count", len(articles))
+		}
+		for _, a := range articles {
+			result, err := client.TranslateArticle(ctx, a, lang)
+			if errors.Is(err, ai.ErrBudgetExceeded) {
+				return saved, err
+			}
+			if err != nil {
+				slog.WarnContext(ctx, "translate article", "article_id", a.Article.ID, "language", lang, "err", err)
+				continue
+			}
+			if err := db.SaveTranslation(store.Translation{
+				ArticleID: a.Article.ID,
+				Language:  lang,
+				Title:     result.Title,
+				Summary:   result.Summary,
+				Reason:    result.Reason,
+			}); err != nil {
+				slog.WarnContext(ctx, "save translation", "article_id", a.Article.ID, "language", lang, "err", err)
+				continue
+			}
+			saved++
+		}
+	}
+	return saved, nil
+}
@@ -3,12 +3,13 @@ package scraper
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/chyiyaqing/newsbot/internal/metrics"
 	"github.com/chyiyaqing/newsbot/internal/store"
 	"github.com/mmcdole/gofeed"
 )
@@ -32,8 +33,18 @@ func ScrapeBlogs(ctx context.Context, blogs []store.Blog, db *store.Store) error
 			defer func() { <-sem }()
 
 			articles, err := scrapeBlog(ctx, b.Domain)
+			metrics.FeedScrapes.Inc(b.Domain, metrics.Result(err))
+			fetch := store.FeedFetch{Domain: b.Domain, OK: err == nil, Articles: len(articles)}
 			if err != nil {
-				log.Printf("WARN: scrape %s: %v", b.Domain, err)
+				fetch.Error = err.Error()
+			}
+			if ctx.Err() == nil {
+				if err := db.SaveFeedFetch(fetch); err != nil {
+					slog.WarnContext(ctx, "save feed fetch", "domain", b.Domain, "err", err)
+				}
+			}
+			if err != nil {
+				slog.WarnContext(ctx, "scrape blog", "domain", b.Domain, "err", err)
 				return
 			}
 
@@ -41,10 +52,10 @@ func ScrapeBlogs(ctx context.Context, blogs []store.Blog, db *store.Store) error
 				a.BlogDomain = b.Domain
 				a.ScrapedAt = time.Now()
 				if err := db.SaveArticle(a); err != nil {
-					log.Printf("WARN: save article from %s: %v", b.Domain, err)
+					slog.WarnContext(ctx, "save article", "domain", b.Domain, "url", a.URL, "err", err)
 				}
 			}
-			log.Printf("Scraped %d articles from %s", len(articles), b.Domain)
+			slog.InfoContext(ctx, "scraped blog", "domain", b.Domain, "articles", len(articles))
 		}(blog)
 	}
 
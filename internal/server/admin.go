@@ -0,0 +1,364 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chyiyaqing/newsbot/internal/interest"
+	"github.com/chyiyaqing/newsbot/internal/scheduler"
+	"github.com/chyiyaqing/newsbot/internal/store"
+	"github.com/chyiyaqing/newsbot/internal/token"
+)
+
+// Pipeline runs pipeline work on demand for the admin API.
+type Pipeline interface {
+	// Trigger starts a stage in the background; scheduler.ErrBusy means a
+	// run is already in progress.
+	Trigger(stage string) error
+	Reanalyze(ctx context.Context, articleID int64) (*store.ArticleWithAnalysis, error)
+}
+
+// adminTokenPrefix separates admin token payloads from other signed tokens.
+const adminTokenPrefix = "admin:"
+
+// AdminToken issues a signed admin token for name, valid until exp.
+func AdminToken(secret, name string, exp time.Time) string {
+	return token.Sign([]byte(secret), adminTokenPrefix+name, exp)
+}
+
+type adminIdentityKey struct{}
+
+// requireAdmin accepts "Authorization: Bearer <key>" with a configured API
+// key or a signed admin token. The admin API is hidden when no credential is
+// configured.
+func (s *Server) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.cfg.Admin.Enabled() {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "not found"})
+			return
+		}
+		who, ok := s.adminIdentity(r.Header.Get("Authorization"))
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="newsbot-admin"`)
+			writeJSON(w, http.StatusUnauthorized, apiError{Error: "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), adminIdentityKey{}, who)))
+	})
+}
+
+// adminIdentity returns a name for the caller, for the audit log.
+func (s *Server) adminIdentity(header string) (string, bool) {
+	bearer, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || bearer == "" {
+		return "", false
+	}
+	for i, key := range s.cfg.Admin.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(bearer), []byte(key)) == 1 {
+			return "api-key#" + strconv.Itoa(i+1), true
+		}
+	}
+	if s.cfg.Admin.TokenSecret != "" {
+		payload, err := token.Verify([]byte(s.cfg.Admin.TokenSecret), bearer, time.Now())
+		if name, ok := strings.CutPrefix(payload, adminTokenPrefix); err == nil && ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// auditLog records an admin action together with the token name that made it.
+func auditLog(r *http.Request, msg string, args ...any) {
+	who, _ := r.Context().Value(adminIdentityKey{}).(string)
+	slog.InfoContext(r.Context(), "admin: "+msg, append([]any{"admin", who}, args...)...)
+}
+
+// POST /api/admin/pipeline/{stage} — fetch-blogs | scrape | analyze | notify | all
+func (s *Server) handleAdminPipeline(w http.ResponseWriter, r *http.Request) {
+	stage := r.PathValue("stage")
+	if !scheduler.ValidStage(stage) {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "unknown stage"})
+		return
+	}
+	if s.pipeline == nil {
+		writeJSON(w, http.StatusServiceUnavailable, apiError{Error: "pipeline not available"})
+		return
+	}
+	if err := s.pipeline.Trigger(stage); err != nil {
+		if errors.Is(err, scheduler.ErrBusy) {
+			writeJSON(w, http.StatusConflict, apiError{Error: err.Error()})
+			return
+		}
+		slog.Error("trigger stage", "stage", stage, "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to start stage"})
+		return
+	}
+	auditLog(r, "triggered stage", "stage", stage)
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "started", "stage": stage})
+}
+
+type apiAdminArticle struct {
+	apiArticle
+	Hidden bool `json:"hidden"`
+}
+
+func toAPIAdminArticle(a store.ArticleWithAnalysis) apiAdminArticle {
+	return apiAdminArticle{apiArticle: toAPIArticle(a), Hidden: a.ArticleAnalysis.HiddenAt != nil}
+}
+
+// POST /api/admin/articles/{id}/reanalyze
+func (s *Server) handleAdminReanalyze(w http.ResponseWriter, r *http.Request) {
+	id, ok := articleIDParam(w, r)
+	if !ok {
+		return
+	}
+	if s.pipeline == nil {
+		writeJSON(w, http.StatusServiceUnavailable, apiError{Error: "pipeline not available"})
+		return
+	}
+
+	article, err := s.pipeline.Reanalyze(r.Context(), id)
+	if err != nil {
+		slog.Error("reanalyze article", "article_id", id, "err", err)
+		writeJSON(w, http.StatusBadGateway, apiError{Error: "analysis failed: " + err.Error()})
+		return
+	}
+	if article == nil {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "article not found"})
+		return
+	}
+	auditLog(r, "re-analyzed article", "article_id", id)
+	writeJSON(w, http.StatusOK, map[string]any{"article": toAPIAdminArticle(*article)})
+}
+
+// PATCH /api/admin/articles/{id} — any of title_cn, ai_summary, recommend_reason,
+// category, keywords, relevance, quality, timeliness, hidden
+func (s *Server) handleAdminEditArticle(w http.ResponseWriter, r *http.Request) {
+	id, ok := articleIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	var edit store.AnalysisEdit
+	if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid JSON body"})
+		return
+	}
+	if err := edit.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+
+	updated, err := s.db.UpdateAnalysis(id, edit, interest.New(s.cfg.Interests).Adjustment)
+	if err != nil {
+		slog.Error("update analysis", "article_id", id, "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to update analysis"})
+		return
+	}
+	if !updated {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "analysis not found"})
+		return
+	}
+
+	article, err := s.db.GetArticleWithAnalysis(id)
+	if err != nil || article == nil {
+		slog.Error("reload article", "article_id", id, "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to load article"})
+		return
+	}
+	auditLog(r, "edited analysis", "article_id", id)
+	writeJSON(w, http.StatusOK, map[string]any{"article": toAPIAdminArticle(*article)})
+}
+
+func articleIDParam(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid article id"})
+		return 0, false
+	}
+	return id, true
+}
+
+type apiBlog struct {
+	Domain  string `json:"domain"`
+	Author  string `json:"author,omitempty"`
+	Rank    int    `json:"rank"`
+	Score   int    `json:"score"`
+	Source  string `json:"source"`
+	Muted   bool   `json:"muted"`
+	MutedAt string `json:"muted_at,omitempty"`
+}
+
+// GET /api/admin/blogs
+func (s *Server) handleAdminListBlogs(w http.ResponseWriter, r *http.Request) {
+	blogs, err := s.db.ListBlogs()
+	if err != nil {
+		slog.Error("list blogs", "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to load blogs"})
+		return
+	}
+	items := make([]apiBlog, len(blogs))
+	for i, b := range blogs {
+		items[i] = apiBlog{Domain: b.Domain, Author: b.Author, Rank: b.Rank, Score: b.Score, Source: b.Source, Muted: b.MutedAt != nil}
+		if b.MutedAt != nil {
+			items[i].MutedAt = fmtTimeRFC3339(*b.MutedAt)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"count": len(items), "blogs": items})
+}
+
+// POST /api/admin/blogs — {"domain":"example.com","author":"..."}
+func (s *Server) handleAdminAddBlog(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Domain string `json:"domain"`
+		Author string `json:"author"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid JSON body"})
+		return
+	}
+	domain, ok := normalizeDomain(body.Domain)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid domain"})
+		return
+	}
+
+	added, err := s.db.AddBlog(domain, body.Author)
+	if err != nil {
+		slog.Error("add blog", "domain", domain, "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to add blog"})
+		return
+	}
+	if !added {
+		writeJSON(w, http.StatusConflict, apiError{Error: "blog already exists"})
+		return
+	}
+	auditLog(r, "added blog", "domain", domain)
+	writeJSON(w, http.StatusCreated, map[string]string{"domain": domain})
+}
+
+// PATCH /api/admin/blogs/{domain} — {"muted":true|false}
+func (s *Server) handleAdminEditBlog(w http.ResponseWriter, r *http.Request) {
+	domain := r.PathValue("domain")
+	var body struct {
+		Muted *bool `json:"muted"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Muted == nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "muted is required"})
+		return
+	}
+
+	var err error
+	if *body.Muted {
+		err = s.db.MuteBlog(domain)
+	} else {
+		err = s.db.UnmuteBlog(domain)
+	}
+	if err != nil {
+		slog.Error("update blog", "domain", domain, "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to update blog"})
+		return
+	}
+	auditLog(r, "updated blog", "domain", domain, "muted", *body.Muted)
+	writeJSON(w, http.StatusOK, map[string]any{"domain": domain, "muted": *body.Muted})
+}
+
+// DELETE /api/admin/blogs/{domain} — manually added blogs only
+func (s *Server) handleAdminDeleteBlog(w http.ResponseWriter, r *http.Request) {
+	domain := r.PathValue("domain")
+	deleted, err := s.db.DeleteBlog(domain)
+	if err != nil {
+		slog.Error("delete blog", "domain", domain, "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to delete blog"})
+		return
+	}
+	if !deleted {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "no manually added blog with this domain"})
+		return
+	}
+	auditLog(r, "deleted blog", "domain", domain)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// normalizeDomain accepts "example.com" or a URL and returns the lower-case host.
+func normalizeDomain(v string) (string, bool) {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if _, after, ok := strings.Cut(v, "://"); ok {
+		v = after
+	}
+	v, _, _ = strings.Cut(v, "/")
+	if v == "" || !strings.Contains(v, ".") || strings.ContainsAny(v, " \t@?#") {
+		return "", false
+	}
+	return v, true
+}
+
+type apiSubscriber struct {
+	Email       string `json:"email"`
+	Status      string `json:"status"`
+	CreatedAt   string `json:"created_at"`
+	ConfirmedAt string `json:"confirmed_at,omitempty"`
+	LastSentAt  string `json:"last_sent_at,omitempty"`
+	BounceCount int    `json:"bounce_count"`
+	store.SubscriberPreferences
+}
+
+// GET /api/admin/subscribers?status=confirmed&format=csv
+func (s *Server) handleAdminSubscribers(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", store.SubscriberPending, store.SubscriberConfirmed, store.SubscriberSuspended:
+	default:
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "unknown status"})
+		return
+	}
+
+	subs, err := s.db.AllSubscribers(status)
+	if err != nil {
+		slog.Error("list subscribers", "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to load subscribers"})
+		return
+	}
+	items := make([]apiSubscriber, len(subs))
+	for i, sub := range subs {
+		items[i] = apiSubscriber{
+			Email:                 sub.Email,
+			Status:                sub.Status,
+			CreatedAt:             fmtTimeRFC3339(sub.CreatedAt),
+			BounceCount:           sub.BounceCount,
+			SubscriberPreferences: sub.SubscriberPreferences,
+		}
+		if sub.ConfirmedAt != nil {
+			items[i].ConfirmedAt = fmtTimeRFC3339(*sub.ConfirmedAt)
+		}
+		if sub.LastSentAt != nil {
+			items[i].LastSentAt = fmtTimeRFC3339(*sub.LastSentAt)
+		}
+	}
+	auditLog(r, "listed subscribers", "count", len(items))
+
+	if r.URL.Query().Get("format") != "csv" {
+		writeJSON(w, http.StatusOK, map[string]any{"count": len(items), "subscribers": items})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="subscribers.csv"`)
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"email", "status", "created_at", "confirmed_at", "last_sent_at", "bounce_count", "frequency", "categories", "min_score", "language"}) //nolint:errcheck
+	for _, it := range items {
+		cw.Write([]string{ //nolint:errcheck
+			it.Email, it.Status, it.CreatedAt, it.ConfirmedAt, it.LastSentAt, strconv.Itoa(it.BounceCount),
+			it.Frequency, strings.Join(it.Categories, ";"), strconv.Itoa(it.MinScore), it.Language,
+		})
+	}
+	cw.Flush()
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/chyiyaqing/newsbot/internal/store"
+)
+
+const (
+	analyticsDefaultDays = 14
+	analyticsMaxDays     = 366
+	// risingMinCount keeps one-off keywords out of the rising list.
+	risingMinCount = 2
+)
+
+type apiAnalyticsResponse struct {
+	From      string            `json:"from"`
+	To        string            `json:"to"`
+	Bucket    string            `json:"bucket"`
+	Dimension string            `json:"dimension"`
+	Series    []store.Series    `json:"series"`
+	Rising    []store.RisingKey `json:"rising"`
+}
+
+// GET /api/analytics/keywords?from=2026-10-01&to=2026-10-14&bucket=day&dimension=keyword&limit=10
+//
+// from and to are inclusive UTC dates; by default the last 14 days. dimension
+// is keyword (default), category or source. Rising keys compare from..to with
+// the same number of days before it.
+func (s *Server) handleAPIKeywordAnalytics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
+		return
+	}
+	q := r.URL.Query()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	to, err := parseDateParam(q.Get("to"), today)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid to date (use YYYY-MM-DD)"})
+		return
+	}
+	from, err := parseDateParam(q.Get("from"), to.AddDate(0, 0, -(analyticsDefaultDays-1)))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid from date (use YYYY-MM-DD)"})
+		return
+	}
+	end := to.AddDate(0, 0, 1) // to is inclusive
+	if !from.Before(end) || end.Sub(from) > analyticsMaxDays*24*time.Hour {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "from must be before to and at most 366 days apart"})
+		return
+	}
+
+	bucket := q.Get("bucket")
+	switch bucket {
+	case "":
+		bucket = store.BucketDay
+	case store.BucketDay, store.BucketWeek, store.BucketMonth:
+	default:
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "bucket must be day, week or month"})
+		return
+	}
+	dimension := q.Get("dimension")
+	switch dimension {
+	case "":
+		dimension = store.DimensionKeyword
+	case store.DimensionKeyword, store.DimensionCategory, store.DimensionSource:
+	default:
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "dimension must be keyword, category or source"})
+		return
+	}
+	limit := 10
+	if n, err := strconv.Atoi(q.Get("limit")); err == nil && n > 0 && n <= 50 {
+		limit = n
+	}
+
+	series, err := s.db.DimensionSeries(dimension, from, end, bucket, limit)
+	if err != nil {
+		slog.Error("api analytics series", "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to load analytics"})
+		return
+	}
+	rising, err := s.db.RisingKeys(dimension, from, end, risingMinCount, limit)
+	if err != nil {
+		slog.Error("api analytics rising", "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to load analytics"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, apiAnalyticsResponse{
+		From:      from.Format("2006-01-02"),
+		To:        to.Format("2006-01-02"),
+		Bucket:    bucket,
+		Dimension: dimension,
+		Series:    nonNil(series),
+		Rising:    nonNil(rising),
+	})
+}
+
+// parseDateParam parses a YYYY-MM-DD date, returning def when v is empty.
+func parseDateParam(v string, def time.Time) (time.Time, error) {
+	if v == "" {
+		return def, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
@@ -1,15 +1,22 @@
 package server
 
 import (
+	"crypto/subtle"
 	"encoding/json"
-	"log"
+	"fmt"
+	"html"
+	"log/slog"
 	"net/http"
+	"net/mail"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/chyiyaqing/newsbot/internal/notify/email"
+	"github.com/chyiyaqing/newsbot/internal/notify/telegram"
 	"github.com/chyiyaqing/newsbot/internal/store"
+	"github.com/chyiyaqing/newsbot/internal/token"
 )
 
 // JSON response types for the REST API.
@@ -29,8 +36,26 @@ type apiArticle struct {
 	Relevance       int    `json:"relevance"`
 	Quality         int    `json:"quality"`
 	Timeliness      int    `json:"timeliness"`
-	PublishedAt     string `json:"published_at"`
-	AnalyzedAt      string `json:"analyzed_at,omitempty"`
+	// ScoreAdjustment is the interest-profile part of TotalScore; only set
+	// on article detail.
+	ScoreAdjustment int `json:"score_adjustment,omitempty"`
+	// Model and PromptVersion produced the analysis; only set on article
+	// detail.
+	Model         string `json:"model,omitempty"`
+	PromptVersion string `json:"prompt_version,omitempty"`
+	Clicks        int    `json:"clicks"`
+	PublishedAt   string `json:"published_at"`
+	AnalyzedAt    string `json:"analyzed_at,omitempty"`
+	// Translation is set when a lang parameter is given and the article
+	// has a title in that language.
+	Translation *apiTranslation `json:"translation,omitempty"`
+}
+
+type apiTranslation struct {
+	Language string `json:"language"`
+	Title    string `json:"title"`
+	Summary  string `json:"summary,omitempty"`
+	Reason   string `json:"reason,omitempty"`
 }
 
 type apiListResponse struct {
@@ -47,12 +72,16 @@ type apiError struct {
 	Error string `json:"error"`
 }
 
-// GET /api/articles?window=24h|3days|7days&limit=20&category=AI/ML
+// GET /api/articles?window=24h|3days|7days&limit=20&category=AI/ML&lang=ja
 func (s *Server) handleAPIArticles(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
 		return
 	}
+	lang, ok := s.queryLanguage(w, r)
+	if !ok {
+		return
+	}
 
 	window := r.URL.Query().Get("window")
 	switch window {
@@ -80,7 +109,25 @@ func (s *Server) handleAPIArticles(w http.ResponseWriter, r *http.Request) {
 		articles, err = s.db.TopScoredArticles(limit, window)
 	}
 	if err != nil {
-		log.Printf("ERROR: api list articles: %v", err)
+		slog.Error("api list articles", "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to load articles"})
+		return
+	}
+
+	ids := make([]int64, len(articles))
+	for i, a := range articles {
+		ids[i] = a.Article.ID
+	}
+	clicks, err := s.db.ClickCounts(ids)
+	if err != nil {
+		slog.Error("api click counts", "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to load articles"})
+		return
+	}
+
+	translations, err := s.apiTranslations(articles, lang)
+	if err != nil {
+		slog.Error("api translations", "language", lang, "err", err)
 		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to load articles"})
 		return
 	}
@@ -88,6 +135,8 @@ func (s *Server) handleAPIArticles(w http.ResponseWriter, r *http.Request) {
 	items := make([]apiArticle, len(articles))
 	for i, a := range articles {
 		items[i] = toAPIArticle(a)
+		items[i].Clicks = clicks[a.Article.ID]
+		items[i].Translation = translations[a.Article.ID]
 	}
 
 	writeJSON(w, http.StatusOK, apiListResponse{
@@ -97,12 +146,16 @@ func (s *Server) handleAPIArticles(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// GET /api/articles/{id}
+// GET /api/articles/{id}?lang=ja
 func (s *Server) handleAPIArticleDetail(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
 		return
 	}
+	lang, ok := s.queryLanguage(w, r)
+	if !ok {
+		return
+	}
 
 	// Extract ID from path: /api/articles/123
 	idStr := strings.TrimPrefix(r.URL.Path, "/api/articles/")
@@ -114,16 +167,81 @@ func (s *Server) handleAPIArticleDetail(w http.ResponseWriter, r *http.Request)
 
 	article, err := s.db.GetArticleWithAnalysis(id)
 	if err != nil {
-		log.Printf("ERROR: api get article %d: %v", id, err)
+		slog.Error("api get article", "article_id", id, "err", err)
 		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to load article"})
 		return
 	}
-	if article == nil {
+	if article == nil || article.ArticleAnalysis.HiddenAt != nil {
 		writeJSON(w, http.StatusNotFound, apiError{Error: "article not found"})
 		return
 	}
 
-	writeJSON(w, http.StatusOK, apiDetailResponse{Article: toAPIArticle(*article)})
+	clicks, err := s.db.ClickCounts([]int64{id})
+	if err != nil {
+		slog.Error("api click counts", "article_id", id, "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to load article"})
+		return
+	}
+
+	translations, err := s.apiTranslations([]store.ArticleWithAnalysis{*article}, lang)
+	if err != nil {
+		slog.Error("api translations", "article_id", id, "language", lang, "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to load article"})
+		return
+	}
+
+	item := toAPIArticle(*article)
+	item.Clicks = clicks[id]
+	item.Translation = translations[id]
+	writeJSON(w, http.StatusOK, apiDetailResponse{Article: item})
+}
+
+// queryLanguage returns the lang query parameter, writing an error response
+// if it names a language articles are not translated into.
+func (s *Server) queryLanguage(w http.ResponseWriter, r *http.Request) (string, bool) {
+	lang := r.URL.Query().Get("lang")
+	if lang == "" || lang == store.LanguageChinese || lang == store.LanguageEnglish || slices.Contains(s.cfg.Languages, lang) {
+		return lang, true
+	}
+	writeJSON(w, http.StatusBadRequest, apiError{Error: "unsupported language: " + lang})
+	return "", false
+}
+
+// apiTranslations returns the articles' titles, summaries and reasons in
+// lang, keyed by article ID. Chinese and English come from the analysis;
+// other languages from stored translations. Articles without a title in
+// lang are left out.
+func (s *Server) apiTranslations(articles []store.ArticleWithAnalysis, lang string) (map[int64]*apiTranslation, error) {
+	result := make(map[int64]*apiTranslation)
+	switch lang {
+	case "":
+		return result, nil
+	case store.LanguageChinese:
+		for _, a := range articles {
+			if a.ArticleAnalysis.TitleCN != "" {
+				result[a.Article.ID] = &apiTranslation{Language: lang, Title: a.ArticleAnalysis.TitleCN, Reason: a.ArticleAnalysis.RecommendReason}
+			}
+		}
+		return result, nil
+	case store.LanguageEnglish:
+		for _, a := range articles {
+			result[a.Article.ID] = &apiTranslation{Language: lang, Title: a.Article.Title, Summary: a.ArticleAnalysis.AISummary}
+		}
+		return result, nil
+	}
+
+	ids := make([]int64, len(articles))
+	for i, a := range articles {
+		ids[i] = a.Article.ID
+	}
+	stored, err := s.db.Translations(ids, lang)
+	if err != nil {
+		return nil, err
+	}
+	for id, t := range stored {
+		result[id] = &apiTranslation{Language: t.Language, Title: t.Title, Summary: t.Summary, Reason: t.Reason}
+	}
+	return result, nil
 }
 
 func toAPIArticle(a store.ArticleWithAnalysis) apiArticle {
@@ -142,12 +260,20 @@ func toAPIArticle(a store.ArticleWithAnalysis) apiArticle {
 		Relevance:       a.ArticleAnalysis.Relevance,
 		Quality:         a.ArticleAnalysis.Quality,
 		Timeliness:      a.ArticleAnalysis.Timeliness,
+		ScoreAdjustment: a.ArticleAnalysis.ScoreAdjustment,
+		Model:           a.ArticleAnalysis.Model,
+		PromptVersion:   a.ArticleAnalysis.PromptVersion,
 		PublishedAt:     fmtTimeRFC3339(a.Article.PublishedAt),
 		AnalyzedAt:      fmtTimeRFC3339(a.ArticleAnalysis.AnalyzedAt),
 	}
 }
 
+// confirmResendCooldown is the minimum time between two confirmation emails
+// to the same address.
+const confirmResendCooldown = 5 * time.Minute
+
 // POST /api/subscribe — {"email":"user@example.com"}
+// New addresses stay pending until the link in the confirmation email is opened.
 func (s *Server) handleSubscribe(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
@@ -161,54 +287,140 @@ func (s *Server) handleSubscribe(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, apiError{Error: "email is required"})
 		return
 	}
-	if !strings.Contains(body.Email, "@") {
+	addr, ok := parseEmail(body.Email)
+	if !ok {
 		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid email"})
 		return
 	}
 
-	token, err := email.GenerateToken()
+	unsubToken, err := email.GenerateToken()
 	if err != nil {
-		log.Printf("ERROR: generate subscribe token: %v", err)
+		slog.Error("generate subscribe token", "err", err)
 		writeJSON(w, http.StatusInternalServerError, apiError{Error: "internal error"})
 		return
 	}
 
-	inserted, err := s.db.AddSubscriber(body.Email, token)
+	inserted, err := s.db.AddSubscriber(addr, unsubToken)
 	if err != nil {
-		log.Printf("ERROR: add subscriber: %v", err)
+		slog.Error("add subscriber", "err", err)
 		writeJSON(w, http.StatusInternalServerError, apiError{Error: "internal error"})
 		return
 	}
-
 	if inserted {
-		log.Printf("New subscriber: %s", body.Email)
-		if s.emailCl != nil {
-			go sendWelcomeEmail(s.emailCl, body.Email, token)
+		slog.Info("new pending subscriber", "email", addr)
+	}
+
+	sub, err := s.db.GetSubscriberByEmail(addr)
+	if err != nil || sub == nil {
+		slog.Error("get subscriber", "email", addr, "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "internal error"})
+		return
+	}
+
+	// (Re)send the confirmation link while pending, or to reactivate an
+	// address suspended after bounces, at most once per cooldown so the
+	// endpoint cannot be used to flood someone's inbox. The response is the
+	// same either way so the endpoint does not reveal who is subscribed.
+	if (sub.Status == store.SubscriberPending || sub.Status == store.SubscriberSuspended) && s.emailCl != nil {
+		send, err := s.db.ClaimConfirmationSend(addr, confirmResendCooldown)
+		if err != nil {
+			slog.Error("claim confirmation send", "email", addr, "err", err)
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "internal error"})
+			return
+		}
+		if send {
+			confirmToken := token.Sign(s.confirmSecret, addr, time.Now().Add(s.cfg.SMTP.ConfirmWindow()))
+			go sendConfirmationEmail(s.emailCl, addr, confirmToken)
+		} else {
+			slog.Info("confirmation email recently sent, skipping", "email", addr)
 		}
 	}
-	writeJSON(w, http.StatusOK, map[string]string{"status": "subscribed"})
+	writeJSON(w, http.StatusOK, map[string]string{"status": "pending_confirmation"})
 }
 
-// GET /api/unsubscribe?token=xxx
-func (s *Server) handleUnsubscribe(w http.ResponseWriter, r *http.Request) {
-	token := r.URL.Query().Get("token")
-	if token == "" {
+// GET /api/confirm?token=xxx
+func (s *Server) handleConfirm(w http.ResponseWriter, r *http.Request) {
+	tok := r.URL.Query().Get("token")
+	if tok == "" {
 		http.Error(w, "missing token", http.StatusBadRequest)
 		return
 	}
 
-	removed, err := s.db.RemoveSubscriberByToken(token)
+	addr, err := token.Verify(s.confirmSecret, tok, time.Now())
+	if err != nil {
+		writeHTMLPage(w, "链接已失效", "该确认链接无效或已过期，请重新订阅。")
+		return
+	}
+
+	confirmed, err := s.db.ConfirmSubscriber(addr)
 	if err != nil {
-		log.Printf("ERROR: remove subscriber: %v", err)
+		slog.Error("confirm subscriber", "err", err)
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if removed {
-		w.Write([]byte(`<!DOCTYPE html><html><body style="font-family:sans-serif;text-align:center;padding:60px"><h2>已成功取消订阅</h2><p style="color:#64748b">您将不再收到 NewsBot 的邮件通知。</p></body></html>`)) //nolint:errcheck
-	} else {
-		w.Write([]byte(`<!DOCTYPE html><html><body style="font-family:sans-serif;text-align:center;padding:60px"><h2>链接已失效</h2><p style="color:#64748b">该取消订阅链接无效或已使用过。</p></body></html>`)) //nolint:errcheck
+	sub, err := s.db.GetSubscriberByEmail(addr)
+	if err != nil {
+		slog.Error("get subscriber", "email", addr, "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	switch {
+	case confirmed && sub != nil:
+		slog.Info("subscriber confirmed", "email", addr)
+		if s.emailCl != nil {
+			go sendWelcomeEmail(s.emailCl, sub.Email, sub.Token)
+		}
+		writeHTMLPage(w, "订阅成功", "您已成功订阅 NewsBot 技术资讯。")
+	case sub != nil && sub.Status == store.SubscriberConfirmed:
+		writeHTMLPage(w, "已确认", "您的订阅此前已确认，无需重复操作。")
+	default:
+		writeHTMLPage(w, "链接已失效", "该确认链接无效或已过期，请重新订阅。")
+	}
+}
+
+// GET  /api/unsubscribe?token=xxx — confirmation page (safe for link scanners)
+// POST /api/unsubscribe?token=xxx — unsubscribe; also the RFC 8058 one-click
+// target of the List-Unsubscribe header (body: List-Unsubscribe=One-Click)
+func (s *Server) handleUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "missing token", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		sub, err := s.db.GetSubscriberByToken(token)
+		if err != nil {
+			slog.Error("get subscriber by token", "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if sub == nil {
+			writeHTMLPage(w, "链接已失效", "该取消订阅链接无效或已使用过。")
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, `<!DOCTYPE html><html><body style="font-family:sans-serif;text-align:center;padding:60px"><h2>取消订阅</h2><p style="color:#64748b">确定不再接收发往 %s 的 NewsBot 邮件？</p><form method="POST"><button type="submit" style="background:#0f172a;color:#fff;border:0;padding:8px 20px;border-radius:6px">确认取消订阅</button></form></body></html>`,
+			html.EscapeString(sub.Email))
+
+	case http.MethodPost:
+		removed, err := s.db.RemoveSubscriberByToken(token)
+		if err != nil {
+			slog.Error("remove subscriber", "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if removed {
+			writeHTMLPage(w, "已成功取消订阅", "您将不再收到 NewsBot 的邮件通知。")
+		} else {
+			writeHTMLPage(w, "链接已失效", "该取消订阅链接无效或已使用过。")
+		}
+
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
 	}
 }
 
@@ -228,7 +440,7 @@ func (s *Server) handleAPICategories(w http.ResponseWriter, r *http.Request) {
 
 	categories, err := s.db.CategoriesForWindow(window)
 	if err != nil {
-		log.Printf("ERROR: api categories: %v", err)
+		slog.Error("api categories", "err", err)
 		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to load categories"})
 		return
 	}
@@ -255,7 +467,7 @@ func (s *Server) handleAPIStats(w http.ResponseWriter, r *http.Request) {
 
 	stats, err := s.db.StatsForWindow(window)
 	if err != nil {
-		log.Printf("ERROR: api stats: %v", err)
+		slog.Error("api stats", "err", err)
 		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to load stats"})
 		return
 	}
@@ -266,9 +478,85 @@ func (s *Server) handleAPIStats(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// GET /api/feedback/stats?window=24h|3days|7days
+func (s *Server) handleAPIFeedbackStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
+		return
+	}
+
+	window := r.URL.Query().Get("window")
+	switch window {
+	case "24h", "3days", "7days":
+	default:
+		window = "24h"
+	}
+
+	stats, err := s.db.FeedbackStatsForWindow(window, 20)
+	if err != nil {
+		slog.Error("api feedback stats", "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to load feedback stats"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"window": window,
+		"stats":  stats,
+	})
+}
+
+// POST /api/telegram/webhook — Telegram Bot API updates (callback queries)
+func (s *Server) handleTelegramWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
+		return
+	}
+	webhookSecret := s.cfg.Telegram.WebhookSecret
+	if s.tg == nil || webhookSecret == "" {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "telegram webhook not configured"})
+		return
+	}
+	secret := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(webhookSecret)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, apiError{Error: "invalid secret token"})
+		return
+	}
+
+	var u telegram.Update
+	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid update"})
+		return
+	}
+
+	// Always acknowledge so Telegram does not redeliver the update.
+	if err := s.tg.HandleUpdate(r.Context(), s.db, u); err != nil {
+		slog.Warn("telegram update", "channel", "telegram", "update_id", u.UpdateID, "err", err)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// parseEmail validates a bare RFC 5322 address (no display name) and returns it.
+func parseEmail(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	addr, err := mail.ParseAddress(raw)
+	if err != nil || addr.Name != "" || addr.Address != raw {
+		return "", false
+	}
+	if _, domain, ok := strings.Cut(addr.Address, "@"); !ok || !strings.Contains(domain, ".") {
+		return "", false
+	}
+	return addr.Address, true
+}
+
+func sendConfirmationEmail(cl EmailClient, to, confirmToken string) {
+	if err := cl.SendConfirmation(to, confirmToken); err != nil {
+		slog.Warn("send confirmation email", "channel", "email", "email", to, "err", err)
+	}
+}
+
 func sendWelcomeEmail(cl EmailClient, to, token string) {
 	if err := cl.SendWelcome(to, token); err != nil {
-		log.Printf("WARNING: send welcome email to %s: %v", to, err)
+		slog.Warn("send welcome email", "channel", "email", "email", to, "err", err)
 	}
 }
 
@@ -279,6 +567,12 @@ func fmtTimeRFC3339(t time.Time) string {
 	return t.UTC().Format(time.RFC3339)
 }
 
+func writeHTMLPage(w http.ResponseWriter, title, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `<!DOCTYPE html><html><body style="font-family:sans-serif;text-align:center;padding:60px"><h2>%s</h2><p style="color:#64748b">%s</p></body></html>`,
+		html.EscapeString(title), html.EscapeString(message))
+}
+
 func writeJSON(w http.ResponseWriter, status int, v any) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(status)
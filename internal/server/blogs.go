@@ -0,0 +1,89 @@
+package server
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/chyiyaqing/newsbot/internal/sources"
+)
+
+type apiBlogStats struct {
+	Domain        string  `json:"domain"`
+	Source        string  `json:"source"`
+	Muted         bool    `json:"muted"`
+	Demoted       bool    `json:"demoted"`
+	Days          int     `json:"days"`
+	Articles      int     `json:"articles"`
+	Analyzed      int     `json:"analyzed"`
+	MeanScore     float64 `json:"mean_score"`
+	MedianScore   float64 `json:"median_score"`
+	HitScore      int     `json:"hit_score"`
+	Hits          int     `json:"hits"`
+	HitRate       float64 `json:"hit_rate"`
+	Fetches       int     `json:"fetches"`
+	FetchErrors   int     `json:"fetch_errors"`
+	ErrorRate     float64 `json:"error_rate"`
+	PostsPerWeek  float64 `json:"posts_per_week"`
+	LastPublished string  `json:"last_published,omitempty"`
+	LastFetched   string  `json:"last_fetched,omitempty"`
+	// Verdict is the source policy's judgement: ok, low_score or dead.
+	Verdict string `json:"verdict"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// GET /api/blogs/{domain}/stats?days=90
+func (s *Server) handleAPIBlogStats(w http.ResponseWriter, r *http.Request) {
+	policy := s.cfg.Sources
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 365 {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "days must be between 1 and 365"})
+			return
+		}
+		policy.Days = n
+	}
+	now := time.Now()
+	since := policy.Window(now)
+
+	domain := r.PathValue("domain")
+	st, err := s.db.BlogStatsFor(domain, since, policy.HitScore)
+	if err != nil {
+		slog.Error("api blog stats", "domain", domain, "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to load blog stats"})
+		return
+	}
+	if st == nil {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "blog not found"})
+		return
+	}
+
+	resp := apiBlogStats{
+		Domain:       st.Domain,
+		Source:       st.Source,
+		Muted:        st.MutedAt != nil,
+		Demoted:      st.DemotedAt != nil,
+		Days:         int(math.Round(now.Sub(since).Hours() / 24)),
+		Articles:     st.Articles,
+		Analyzed:     st.Analyzed,
+		MeanScore:    st.MeanScore,
+		MedianScore:  st.MedianScore,
+		HitScore:     policy.HitScore,
+		Hits:         st.Hits,
+		HitRate:      st.HitRate,
+		Fetches:      st.Fetches,
+		FetchErrors:  st.FetchErrors,
+		ErrorRate:    st.ErrorRate,
+		PostsPerWeek: st.PostsPerWeek,
+	}
+	if st.LastPublished != nil {
+		resp.LastPublished = fmtTimeRFC3339(*st.LastPublished)
+	}
+	if st.LastFetched != nil {
+		resp.LastFetched = fmtTimeRFC3339(*st.LastFetched)
+	}
+	resp.Verdict, resp.Reason = sources.Judge(policy, *st)
+	writeJSON(w, http.StatusOK, resp)
+}
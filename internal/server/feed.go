@@ -0,0 +1,412 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chyiyaqing/newsbot/internal/store"
+)
+
+const (
+	feedTitle       = "Newsbot"
+	feedDescription = "AI-curated articles from popular tech blogs"
+	feedLimit       = 50
+	// feedMaxAge is how long readers and proxies may cache a feed.
+	feedMaxAge = 5 * time.Minute
+)
+
+// feedQuery is the article selection shared by all feed formats.
+type feedQuery struct {
+	window   string
+	category string
+	minScore int
+	limit    int
+}
+
+func parseFeedQuery(r *http.Request) feedQuery {
+	q := feedQuery{window: "24h", limit: feedLimit}
+	switch w := r.URL.Query().Get("window"); w {
+	case "24h", "3days", "7days":
+		q.window = w
+	}
+	q.category = r.URL.Query().Get("category")
+	if n, err := strconv.Atoi(r.URL.Query().Get("min_score")); err == nil && n > 0 {
+		q.minScore = n
+	}
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 && n <= 100 {
+		q.limit = n
+	}
+	return q
+}
+
+// feedArticles loads the top articles for q. Articles come back sorted by
+// score, so the minimum score cuts off a tail of the list.
+func (s *Server) feedArticles(q feedQuery) ([]store.ArticleWithAnalysis, error) {
+	var (
+		articles []store.ArticleWithAnalysis
+		err      error
+	)
+	if q.category != "" {
+		articles, err = s.db.TopScoredArticlesByCategory(q.limit, q.window, q.category)
+	} else {
+		articles, err = s.db.TopScoredArticles(q.limit, q.window)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, a := range articles {
+		if a.ArticleAnalysis.TotalScore < q.minScore {
+			return articles[:i], nil
+		}
+	}
+	return articles, nil
+}
+
+// feedBaseURL is the public site URL, taken from SITE_URL or, failing that,
+// from the request as seen through a trusted reverse proxy. fromRequest
+// reports the latter, as the Host header is then client-supplied.
+func (s *Server) feedBaseURL(r *http.Request) (base string, fromRequest bool) {
+	if s.cfg.SMTP.SiteURL != "" {
+		return strings.TrimRight(s.cfg.SMTP.SiteURL, "/"), false
+	}
+	scheme := "http"
+	if p := r.Header.Get("X-Forwarded-Proto"); (p == "http" || p == "https") && s.fromTrustedProxy(r) {
+		scheme = p
+	} else if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host, true
+}
+
+// feedItemHTML renders the analysis of an article as the HTML body of a feed item.
+func feedItemHTML(a store.ArticleWithAnalysis) string {
+	an := a.ArticleAnalysis
+	var b strings.Builder
+	if an.TitleCN != "" {
+		fmt.Fprintf(&b, "<p><strong>%s</strong></p>", html.EscapeString(an.TitleCN))
+	}
+	if an.AISummary != "" {
+		fmt.Fprintf(&b, "<p>%s</p>", html.EscapeString(an.AISummary))
+	}
+	if an.RecommendReason != "" {
+		fmt.Fprintf(&b, "<p>💡 %s</p>", html.EscapeString(an.RecommendReason))
+	}
+	fmt.Fprintf(&b, "<p>%s · %s · %d/30</p>",
+		html.EscapeString(a.Article.BlogDomain), html.EscapeString(an.Category), an.TotalScore)
+	return b.String()
+}
+
+func feedItemTitle(a store.ArticleWithAnalysis) string {
+	if a.ArticleAnalysis.TitleCN != "" && a.ArticleAnalysis.TitleCN != a.Article.Title {
+		return a.Article.Title + " | " + a.ArticleAnalysis.TitleCN
+	}
+	return a.Article.Title
+}
+
+// feedUpdated is the latest analysis time, used as the feed's modification time.
+func feedUpdated(articles []store.ArticleWithAnalysis) time.Time {
+	var t time.Time
+	for _, a := range articles {
+		if a.ArticleAnalysis.AnalyzedAt.After(t) {
+			t = a.ArticleAnalysis.AnalyzedAt
+		}
+	}
+	if t.IsZero() {
+		t = time.Unix(0, 0)
+	}
+	return t.UTC()
+}
+
+// serveFeed loads the articles, renders them with build and writes the
+// result with caching headers, answering conditional requests with 304.
+func (s *Server) serveFeed(w http.ResponseWriter, r *http.Request, contentType string,
+	build func(base, self string, articles []store.ArticleWithAnalysis, updated time.Time) ([]byte, error)) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
+		return
+	}
+
+	articles, err := s.feedArticles(parseFeedQuery(r))
+	if err != nil {
+		slog.Error("feed", "path", r.URL.Path, "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to load articles"})
+		return
+	}
+	base, fromRequest := s.feedBaseURL(r)
+	updated := feedUpdated(articles)
+	body, err := build(base, base+r.URL.RequestURI(), articles, updated)
+	if err != nil {
+		slog.Error("feed", "path", r.URL.Path, "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to render feed"})
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	h := w.Header()
+	h.Set("Content-Type", contentType)
+	// Links built from the request's Host must not be shared with other
+	// clients through a cache.
+	cache := "public"
+	if fromRequest {
+		cache = "private"
+	}
+	h.Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", cache, int(feedMaxAge.Seconds())))
+	h.Set("ETag", etag)
+	h.Set("Last-Modified", updated.Format(http.TimeFormat))
+
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if match == "*" || strings.Contains(match, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !updated.After(since) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(body) //nolint:errcheck
+}
+
+// RSS 2.0
+
+type rssDoc struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	SelfLink      atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	TTL           int       `xml:"ttl"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Category    string  `xml:"category,omitempty"`
+	Description string  `xml:"description"`
+}
+
+// GET /feed.xml?window=24h&category=AI/ML&min_score=20
+func (s *Server) handleRSSFeed(w http.ResponseWriter, r *http.Request) {
+	s.serveFeed(w, r, "application/rss+xml; charset=utf-8", func(base, self string, articles []store.ArticleWithAnalysis, updated time.Time) ([]byte, error) {
+		doc := rssDoc{
+			Version: "2.0",
+			AtomNS:  "http://www.w3.org/2005/Atom",
+			Channel: rssChannel{
+				Title:         feedTitle,
+				Link:          base + "/",
+				Description:   feedDescription,
+				SelfLink:      atomLink{Href: self, Rel: "self", Type: "application/rss+xml"},
+				LastBuildDate: updated.Format(time.RFC1123Z),
+				TTL:           int(feedMaxAge.Minutes()),
+			},
+		}
+		for _, a := range articles {
+			doc.Channel.Items = append(doc.Channel.Items, rssItem{
+				Title:       feedItemTitle(a),
+				Link:        a.Article.URL,
+				GUID:        rssGUID{IsPermaLink: true, Value: a.Article.URL},
+				PubDate:     a.Article.PublishedAt.UTC().Format(time.RFC1123Z),
+				Category:    a.ArticleAnalysis.Category,
+				Description: feedItemHTML(a),
+			})
+		}
+		return marshalXML(doc)
+	})
+}
+
+// Atom 1.0
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomPerson  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     atomPerson     `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Summary    atomText       `xml:"summary"`
+}
+
+// GET /atom.xml?window=24h&category=AI/ML&min_score=20
+func (s *Server) handleAtomFeed(w http.ResponseWriter, r *http.Request) {
+	s.serveFeed(w, r, "application/atom+xml; charset=utf-8", func(base, self string, articles []store.ArticleWithAnalysis, updated time.Time) ([]byte, error) {
+		feed := atomFeed{
+			ID:      self,
+			Title:   feedTitle,
+			Updated: updated.Format(time.RFC3339),
+			Links: []atomLink{
+				{Href: self, Rel: "self", Type: "application/atom+xml"},
+				{Href: base + "/", Rel: "alternate", Type: "text/html"},
+			},
+			Author: atomPerson{Name: feedTitle},
+		}
+		for _, a := range articles {
+			entry := atomEntry{
+				ID:        a.Article.URL,
+				Title:     feedItemTitle(a),
+				Link:      atomLink{Href: a.Article.URL, Rel: "alternate"},
+				Published: a.Article.PublishedAt.UTC().Format(time.RFC3339),
+				Updated:   a.ArticleAnalysis.AnalyzedAt.UTC().Format(time.RFC3339),
+				Author:    atomPerson{Name: a.Article.BlogDomain},
+				Summary:   atomText{Type: "html", Value: feedItemHTML(a)},
+			}
+			if c := a.ArticleAnalysis.Category; c != "" {
+				entry.Categories = append(entry.Categories, atomCategory{Term: c})
+			}
+			feed.Entries = append(feed.Entries, entry)
+		}
+		return marshalXML(feed)
+	})
+}
+
+func marshalXML(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// JSON Feed 1.1 (https://jsonfeed.org/version/1.1)
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Description string         `json:"description"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Title         string           `json:"title"`
+	ContentHTML   string           `json:"content_html"`
+	Summary       string           `json:"summary,omitempty"`
+	DatePublished string           `json:"date_published"`
+	DateModified  string           `json:"date_modified,omitempty"`
+	Authors       []jsonFeedAuthor `json:"authors"`
+	Tags          []string         `json:"tags,omitempty"`
+	Newsbot       jsonFeedNewsbot  `json:"_newsbot"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+// jsonFeedNewsbot is a JSON Feed extension object with the raw analysis.
+type jsonFeedNewsbot struct {
+	TitleCN         string `json:"title_cn,omitempty"`
+	RecommendReason string `json:"recommend_reason,omitempty"`
+	Category        string `json:"category,omitempty"`
+	TotalScore      int    `json:"total_score"`
+}
+
+// GET /feed.json?window=24h&category=AI/ML&min_score=20
+func (s *Server) handleJSONFeed(w http.ResponseWriter, r *http.Request) {
+	s.serveFeed(w, r, "application/feed+json; charset=utf-8", func(base, self string, articles []store.ArticleWithAnalysis, _ time.Time) ([]byte, error) {
+		feed := jsonFeed{
+			Version:     "https://jsonfeed.org/version/1.1",
+			Title:       feedTitle,
+			HomePageURL: base + "/",
+			FeedURL:     self,
+			Description: feedDescription,
+			Items:       []jsonFeedItem{},
+		}
+		for _, a := range articles {
+			an := a.ArticleAnalysis
+			item := jsonFeedItem{
+				ID:            a.Article.URL,
+				URL:           a.Article.URL,
+				Title:         feedItemTitle(a),
+				ContentHTML:   feedItemHTML(a),
+				Summary:       an.AISummary,
+				DatePublished: a.Article.PublishedAt.UTC().Format(time.RFC3339),
+				DateModified:  fmtTimeRFC3339(an.AnalyzedAt),
+				Authors:       []jsonFeedAuthor{{Name: a.Article.BlogDomain}},
+				Newsbot: jsonFeedNewsbot{
+					TitleCN:         an.TitleCN,
+					RecommendReason: an.RecommendReason,
+					Category:        an.Category,
+					TotalScore:      an.TotalScore,
+				},
+			}
+			for _, kw := range strings.Split(an.Keywords, ",") {
+				if kw = strings.TrimSpace(kw); kw != "" {
+					item.Tags = append(item.Tags, kw)
+				}
+			}
+			feed.Items = append(feed.Items, item)
+		}
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		err := enc.Encode(feed)
+		return buf.Bytes(), err
+	})
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/chyiyaqing/newsbot/internal/metrics"
+	"github.com/chyiyaqing/newsbot/internal/store"
+	"github.com/chyiyaqing/newsbot/internal/token"
+	"github.com/chyiyaqing/newsbot/internal/track"
+)
+
+// POST /api/articles/{id}/feedback — {"action":"up"} or {"action":"down"}
+// Votes are kept per reader, identified by a keyed hash of their address.
+func (s *Server) handleAPIArticleFeedback(w http.ResponseWriter, r *http.Request) {
+	article, ok := s.pathArticle(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Action string `json:"action"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request body"})
+		return
+	}
+	if body.Action != store.FeedbackUp && body.Action != store.FeedbackDown {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "action must be up or down"})
+		return
+	}
+
+	if err := s.db.SaveFeedback(store.Feedback{
+		ArticleID: article.ID,
+		Action:    body.Action,
+		Channel:   "web",
+		UserRef:   s.readerRef(r),
+	}); err != nil {
+		slog.Error("api save feedback", "article_id", article.ID, "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to save feedback"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"action": body.Action})
+}
+
+// GET /api/articles/{id}/open — records a click and redirects to the article.
+func (s *Server) handleAPIArticleOpen(w http.ResponseWriter, r *http.Request) {
+	article, ok := s.pathArticle(w, r)
+	if !ok {
+		return
+	}
+	if err := s.db.SaveClick(store.Click{
+		ArticleID: article.ID,
+		Channel:   "web",
+		UserRef:   s.readerRef(r),
+	}); err != nil {
+		// Losing a click must not keep the reader from the article.
+		slog.Error("api save click", "article_id", article.ID, "err", err)
+	}
+	metrics.ArticleClicks.Inc("web")
+	http.Redirect(w, r, article.URL, http.StatusFound)
+}
+
+// GET /r/{token} — digest link: records the click with its channel and
+// hashed recipient and redirects to the article.
+func (s *Server) handleClickRedirect(w http.ResponseWriter, r *http.Request) {
+	click, err := track.Parse(s.confirmSecret, r.PathValue("token"), time.Now())
+	if err != nil {
+		writeHTMLPage(w, "链接已失效", "该文章链接无效或已过期。")
+		return
+	}
+	article, err := s.db.GetArticle(click.ArticleID)
+	if err != nil {
+		slog.Error("get article", "article_id", click.ArticleID, "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if article == nil {
+		writeHTMLPage(w, "文章不存在", "该文章已被删除。")
+		return
+	}
+
+	if err := s.db.SaveClick(store.Click{
+		ArticleID: article.ID,
+		Channel:   click.Channel,
+		UserRef:   click.UserRef,
+	}); err != nil {
+		slog.Error("save click", "article_id", article.ID, "channel", click.Channel, "err", err)
+	}
+	metrics.ArticleClicks.Inc(click.Channel)
+	http.Redirect(w, r, article.URL, http.StatusFound)
+}
+
+// pathArticle loads the article named by the {id} path value, writing an
+// error response if it is invalid or missing.
+func (s *Server) pathArticle(w http.ResponseWriter, r *http.Request) (*store.Article, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid article id"})
+		return nil, false
+	}
+	article, err := s.db.GetArticle(id)
+	if err != nil {
+		slog.Error("api get article", "article_id", id, "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to load article"})
+		return nil, false
+	}
+	if article == nil {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "article not found"})
+		return nil, false
+	}
+	return article, true
+}
+
+// readerRef identifies an anonymous web reader by a keyed hash of their
+// address and user agent, so repeated votes replace each other without
+// storing the address itself.
+func (s *Server) readerRef(r *http.Request) string {
+	h := hmac.New(sha256.New, token.Derive(s.confirmSecret, "reader"))
+	h.Write([]byte(s.clientAddr(r) + "\x00" + r.UserAgent()))
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+type apiArticleHistory struct {
+	ArticleID int64                `json:"article_id"`
+	Title     string               `json:"title"`
+	Versions  []apiAnalysisVersion `json:"versions"`
+}
+
+type apiAnalysisVersion struct {
+	ID int64 `json:"id"`
+	// Origin is "model" for scoring and summary results, "edit" for
+	// moderator edits and "rescore" for scores recomputed after the interest
+	// profile changed.
+	Origin          string `json:"origin"`
+	Model           string `json:"model,omitempty"`
+	PromptVersion   string `json:"prompt_version,omitempty"`
+	TotalScore      int    `json:"total_score"`
+	Relevance       int    `json:"relevance"`
+	Quality         int    `json:"quality"`
+	Timeliness      int    `json:"timeliness"`
+	ScoreAdjustment int    `json:"score_adjustment,omitempty"`
+	Category        string `json:"category,omitempty"`
+	Keywords        string `json:"keywords,omitempty"`
+	TitleCN         string `json:"title_cn,omitempty"`
+	AISummary       string `json:"ai_summary,omitempty"`
+	RecommendReason string `json:"recommend_reason,omitempty"`
+	AnalyzedAt      string `json:"analyzed_at"`
+}
+
+// GET /api/articles/{id}/history — every recorded analysis of an article,
+// newest first.
+func (s *Server) handleAPIArticleHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid article id"})
+		return
+	}
+	article, err := s.db.GetArticleWithAnalysis(id)
+	if err != nil {
+		slog.Error("api get article", "article_id", id, "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to load article"})
+		return
+	}
+	if article == nil || article.ArticleAnalysis.HiddenAt != nil {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "article not found"})
+		return
+	}
+
+	versions, err := s.db.AnalysisHistory(id)
+	if err != nil {
+		slog.Error("api analysis history", "article_id", id, "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to load analysis history"})
+		return
+	}
+	resp := apiArticleHistory{ArticleID: id, Title: article.Article.Title, Versions: make([]apiAnalysisVersion, len(versions))}
+	for i, v := range versions {
+		resp.Versions[i] = apiAnalysisVersion{
+			ID:              v.ID,
+			Origin:          v.Origin,
+			Model:           v.Model,
+			PromptVersion:   v.PromptVersion,
+			TotalScore:      v.TotalScore,
+			Relevance:       v.Relevance,
+			Quality:         v.Quality,
+			Timeliness:      v.Timeliness,
+			ScoreAdjustment: v.ScoreAdjustment,
+			Category:        v.Category,
+			Keywords:        v.Keywords,
+			TitleCN:         v.TitleCN,
+			AISummary:       v.AISummary,
+			RecommendReason: v.RecommendReason,
+			AnalyzedAt:      fmtTimeRFC3339(v.AnalyzedAt),
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -4,41 +4,96 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
 	"time"
 
+	"github.com/chyiyaqing/newsbot/internal/config"
+	"github.com/chyiyaqing/newsbot/internal/events"
+	"github.com/chyiyaqing/newsbot/internal/metrics"
+	"github.com/chyiyaqing/newsbot/internal/notify/telegram"
 	"github.com/chyiyaqing/newsbot/internal/store"
 )
 
 type Server struct {
-	db      *store.Store
-	emailCl EmailClient
-	srv     *http.Server
+	db            *store.Store
+	cfg           *config.Config
+	emailCl       EmailClient
+	tg            TelegramClient
+	pipeline      Pipeline
+	events        *events.Bus
+	confirmSecret []byte
+	proxies       []netip.Prefix
+	srv           *http.Server
 }
 
 // EmailClient is a minimal interface for sending HTML emails.
 type EmailClient interface {
 	SendHTML(to, subject, body string) error
 	SendWelcome(to, token string) error
+	SendConfirmation(to, confirmToken string) error
 }
 
-func New(db *store.Store, addr string, emailCl EmailClient) *Server {
-	s := &Server{db: db, emailCl: emailCl}
+// TelegramClient handles updates delivered to the Telegram webhook.
+type TelegramClient interface {
+	HandleUpdate(ctx context.Context, db *store.Store, u telegram.Update) error
+}
+
+// New creates the HTTP server. emailCl and tg may be nil when email or the
+// Telegram webhook are not configured; pipeline and bus may be nil when the
+// server runs without the scheduler. cfg.SMTP.ConfirmSecret must be set, as
+// it also verifies the links the scheduler signs.
+func New(db *store.Store, cfg *config.Config, addr string, emailCl EmailClient, tg TelegramClient, pipeline Pipeline, bus *events.Bus) *Server {
+	s := &Server{db: db, cfg: cfg, emailCl: emailCl, tg: tg, pipeline: pipeline, events: bus}
+
+	s.confirmSecret = []byte(cfg.SMTP.ConfirmSecret)
+	s.proxies = parseProxies(cfg.Server.TrustedProxies)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", s.handleHealth)
+	mux.Handle("/metrics", metrics.Handler())
 	mux.HandleFunc("/api/articles", s.handleAPIArticles)
 	mux.HandleFunc("/api/articles/", s.handleAPIArticleDetail)
+	mux.HandleFunc("POST /api/articles/{id}/feedback", s.handleAPIArticleFeedback)
+	mux.HandleFunc("GET /api/articles/{id}/open", s.handleAPIArticleOpen)
+	mux.HandleFunc("GET /api/articles/{id}/history", s.handleAPIArticleHistory)
 	mux.HandleFunc("/api/categories", s.handleAPICategories)
+	mux.HandleFunc("GET /api/blogs/{domain}/stats", s.handleAPIBlogStats)
 	mux.HandleFunc("/api/stats", s.handleAPIStats)
+	mux.HandleFunc("/api/trends", s.handleAPITrends)
+	mux.HandleFunc("/api/trends/history", s.handleAPITrendHistory)
+	mux.HandleFunc("/api/analytics/keywords", s.handleAPIKeywordAnalytics)
+	mux.HandleFunc("/api/usage", s.handleAPIUsage)
 	mux.HandleFunc("/api/subscribe", s.handleSubscribe)
+	mux.HandleFunc("/api/confirm", s.handleConfirm)
 	mux.HandleFunc("/api/unsubscribe", s.handleUnsubscribe)
+	mux.HandleFunc("/api/subscription", s.handleSubscription)
+	mux.HandleFunc("/api/feedback/stats", s.handleAPIFeedbackStats)
+	mux.HandleFunc("/api/telegram/webhook", s.handleTelegramWebhook)
+	mux.HandleFunc("/api/stream", s.handleStream)
+	mux.HandleFunc("/feed.xml", s.handleRSSFeed)
+	mux.HandleFunc("/atom.xml", s.handleAtomFeed)
+	mux.HandleFunc("/feed.json", s.handleJSONFeed)
+	mux.HandleFunc("GET /r/{token}", s.handleClickRedirect)
+
+	admin := http.NewServeMux()
+	admin.HandleFunc("POST /api/admin/pipeline/{stage}", s.handleAdminPipeline)
+	admin.HandleFunc("POST /api/admin/articles/{id}/reanalyze", s.handleAdminReanalyze)
+	admin.HandleFunc("PATCH /api/admin/articles/{id}", s.handleAdminEditArticle)
+	admin.HandleFunc("GET /api/admin/blogs", s.handleAdminListBlogs)
+	admin.HandleFunc("POST /api/admin/blogs", s.handleAdminAddBlog)
+	admin.HandleFunc("PATCH /api/admin/blogs/{domain}", s.handleAdminEditBlog)
+	admin.HandleFunc("DELETE /api/admin/blogs/{domain}", s.handleAdminDeleteBlog)
+	admin.HandleFunc("GET /api/admin/subscribers", s.handleAdminSubscribers)
+	mux.Handle("/api/admin/", s.requireAdmin(admin))
 
 	s.srv = &http.Server{
 		Addr:    addr,
-		Handler: corsMiddleware(mux),
+		Handler: instrument(mux, corsMiddleware(cfg.Server.CORSOrigins, mux)),
 	}
 	return s
 }
@@ -49,7 +104,10 @@ func (s *Server) Start(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("listen %s: %w", s.srv.Addr, err)
 	}
-	log.Printf("HTTP server listening on %s", ln.Addr())
+	slog.Info("HTTP server listening", "addr", ln.Addr().String())
+
+	// Requests inherit ctx so long-lived streams end on shutdown.
+	s.srv.BaseContext = func(net.Listener) context.Context { return ctx }
 
 	go func() {
 		<-ctx.Done()
@@ -74,11 +132,80 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"}) //nolint:errcheck
 }
 
-func corsMiddleware(next http.Handler) http.Handler {
+// instrument records the latency of each request, labelled with the mux
+// pattern that matched it so that paths with IDs share one series.
+func instrument(mux *http.ServeMux, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		method := r.Method
+		switch method {
+		case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+			http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		default:
+			method = "other"
+		}
+
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		metrics.HTTPDuration.Observe(time.Since(start).Seconds(), route, method, strconv.Itoa(sw.code))
+	})
+}
+
+// statusWriter remembers the response status code. It passes Flush through
+// for the event stream.
+type statusWriter struct {
+	http.ResponseWriter
+	code        int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.code, w.wroteHeader = code, true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// corsMiddleware allows cross-origin requests from the listed origins, or
+// from any origin when the list contains "*". An empty list allows none, so
+// only same-origin pages (such as the bundled frontend behind nginx) can
+// call the API.
+func corsMiddleware(origins []string, next http.Handler) http.Handler {
+	anyOrigin := slices.Contains(origins, "*")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		allowed := true
+		switch {
+		case anyOrigin:
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		case origin != "" && slices.Contains(origins, origin):
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+		default:
+			w.Header().Add("Vary", "Origin")
+			allowed = false
+		}
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		}
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
 			return
@@ -86,3 +213,49 @@ func corsMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// parseProxies parses trusted proxy addresses and CIDR ranges, skipping
+// invalid entries.
+func parseProxies(entries []string) []netip.Prefix {
+	var proxies []netip.Prefix
+	for _, e := range entries {
+		if p, err := netip.ParsePrefix(e); err == nil {
+			proxies = append(proxies, p.Masked())
+		} else if a, err := netip.ParseAddr(e); err == nil {
+			proxies = append(proxies, netip.PrefixFrom(a, a.BitLen()))
+		} else {
+			slog.Warn("ignoring invalid trusted proxy", "proxy", e)
+		}
+	}
+	return proxies
+}
+
+// fromTrustedProxy reports whether r came through a trusted proxy, whose
+// forwarding headers can be believed. Anyone else could set them to any
+// value.
+func (s *Server) fromTrustedProxy(r *http.Request) bool {
+	peer, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := peer.Addr().Unmap()
+	for _, p := range s.proxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAddr returns the address of the client that sent r: the X-Real-IP
+// header when the request came through a trusted proxy, else the peer
+// address.
+func (s *Server) clientAddr(r *http.Request) string {
+	if ip := r.Header.Get("X-Real-IP"); ip != "" && s.fromTrustedProxy(r) {
+		return ip
+	}
+	if peer, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+		return peer.Addr().Unmap().String()
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chyiyaqing/newsbot/internal/events"
+	"github.com/chyiyaqing/newsbot/internal/store"
+)
+
+// streamHeartbeat keeps idle connections open through proxies.
+const streamHeartbeat = 25 * time.Second
+
+// streamFilter selects the events a client receives. Category and minimum
+// score only apply to article events.
+type streamFilter struct {
+	types    []string
+	category string
+	minScore int
+}
+
+func (f streamFilter) match(e events.Event) bool {
+	if len(f.types) > 0 && !slices.Contains(f.types, e.Type) {
+		return false
+	}
+	if e.Type != events.ArticleAnalyzed {
+		return true
+	}
+	if f.category != "" && !strings.EqualFold(e.Category, f.category) {
+		return false
+	}
+	return e.Score >= f.minScore
+}
+
+// GET /api/stream?types=article.analyzed&category=AI/ML&min_score=20
+//
+// Server-Sent Events. Clients resume with the Last-Event-ID header, or the
+// last_event_id query parameter on the first connection.
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
+		return
+	}
+	if s.events == nil {
+		writeJSON(w, http.StatusServiceUnavailable, apiError{Error: "event stream not available"})
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "streaming not supported"})
+		return
+	}
+
+	q := r.URL.Query()
+	filter := streamFilter{category: q.Get("category")}
+	if v := q.Get("types"); v != "" {
+		filter.types = strings.Split(v, ",")
+	}
+	if n, err := strconv.Atoi(q.Get("min_score")); err == nil {
+		filter.minScore = n
+	}
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = q.Get("last_event_id")
+	}
+	after, _ := strconv.ParseInt(lastID, 10, 64)
+
+	replay, ch, cancel := s.events.Subscribe(after)
+	defer cancel()
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no") // disable nginx response buffering
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 5000\n\n")
+
+	for _, e := range replay {
+		if err := writeEvent(w, filter, e); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-ch:
+			if !ok {
+				// Dropped for falling behind; the client reconnects and resumes.
+				return
+			}
+			if err := writeEvent(w, filter, e); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes e in SSE framing if it passes filter.
+func writeEvent(w http.ResponseWriter, filter streamFilter, e events.Event) error {
+	if !filter.match(e) {
+		return nil
+	}
+	data := e.Data
+	if a, ok := data.(store.ArticleWithAnalysis); ok {
+		data = toAPIArticle(a)
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		slog.Warn("encode event", "type", e.Type, "err", err)
+		return nil
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, payload)
+	return err
+}
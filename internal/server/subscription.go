@@ -0,0 +1,216 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/chyiyaqing/newsbot/internal/i18n"
+	"github.com/chyiyaqing/newsbot/internal/store"
+)
+
+type apiSubscription struct {
+	Email string `json:"email"`
+	store.SubscriberPreferences
+}
+
+var preferencesPage = template.Must(template.New("preferences").Parse(`<!DOCTYPE html>
+<html><head><meta charset="UTF-8"><meta name="viewport" content="width=device-width,initial-scale=1"><title>订阅偏好 — NewsBot</title></head>
+<body style="font-family:-apple-system,BlinkMacSystemFont,'Segoe UI',sans-serif;max-width:520px;margin:0 auto;padding:40px 20px;color:#0f172a;line-height:1.6">
+<h2 style="margin-bottom:4px">订阅偏好</h2>
+<p style="color:#64748b;margin-top:0">{{.Email}}</p>
+{{if .Saved}}<p style="color:#16a34a">已保存</p>{{end}}
+{{if .Error}}<p style="color:#dc2626">{{.Error}}</p>{{end}}
+<form method="POST">
+<p><label>推送频率<br><select name="frequency">
+{{range .Frequencies}}<option value="{{.Value}}"{{if eq .Value $.Frequency}} selected{{end}}>{{.Label}}</option>{{end}}
+</select></label></p>
+<p><label>内容语言<br><select name="language">
+{{range .Languages}}<option value="{{.Value}}"{{if eq .Value $.Language}} selected{{end}}>{{.Label}}</option>{{end}}
+</select></label></p>
+<p><label>最低总分（0-30）<br><input type="number" name="min_score" min="0" max="30" value="{{.MinScore}}"></label></p>
+<p>分类（不选则接收全部）<br>
+{{range .AllCategories}}<label style="display:inline-block;margin-right:12px"><input type="checkbox" name="categories" value="{{.Name}}"{{if .Checked}} checked{{end}}> {{.Name}}</label>{{end}}
+</p>
+<p><button type="submit" style="background:#0f172a;color:#fff;border:0;padding:8px 20px;border-radius:6px">保存</button></p>
+</form>
+</body></html>`))
+
+type option struct {
+	Value, Label string
+}
+
+type categoryOption struct {
+	Name    string
+	Checked bool
+}
+
+type preferencesView struct {
+	Email string
+	store.SubscriberPreferences
+	Frequencies   []option
+	Languages     []option
+	AllCategories []categoryOption
+	Saved         bool
+	Error         string
+}
+
+// GET/POST /api/subscription?token=xxx
+// GET renders the preferences page (or JSON when Accept: application/json);
+// POST accepts a form submission or a JSON body with the same fields.
+func (s *Server) handleSubscription(w http.ResponseWriter, r *http.Request) {
+	tok := r.URL.Query().Get("token")
+	if tok == "" {
+		http.Error(w, "missing token", http.StatusBadRequest)
+		return
+	}
+
+	sub, err := s.db.GetSubscriberByToken(tok)
+	if err != nil {
+		slog.Error("get subscriber by token", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if sub == nil || sub.Status != store.SubscriberConfirmed {
+		if wantsJSON(r) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "subscription not found"})
+		} else {
+			writeHTMLPage(w, "链接已失效", "该订阅链接无效或订阅已取消。")
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if wantsJSON(r) {
+			writeJSON(w, http.StatusOK, apiSubscription{Email: sub.Email, SubscriberPreferences: sub.SubscriberPreferences})
+			return
+		}
+		s.renderPreferences(w, sub.Email, sub.SubscriberPreferences, false, "")
+
+	case http.MethodPost:
+		isJSON := strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+		prefs, err := decodePreferences(r, isJSON, sub.SubscriberPreferences)
+		if err == nil && prefs.Language != sub.Language && !s.offersLanguage(prefs.Language) {
+			err = fmt.Errorf("unsupported language: %s", prefs.Language)
+		}
+		if err == nil {
+			prefs.Categories = slices.DeleteFunc(prefs.Categories, func(c string) bool { return strings.TrimSpace(c) == "" })
+			_, err = s.db.UpdateSubscriberPreferences(tok, prefs)
+		}
+		if isJSON {
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, apiSubscription{Email: sub.Email, SubscriberPreferences: prefs})
+			return
+		}
+		if err != nil {
+			s.renderPreferences(w, sub.Email, sub.SubscriberPreferences, false, err.Error())
+			return
+		}
+		s.renderPreferences(w, sub.Email, prefs, true, "")
+
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
+	}
+}
+
+// decodePreferences overlays the submitted fields onto the current preferences.
+func decodePreferences(r *http.Request, isJSON bool, current store.SubscriberPreferences) (store.SubscriberPreferences, error) {
+	p := current
+	if isJSON {
+		err := json.NewDecoder(r.Body).Decode(&p)
+		return p, err
+	}
+	if err := r.ParseForm(); err != nil {
+		return p, err
+	}
+	if v := r.PostForm.Get("frequency"); v != "" {
+		p.Frequency = v
+	}
+	if v := r.PostForm.Get("language"); v != "" {
+		p.Language = v
+	}
+	// Unchecked boxes are not submitted, so an absent field means "all".
+	p.Categories = r.PostForm["categories"]
+	if v := r.PostForm.Get("min_score"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return p, err
+		}
+		p.MinScore = n
+	}
+	return p, nil
+}
+
+func (s *Server) renderPreferences(w http.ResponseWriter, addr string, prefs store.SubscriberPreferences, saved bool, errMsg string) {
+	categories, err := s.db.CategoriesForWindow("7days")
+	if err != nil {
+		slog.Warn("load categories for preferences page", "err", err)
+	}
+	for _, c := range prefs.Categories {
+		if !slices.Contains(categories, c) {
+			categories = append(categories, c)
+		}
+	}
+
+	view := preferencesView{
+		Email:                 addr,
+		SubscriberPreferences: prefs,
+		Frequencies: []option{
+			{store.FrequencyInstant, "每次更新即推送"},
+			{store.FrequencyDaily, "每日摘要"},
+			{store.FrequencyWeekly, "每周摘要"},
+		},
+		Languages: s.languageOptions(prefs.Language),
+		Saved:     saved,
+		Error:     errMsg,
+	}
+	for _, c := range categories {
+		view.AllCategories = append(view.AllCategories, categoryOption{Name: c, Checked: slices.Contains(prefs.Categories, c)})
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if errMsg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	if err := preferencesPage.Execute(w, view); err != nil {
+		slog.Error("render preferences page", "err", err)
+	}
+}
+
+// offersLanguage reports whether subscribers can choose lang: one every
+// analysis has, or a configured translation language.
+func (s *Server) offersLanguage(lang string) bool {
+	return i18n.Builtin(lang) || slices.Contains(s.cfg.Languages, lang)
+}
+
+// languageOptions lists the digest languages, translation languages last.
+// The current language stays listed even if it is no longer configured.
+func (s *Server) languageOptions(current string) []option {
+	opts := []option{
+		{store.LanguageBilingual, "中英双语"},
+		{store.LanguageChinese, "中文"},
+		{store.LanguageEnglish, "English"},
+	}
+	for _, lang := range s.cfg.Languages {
+		if !i18n.Builtin(lang) {
+			opts = append(opts, option{lang, i18n.Name(lang)})
+		}
+	}
+	if current != "" && !slices.ContainsFunc(opts, func(o option) bool { return o.Value == current }) {
+		opts = append(opts, option{current, i18n.Name(current)})
+	}
+	return opts
+}
+
+func wantsJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
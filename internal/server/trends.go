@@ -0,0 +1,121 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/chyiyaqing/newsbot/internal/store"
+)
+
+type apiTrend struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Articles    []string `json:"articles"`
+	ArticleIDs  []int64  `json:"article_ids"`
+}
+
+type apiTrendReport struct {
+	ID         int64      `json:"id"`
+	Window     string     `json:"window"`
+	Model      string     `json:"model,omitempty"`
+	CreatedAt  string     `json:"created_at"`
+	ArticleIDs []int64    `json:"article_ids"`
+	Trends     []apiTrend `json:"trends"`
+}
+
+func toAPITrendReport(r store.TrendReport) apiTrendReport {
+	out := apiTrendReport{
+		ID:         r.ID,
+		Window:     r.Window,
+		Model:      r.Model,
+		CreatedAt:  fmtTimeRFC3339(r.CreatedAt),
+		ArticleIDs: nonNil(r.ArticleIDs),
+		Trends:     make([]apiTrend, len(r.Trends)),
+	}
+	for i, t := range r.Trends {
+		out.Trends[i] = apiTrend{
+			Title:       t.Title,
+			Description: t.Description,
+			Articles:    nonNil(t.Articles),
+			ArticleIDs:  nonNil(t.ArticleIDs),
+		}
+	}
+	return out
+}
+
+// nonNil makes empty lists encode as [] rather than null.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+// trendWindow returns the window query parameter, or "" for any window.
+func trendWindow(r *http.Request) (string, bool) {
+	switch w := r.URL.Query().Get("window"); w {
+	case "", "24h", "3days", "7days":
+		return w, true
+	}
+	return "", false
+}
+
+// GET /api/trends?window=7days — the latest stored trend report
+func (s *Server) handleAPITrends(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
+		return
+	}
+	window, ok := trendWindow(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid window"})
+		return
+	}
+
+	report, err := s.db.LatestTrendReport(window)
+	if err != nil {
+		slog.Error("api latest trend report", "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to load trends"})
+		return
+	}
+	if report == nil {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "no trend report yet"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"report": toAPITrendReport(*report)})
+}
+
+// GET /api/trends/history?window=7days&limit=20&before=123
+func (s *Server) handleAPITrendHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
+		return
+	}
+	window, ok := trendWindow(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid window"})
+		return
+	}
+	limit := 20
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 && n <= 100 {
+		limit = n
+	}
+	before, _ := strconv.ParseInt(r.URL.Query().Get("before"), 10, 64)
+
+	reports, err := s.db.TrendReports(window, before, limit)
+	if err != nil {
+		slog.Error("api trend history", "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to load trends"})
+		return
+	}
+	items := make([]apiTrendReport, len(reports))
+	for i, rep := range reports {
+		items[i] = toAPITrendReport(rep)
+	}
+	resp := map[string]any{"count": len(items), "reports": items}
+	if len(items) == limit {
+		resp["next_before"] = items[len(items)-1].ID
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
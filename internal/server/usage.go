@@ -0,0 +1,104 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/chyiyaqing/newsbot/internal/store"
+)
+
+type apiUsageTotal struct {
+	store.UsageTotal
+	Tokens int64   `json:"tokens"`
+	Cost   float64 `json:"cost"`
+}
+
+type apiUsageResponse struct {
+	From        string          `json:"from"`
+	To          string          `json:"to"`
+	Total       apiUsageTotal   `json:"total"`
+	ByOperation []apiUsageTotal `json:"by_operation"`
+	Runs        []apiUsageTotal `json:"runs"`
+	Budget      apiUsageBudget  `json:"budget"`
+}
+
+type apiUsageBudget struct {
+	DailyTokens int64 `json:"daily_tokens"` // 0 = unlimited
+	UsedToday   int64 `json:"used_today"`
+	Exceeded    bool  `json:"exceeded"`
+}
+
+// GET /api/usage?days=7&runs=20
+//
+// Token usage and estimated cost of model calls over the last days (UTC,
+// including today), per operation and model and per pipeline run.
+func (s *Server) handleAPIUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
+		return
+	}
+	days := 7
+	if n, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && n > 0 && n <= 366 {
+		days = n
+	}
+	runLimit := 20
+	if n, err := strconv.Atoi(r.URL.Query().Get("runs")); err == nil && n > 0 && n <= 200 {
+		runLimit = n
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from, to := today.AddDate(0, 0, -(days-1)), today.AddDate(0, 0, 1)
+
+	ops, err := s.db.UsageByOperation(from, to)
+	if err != nil {
+		slog.Error("api usage by operation", "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to load usage"})
+		return
+	}
+	runs, err := s.db.UsageByRun(from, to, runLimit)
+	if err != nil {
+		slog.Error("api usage by run", "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to load usage"})
+		return
+	}
+	usedToday, err := s.db.LLMTokensSince(today)
+	if err != nil {
+		slog.Error("api usage today", "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "failed to load usage"})
+		return
+	}
+
+	resp := apiUsageResponse{
+		From:        from.Format("2006-01-02"),
+		To:          today.Format("2006-01-02"),
+		ByOperation: make([]apiUsageTotal, len(ops)),
+		Runs:        make([]apiUsageTotal, len(runs)),
+		Budget: apiUsageBudget{
+			DailyTokens: s.cfg.Usage.DailyTokenBudget,
+			UsedToday:   usedToday,
+			Exceeded:    s.cfg.Usage.DailyTokenBudget > 0 && usedToday >= s.cfg.Usage.DailyTokenBudget,
+		},
+	}
+	var total store.UsageTotal
+	for i, u := range ops {
+		resp.ByOperation[i] = s.toAPIUsage(u)
+		total.Calls += u.Calls
+		total.PromptTokens += u.PromptTokens
+		total.CompletionTokens += u.CompletionTokens
+	}
+	resp.Total = s.toAPIUsage(total)
+	for i, u := range runs {
+		resp.Runs[i] = s.toAPIUsage(u)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) toAPIUsage(u store.UsageTotal) apiUsageTotal {
+	return apiUsageTotal{
+		UsageTotal: u,
+		Tokens:     u.Tokens(),
+		Cost:       s.cfg.Usage.Cost(u.PromptTokens, u.CompletionTokens),
+	}
+}
@@ -0,0 +1,141 @@
+// Package sources judges blogs by their track record — how their articles
+// score and whether their feed still works — and applies the configured
+// policy to the ones that keep disappointing: demoting them to be scraped
+// less often, or muting them.
+package sources
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/chyiyaqing/newsbot/internal/config"
+	"github.com/chyiyaqing/newsbot/internal/store"
+)
+
+// Policy actions. ActionUndemote restores a demoted blog that recovered;
+// it is not configurable.
+const (
+	ActionDemote   = "demote"
+	ActionMute     = "mute"
+	ActionUndemote = "undemote"
+)
+
+// Verdicts on a blog's stats.
+const (
+	VerdictOK       = "ok"
+	VerdictLowScore = "low_score"
+	VerdictDead     = "dead"
+)
+
+// ValidAction reports whether action is a policy action or "" (report only).
+func ValidAction(action string) bool {
+	return action == "" || action == ActionDemote || action == ActionMute
+}
+
+// Judge returns the verdict on a blog's stats and a human-readable reason
+// for verdicts other than VerdictOK. A dead feed outweighs low scores.
+func Judge(cfg config.SourcesConfig, st store.BlogStats) (verdict, reason string) {
+	if st.Fetches >= cfg.MinFetches && st.Fetches > 0 && st.ErrorRate > cfg.MaxErrorRate {
+		return VerdictDead, fmt.Sprintf("feed failed %d of %d fetches", st.FetchErrors, st.Fetches)
+	}
+	if st.Analyzed >= cfg.MinAnalyzed && st.Analyzed > 0 && st.MeanScore < cfg.MinMeanScore {
+		return VerdictLowScore, fmt.Sprintf("mean score %.1f over %d articles is below %.1f", st.MeanScore, st.Analyzed, cfg.MinMeanScore)
+	}
+	return VerdictOK, ""
+}
+
+// Decision is what the policy did, or would do, to one blog.
+type Decision struct {
+	Domain  string
+	Verdict string
+	Reason  string
+	// Action is ActionDemote, ActionMute, ActionUndemote, or "" when
+	// nothing changes.
+	Action string
+}
+
+// Apply judges every blog from the HN list over the configured period and
+// demotes or mutes the failing ones according to cfg.Action. Demoted blogs
+// that no longer fail are restored. With dryRun nothing is changed. It
+// returns a decision for every blog that is failing or changes state.
+func Apply(ctx context.Context, db *store.Store, cfg config.SourcesConfig, dryRun bool) ([]Decision, error) {
+	if !ValidAction(cfg.Action) {
+		return nil, fmt.Errorf("unknown source action: %s", cfg.Action)
+	}
+	stats, err := db.AllBlogStats(cfg.Window(time.Now()), cfg.HitScore)
+	if err != nil {
+		return nil, err
+	}
+
+	var decisions []Decision
+	for _, st := range stats {
+		if st.Source != store.BlogSourceHN || st.MutedAt != nil {
+			continue
+		}
+		d := Decision{Domain: st.Domain}
+		d.Verdict, d.Reason = Judge(cfg, st)
+		switch {
+		case d.Verdict != VerdictOK && cfg.Action == ActionMute:
+			d.Action = ActionMute
+		case d.Verdict != VerdictOK && cfg.Action == ActionDemote && st.DemotedAt == nil:
+			d.Action = ActionDemote
+		case d.Verdict == VerdictOK && st.DemotedAt != nil:
+			d.Action = ActionUndemote
+		case d.Verdict == VerdictOK:
+			continue
+		}
+		decisions = append(decisions, d)
+		if dryRun || d.Action == "" {
+			continue
+		}
+
+		switch d.Action {
+		case ActionMute:
+			err = db.MuteBlog(d.Domain)
+		case ActionDemote:
+			err = db.DemoteBlog(d.Domain)
+		case ActionUndemote:
+			err = db.UndemoteBlog(d.Domain)
+		}
+		if err != nil {
+			return decisions, fmt.Errorf("%s %s: %w", d.Action, d.Domain, err)
+		}
+		slog.InfoContext(ctx, "applied source policy", "domain", d.Domain, "action", d.Action, "verdict", d.Verdict, "reason", d.Reason)
+	}
+	return decisions, nil
+}
+
+// Due returns the blogs to scrape now, judged by their stored state: muted
+// blogs are skipped, and demoted ones are only scraped when their last
+// fetch is at least interval old.
+func Due(db *store.Store, blogs []store.Blog, interval time.Duration, now time.Time) ([]store.Blog, error) {
+	known, err := db.ListBlogs()
+	if err != nil {
+		return nil, err
+	}
+	lastFetch, err := db.LastFeedFetches()
+	if err != nil {
+		return nil, err
+	}
+	state := make(map[string]store.Blog, len(known))
+	for _, b := range known {
+		state[b.Domain] = b
+	}
+
+	var due []store.Blog
+	for _, b := range blogs {
+		st := state[b.Domain]
+		if st.MutedAt != nil {
+			continue
+		}
+		if st.DemotedAt != nil {
+			if last, ok := lastFetch[b.Domain]; ok && now.Sub(last) < interval {
+				continue
+			}
+		}
+		due = append(due, b)
+	}
+	return due, nil
+}
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// ManualBlogs returns blogs added through the admin API.
+func (s *Store) ManualBlogs() ([]Blog, error) {
+	return s.queryBlogs(
+		"SELECT id, domain, score, author, rank, source, muted_at, demoted_at FROM blogs WHERE source = ? ORDER BY domain",
+		BlogSourceManual,
+	)
+}
+
+// AddBlog adds a blog to be scraped on every run. Returns false if the
+// domain is already known.
+func (s *Store) AddBlog(domain, author string) (bool, error) {
+	res, err := s.db.Exec(
+		"INSERT OR IGNORE INTO blogs (domain, author, source) VALUES (?, ?, ?)",
+		domain, author, BlogSourceManual,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// UnmuteBlog lets a muted blog's articles into digests again.
+func (s *Store) UnmuteBlog(domain string) error {
+	_, err := s.db.Exec("UPDATE blogs SET muted_at = NULL WHERE domain = ?", domain)
+	return err
+}
+
+// DeleteBlog removes a manually added blog. Blogs from the HN popularity list
+// would come back on the next fetch, so they can only be muted. Returns false
+// if no manual blog has this domain. Articles already scraped are kept.
+func (s *Store) DeleteBlog(domain string) (bool, error) {
+	res, err := s.db.Exec("DELETE FROM blogs WHERE domain = ? AND source = ?", domain, BlogSourceManual)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// AnalysisEdit is a moderator's change to an analysis. Nil fields are left
+// unchanged.
+type AnalysisEdit struct {
+	TitleCN         *string `json:"title_cn"`
+	AISummary       *string `json:"ai_summary"`
+	RecommendReason *string `json:"recommend_reason"`
+	Category        *string `json:"category"`
+	Keywords        *string `json:"keywords"`
+	Relevance       *int    `json:"relevance"`
+	Quality         *int    `json:"quality"`
+	Timeliness      *int    `json:"timeliness"`
+	Hidden          *bool   `json:"hidden"`
+}
+
+// Validate checks that edited scores are in the 0-10 range used by the scorer.
+func (e AnalysisEdit) Validate() error {
+	for name, v := range map[string]*int{"relevance": e.Relevance, "quality": e.Quality, "timeliness": e.Timeliness} {
+		if v != nil && (*v < 0 || *v > 10) {
+			return fmt.Errorf("%s must be between 0 and 10", name)
+		}
+	}
+	return nil
+}
+
+// UpdateAnalysis applies e to the analysis of an article, recomputing the
+// total score with the current interest adjustment, and records the edited
+// analysis in its history unless only Hidden changed. A new category or
+// keywords can match other interest topics, so the adjustment is then
+// recomputed with adjust first. Translations of an edited title, summary or
+// reason are dropped. Returns false if the article has no analysis.
+func (s *Store) UpdateAnalysis(articleID int64, e AnalysisEdit, adjust AdjustFunc) (bool, error) {
+	if err := e.Validate(); err != nil {
+		return false, err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if err := dropStaleTranslations(tx, articleID, e.TitleCN, e.AISummary, e.RecommendReason); err != nil {
+		return false, err
+	}
+	res, err := tx.Exec(`
+		UPDATE article_analysis SET
+			title_cn         = COALESCE(?, title_cn),
+			ai_summary       = COALESCE(?, ai_summary),
+			recommend_reason = COALESCE(?, recommend_reason),
+			category         = COALESCE(?, category),
+			keywords         = COALESCE(?, keywords),
+			relevance        = COALESCE(?, relevance),
+			quality          = COALESCE(?, quality),
+			timeliness       = COALESCE(?, timeliness),
+			total_score      = MAX(COALESCE(?, relevance) + COALESCE(?, quality) + COALESCE(?, timeliness) + score_adjustment, 0),
+			hidden_at        = CASE
+				WHEN ? IS NULL THEN hidden_at
+				WHEN ? THEN COALESCE(hidden_at, ?)
+				ELSE NULL
+			END
+		WHERE article_id = ?
+	`,
+		e.TitleCN, e.AISummary, e.RecommendReason, e.Category, e.Keywords,
+		e.Relevance, e.Quality, e.Timeliness,
+		e.Relevance, e.Quality, e.Timeliness,
+		e.Hidden, e.Hidden, time.Now().UTC().Format(time.RFC3339),
+		articleID,
+	)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if e.Keywords != nil {
+		if err := replaceKeywords(tx, articleID, *e.Keywords); err != nil {
+			return false, err
+		}
+	}
+	if e.Category != nil || e.Keywords != nil {
+		if _, err := rescore(tx, "WHERE a.id = ?", adjust, articleID); err != nil {
+			return false, err
+		}
+	}
+	if e != (AnalysisEdit{Hidden: e.Hidden}) {
+		if err := recordVersion(tx, articleID, VersionOriginEdit, time.Now()); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+// AllSubscribers returns subscribers in any state, or only those with the
+// given status when it is non-empty.
+func (s *Store) AllSubscribers(status string) ([]Subscriber, error) {
+	query := "SELECT " + subscriberColumns + " FROM subscribers"
+	var args []any
+	if status != "" {
+		query += " WHERE status = ?"
+		args = append(args, status)
+	}
+	rows, err := s.db.Query(query+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []Subscriber
+	for rows.Next() {
+		sub, err := scanSubscriber(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, *sub)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,300 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Analytics dimensions and time buckets.
+const (
+	DimensionKeyword  = "keyword"
+	DimensionCategory = "category"
+	DimensionSource   = "source"
+
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+// dimensionSQL maps a dimension to the joins it needs and its key column.
+var dimensionSQL = map[string]struct{ join, key string }{
+	DimensionKeyword:  {"JOIN article_keywords k ON k.article_id = a.id", "k.keyword"},
+	DimensionCategory: {"", "aa.category"},
+	DimensionSource:   {"", "a.blog_domain"},
+}
+
+// bucketSQL maps a bucket size to an expression for the bucket's first day.
+// Weeks start on Monday.
+var bucketSQL = map[string]string{
+	BucketDay:   "substr(a.published_at, 1, 10)",
+	BucketWeek:  "date(substr(a.published_at, 1, 10), '-6 days', 'weekday 1')",
+	BucketMonth: "substr(a.published_at, 1, 7) || '-01'",
+}
+
+// SeriesPoint is the number and average total score of articles in one bucket.
+type SeriesPoint struct {
+	Bucket   string  `json:"bucket"` // first day, YYYY-MM-DD
+	Count    int     `json:"count"`
+	AvgScore float64 `json:"avg_score"`
+}
+
+// Series is the activity of one keyword, category or source over time.
+type Series struct {
+	Key      string        `json:"key"`
+	Count    int           `json:"count"`
+	AvgScore float64       `json:"avg_score"`
+	Points   []SeriesPoint `json:"points"`
+}
+
+// RisingKey compares a key's article count to the preceding period.
+type RisingKey struct {
+	Key      string  `json:"key"`
+	Current  int     `json:"current"`
+	Baseline int     `json:"baseline"`
+	Ratio    float64 `json:"ratio"` // (current+1) / (baseline+1)
+}
+
+// normalizeKeyword lower-cases a keyword and collapses its whitespace.
+func normalizeKeyword(k string) string {
+	return strings.ToLower(strings.Join(strings.Fields(k), " "))
+}
+
+// replaceKeywords stores the comma-separated keywords of an analysis as
+// article_keywords rows.
+func replaceKeywords(tx *sql.Tx, articleID int64, keywords string) error {
+	if _, err := tx.Exec("DELETE FROM article_keywords WHERE article_id = ?", articleID); err != nil {
+		return err
+	}
+	for _, k := range strings.Split(keywords, ",") {
+		if k = normalizeKeyword(k); k == "" {
+			continue
+		}
+		if _, err := tx.Exec("INSERT OR IGNORE INTO article_keywords (article_id, keyword) VALUES (?, ?)", articleID, k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillKeywords fills article_keywords from existing analyses when the
+// table is still empty.
+func (s *Store) backfillKeywords() error {
+	var n int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM article_keywords").Scan(&n); err != nil || n > 0 {
+		return err
+	}
+
+	rows, err := s.db.Query("SELECT article_id, keywords FROM article_analysis WHERE keywords != ''")
+	if err != nil {
+		return err
+	}
+	type row struct {
+		id       int64
+		keywords string
+	}
+	var all []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.keywords); err != nil {
+			rows.Close()
+			return err
+		}
+		all = append(all, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(all) == 0 {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, r := range all {
+		if err := replaceKeywords(tx, r.id, r.keywords); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DimensionSeries returns, for the limit most frequent keys of dimension
+// among articles published in [from, to), their article count and average
+// score per bucket. Buckets without articles are included with zero counts.
+func (s *Store) DimensionSeries(dimension string, from, to time.Time, bucket string, limit int) ([]Series, error) {
+	dim, ok := dimensionSQL[dimension]
+	if !ok {
+		return nil, fmt.Errorf("unsupported dimension: %s", dimension)
+	}
+	bucketExpr, ok := bucketSQL[bucket]
+	if !ok {
+		return nil, fmt.Errorf("unsupported bucket: %s", bucket)
+	}
+
+	totals, err := s.dimensionTotals(dimension, from, to, limit)
+	if err != nil || len(totals) == 0 {
+		return nil, err
+	}
+
+	starts := bucketStarts(from, to, bucket)
+	index := make(map[string]int, len(totals))
+	placeholders := make([]string, len(totals))
+	args := []any{from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339)}
+	for i := range totals {
+		index[totals[i].Key] = i
+		placeholders[i] = "?"
+		args = append(args, totals[i].Key)
+		totals[i].Points = make([]SeriesPoint, len(starts))
+		for j, b := range starts {
+			totals[i].Points[j].Bucket = b
+		}
+	}
+	position := make(map[string]int, len(starts))
+	for j, b := range starts {
+		position[b] = j
+	}
+
+	rows, err := s.db.Query(`
+		SELECT `+dim.key+`, `+bucketExpr+`, COUNT(*), AVG(aa.total_score)
+		FROM articles a
+		JOIN article_analysis aa ON aa.article_id = a.id
+		`+dim.join+`
+		WHERE a.published_at >= ? AND a.published_at < ? AND aa.hidden_at IS NULL
+		  AND `+dim.key+` IN (`+strings.Join(placeholders, ",")+`)
+		GROUP BY 1, 2
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key, b string
+		var p SeriesPoint
+		if err := rows.Scan(&key, &b, &p.Count, &p.AvgScore); err != nil {
+			return nil, err
+		}
+		i, ok1 := index[key]
+		j, ok2 := position[b]
+		if ok1 && ok2 {
+			p.Bucket = b
+			p.AvgScore = roundScore(p.AvgScore)
+			totals[i].Points[j] = p
+		}
+	}
+	return totals, rows.Err()
+}
+
+// RisingKeys compares each key's article count in [from, to) with the
+// preceding period of the same length and returns the limit keys that grew
+// the most. Keys need at least minCount articles in the current period.
+func (s *Store) RisingKeys(dimension string, from, to time.Time, minCount, limit int) ([]RisingKey, error) {
+	current, err := s.dimensionTotals(dimension, from, to, 0)
+	if err != nil {
+		return nil, err
+	}
+	baseline, err := s.dimensionTotals(dimension, from.Add(-to.Sub(from)), from, 0)
+	if err != nil {
+		return nil, err
+	}
+	before := make(map[string]int, len(baseline))
+	for _, b := range baseline {
+		before[b.Key] = b.Count
+	}
+
+	var rising []RisingKey
+	for _, c := range current {
+		if c.Count < minCount || c.Count <= before[c.Key] {
+			continue
+		}
+		rising = append(rising, RisingKey{
+			Key:      c.Key,
+			Current:  c.Count,
+			Baseline: before[c.Key],
+			Ratio:    roundScore(float64(c.Count+1) / float64(before[c.Key]+1)),
+		})
+	}
+	sort.SliceStable(rising, func(i, j int) bool {
+		if rising[i].Ratio != rising[j].Ratio {
+			return rising[i].Ratio > rising[j].Ratio
+		}
+		return rising[i].Current > rising[j].Current
+	})
+	if len(rising) > limit {
+		rising = rising[:limit]
+	}
+	return rising, nil
+}
+
+// dimensionTotals returns keys of dimension by article count in [from, to),
+// most frequent first. limit <= 0 returns all keys.
+func (s *Store) dimensionTotals(dimension string, from, to time.Time, limit int) ([]Series, error) {
+	dim, ok := dimensionSQL[dimension]
+	if !ok {
+		return nil, fmt.Errorf("unsupported dimension: %s", dimension)
+	}
+	if limit <= 0 {
+		limit = -1 // no limit in SQLite
+	}
+	rows, err := s.db.Query(`
+		SELECT `+dim.key+`, COUNT(*), AVG(aa.total_score)
+		FROM articles a
+		JOIN article_analysis aa ON aa.article_id = a.id
+		`+dim.join+`
+		WHERE a.published_at >= ? AND a.published_at < ? AND aa.hidden_at IS NULL AND `+dim.key+` != ''
+		GROUP BY 1
+		ORDER BY 2 DESC, 1
+		LIMIT ?
+	`, from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []Series
+	for rows.Next() {
+		var r Series
+		if err := rows.Scan(&r.Key, &r.Count, &r.AvgScore); err != nil {
+			return nil, err
+		}
+		r.AvgScore = roundScore(r.AvgScore)
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// bucketStarts lists the first day of every bucket overlapping [from, to),
+// in the format produced by bucketSQL.
+func bucketStarts(from, to time.Time, bucket string) []string {
+	from, to = from.UTC(), to.UTC()
+	d := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	switch bucket {
+	case BucketWeek:
+		d = d.AddDate(0, 0, -((int(d.Weekday()) + 6) % 7))
+	case BucketMonth:
+		d = time.Date(d.Year(), d.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	var starts []string
+	for ; d.Before(to); d = nextBucket(d, bucket) {
+		starts = append(starts, d.Format("2006-01-02"))
+	}
+	return starts
+}
+
+func nextBucket(d time.Time, bucket string) time.Time {
+	switch bucket {
+	case BucketWeek:
+		return d.AddDate(0, 0, 7)
+	case BucketMonth:
+		return d.AddDate(0, 1, 0)
+	}
+	return d.AddDate(0, 0, 1)
+}
+
+func roundScore(v float64) float64 {
+	return float64(int(v*10+0.5)) / 10
+}
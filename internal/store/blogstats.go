@@ -0,0 +1,227 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// FeedFetch is one attempt to read a blog's feed.
+type FeedFetch struct {
+	Domain    string
+	FetchedAt time.Time
+	OK        bool
+	Articles  int
+	Error     string
+}
+
+// SaveFeedFetch records a feed fetch.
+func (s *Store) SaveFeedFetch(f FeedFetch) error {
+	if f.FetchedAt.IsZero() {
+		f.FetchedAt = time.Now()
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO feed_fetches (blog_domain, fetched_at, ok, articles, error)
+		VALUES (?, ?, ?, ?, ?)
+	`, f.Domain, f.FetchedAt.UTC().Format(time.RFC3339), f.OK, f.Articles, f.Error)
+	return err
+}
+
+// LastFeedFetches returns when each blog's feed was last fetched, keyed by
+// domain. Blogs never fetched are left out.
+func (s *Store) LastFeedFetches() (map[string]time.Time, error) {
+	rows, err := s.db.Query("SELECT blog_domain, MAX(fetched_at) FROM feed_fetches GROUP BY blog_domain")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]time.Time)
+	for rows.Next() {
+		var domain, fetchedAt string
+		if err := rows.Scan(&domain, &fetchedAt); err != nil {
+			return nil, err
+		}
+		if t, err := time.Parse(time.RFC3339, fetchedAt); err == nil {
+			result[domain] = t
+		}
+	}
+	return result, rows.Err()
+}
+
+// DemoteBlog marks a blog as demoted by the source policy. Demoting an
+// already demoted blog keeps the original time.
+func (s *Store) DemoteBlog(domain string) error {
+	_, err := s.db.Exec(
+		"UPDATE blogs SET demoted_at = ? WHERE domain = ? AND demoted_at IS NULL",
+		time.Now().UTC().Format(time.RFC3339), domain,
+	)
+	return err
+}
+
+// UndemoteBlog lets a demoted blog be scraped on every run again.
+func (s *Store) UndemoteBlog(domain string) error {
+	_, err := s.db.Exec("UPDATE blogs SET demoted_at = NULL WHERE domain = ?", domain)
+	return err
+}
+
+// BlogStats aggregates a blog's articles, their scores and its feed fetches
+// since a point in time.
+type BlogStats struct {
+	Blog
+	// Articles scraped and analyzed since the start of the period.
+	Articles int
+	Analyzed int
+	// MeanScore and MedianScore are over the analyzed articles' total scores.
+	MeanScore   float64
+	MedianScore float64
+	// Hits are analyzed articles scoring at least the hit score.
+	Hits    int
+	HitRate float64
+	// Feed fetches and failed ones since the start of the period.
+	Fetches     int
+	FetchErrors int
+	ErrorRate   float64
+	// PostsPerWeek counts articles published since the start of the period.
+	PostsPerWeek  float64
+	LastPublished *time.Time
+	LastFetched   *time.Time
+}
+
+// AllBlogStats returns the stats of every blog since the given time, in
+// rank order. Analyzed articles with a total score of at least hitScore
+// count as hits.
+func (s *Store) AllBlogStats(since time.Time, hitScore int) ([]BlogStats, error) {
+	blogs, err := s.ListBlogs()
+	if err != nil {
+		return nil, err
+	}
+	cutoff := since.UTC().Format(time.RFC3339)
+
+	stats := make([]BlogStats, len(blogs))
+	byDomain := make(map[string]*BlogStats, len(blogs))
+	for i, b := range blogs {
+		stats[i].Blog = b
+		byDomain[b.Domain] = &stats[i]
+	}
+
+	// Each query's rows are closed before the next one runs: the store
+	// uses a single connection.
+	if err := s.scanRows(func(rows *sql.Rows) error {
+		var domain string
+		var scraped, published int
+		var last sql.NullString
+		if err := rows.Scan(&domain, &scraped, &published, &last); err != nil {
+			return err
+		}
+		if st := byDomain[domain]; st != nil {
+			st.Articles = scraped
+			st.PostsPerWeek = float64(published) / (time.Since(since).Hours() / (24 * 7))
+			st.LastPublished = parseNullTime(last)
+		}
+		return nil
+	}, `
+		SELECT blog_domain,
+		       SUM(CASE WHEN scraped_at >= ? THEN 1 ELSE 0 END),
+		       SUM(CASE WHEN published_at >= ? THEN 1 ELSE 0 END),
+		       MAX(published_at)
+		FROM articles
+		GROUP BY blog_domain
+	`, cutoff, cutoff); err != nil {
+		return nil, err
+	}
+
+	scores := make(map[string][]int)
+	if err := s.scanRows(func(rows *sql.Rows) error {
+		var domain string
+		var score int
+		if err := rows.Scan(&domain, &score); err != nil {
+			return err
+		}
+		scores[domain] = append(scores[domain], score)
+		return nil
+	}, `
+		SELECT a.blog_domain, aa.total_score
+		FROM article_analysis aa
+		JOIN articles a ON a.id = aa.article_id
+		WHERE a.scraped_at >= ?
+		ORDER BY a.blog_domain, aa.total_score
+	`, cutoff); err != nil {
+		return nil, err
+	}
+	for domain, sorted := range scores {
+		st := byDomain[domain]
+		if st == nil {
+			continue
+		}
+		st.Analyzed = len(sorted)
+		sum := 0
+		for _, v := range sorted {
+			sum += v
+			if v >= hitScore {
+				st.Hits++
+			}
+		}
+		st.MeanScore = float64(sum) / float64(len(sorted))
+		st.HitRate = float64(st.Hits) / float64(len(sorted))
+		mid := len(sorted) / 2
+		if len(sorted)%2 == 1 {
+			st.MedianScore = float64(sorted[mid])
+		} else {
+			st.MedianScore = float64(sorted[mid-1]+sorted[mid]) / 2
+		}
+	}
+
+	if err := s.scanRows(func(rows *sql.Rows) error {
+		var domain string
+		var fetches, failed int
+		var last sql.NullString
+		if err := rows.Scan(&domain, &fetches, &failed, &last); err != nil {
+			return err
+		}
+		if st := byDomain[domain]; st != nil {
+			st.Fetches = fetches
+			st.FetchErrors = failed
+			st.ErrorRate = float64(failed) / float64(fetches)
+			st.LastFetched = parseNullTime(last)
+		}
+		return nil
+	}, `
+		SELECT blog_domain, COUNT(*), SUM(CASE WHEN ok THEN 0 ELSE 1 END), MAX(fetched_at)
+		FROM feed_fetches
+		WHERE fetched_at >= ?
+		GROUP BY blog_domain
+	`, cutoff); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// BlogStatsFor returns the stats of one blog since the given time, or nil
+// if the blog does not exist.
+func (s *Store) BlogStatsFor(domain string, since time.Time, hitScore int) (*BlogStats, error) {
+	all, err := s.AllBlogStats(since, hitScore)
+	if err != nil {
+		return nil, err
+	}
+	for i := range all {
+		if all[i].Domain == domain {
+			return &all[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// scanRows runs query and calls scan for each row.
+func (s *Store) scanRows(scan func(*sql.Rows) error, query string, args ...any) error {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
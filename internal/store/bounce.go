@@ -0,0 +1,99 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Bounce kinds.
+const (
+	BounceHard      = "hard"      // permanent failure from SMTP or a DSN report
+	BounceComplaint = "complaint" // ARF abuse report
+)
+
+// Bounce is a permanent delivery failure or spam complaint for an address.
+type Bounce struct {
+	Email  string
+	Kind   string
+	Detail string
+	// MessageID identifies the bounce report; a report already recorded for
+	// the same address is ignored. Empty for failures seen while sending.
+	MessageID string
+	CreatedAt time.Time
+}
+
+// RecordBounce logs a permanent delivery failure and increments the
+// subscriber's bounce count. Once the count reaches threshold (0 disables
+// suspension) a confirmed subscriber is suspended. Returns true if this call
+// suspended the subscriber.
+func (s *Store) RecordBounce(b Bounce, threshold int) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if ok, err := insertBounce(tx, b); err != nil || !ok {
+		return false, err
+	}
+	if _, err := tx.Exec(
+		"UPDATE subscribers SET bounce_count = bounce_count + 1 WHERE lower(email) = lower(?)", b.Email,
+	); err != nil {
+		return false, err
+	}
+
+	var n int64
+	if threshold > 0 {
+		res, err := tx.Exec(
+			"UPDATE subscribers SET status = ? WHERE lower(email) = lower(?) AND status = ? AND bounce_count >= ?",
+			SubscriberSuspended, b.Email, SubscriberConfirmed, threshold,
+		)
+		if err != nil {
+			return false, err
+		}
+		n, _ = res.RowsAffected()
+	}
+	return n > 0, tx.Commit()
+}
+
+// RemoveBouncedSubscriber logs a bounce or complaint report and deletes the
+// subscriber. Returns false if the report was already processed or the
+// address is not subscribed.
+func (s *Store) RemoveBouncedSubscriber(b Bounce) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if ok, err := insertBounce(tx, b); err != nil || !ok {
+		return false, err
+	}
+	res, err := tx.Exec("DELETE FROM subscribers WHERE lower(email) = lower(?)", b.Email)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, tx.Commit()
+}
+
+// insertBounce adds b to the bounce log. Returns false if the same report
+// was already logged for the address.
+func insertBounce(tx *sql.Tx, b Bounce) (bool, error) {
+	if b.CreatedAt.IsZero() {
+		b.CreatedAt = time.Now()
+	}
+	var messageID sql.NullString
+	if b.MessageID != "" {
+		messageID = sql.NullString{String: b.MessageID, Valid: true}
+	}
+	res, err := tx.Exec(`
+		INSERT OR IGNORE INTO bounces (email, kind, detail, message_id, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, b.Email, b.Kind, b.Detail, messageID, b.CreatedAt.UTC().Format(time.RFC3339))
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
@@ -0,0 +1,225 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Feedback actions a reader can take on an article.
+const (
+	FeedbackUp   = "up"
+	FeedbackDown = "down"
+	FeedbackMore = "more"
+	FeedbackMute = "mute"
+)
+
+// Feedback is a single reader reaction to an article.
+type Feedback struct {
+	ID        int64
+	ArticleID int64
+	Action    string
+	Channel   string
+	UserRef   string
+	CreatedAt time.Time
+}
+
+// FeedbackTotals counts feedback actions.
+type FeedbackTotals struct {
+	Up   int `json:"up"`
+	Down int `json:"down"`
+	More int `json:"more"`
+	Mute int `json:"mute"`
+}
+
+// ArticleFeedback is the feedback tally for a single article.
+type ArticleFeedback struct {
+	ArticleID int64  `json:"article_id"`
+	Title     string `json:"title"`
+	Source    string `json:"source"`
+	FeedbackTotals
+}
+
+// FeedbackStats aggregates feedback received within a time window.
+type FeedbackStats struct {
+	Totals   FeedbackTotals    `json:"totals"`
+	Articles []ArticleFeedback `json:"articles"`
+}
+
+// ValidFeedbackAction reports whether action is a known feedback action.
+func ValidFeedbackAction(action string) bool {
+	switch action {
+	case FeedbackUp, FeedbackDown, FeedbackMore, FeedbackMute:
+		return true
+	}
+	return false
+}
+
+// SaveFeedback records a reader reaction. Repeated identical reactions are
+// ignored, and a thumbs up replaces an earlier thumbs down (and vice versa).
+func (s *Store) SaveFeedback(f Feedback) error {
+	if !ValidFeedbackAction(f.Action) {
+		return fmt.Errorf("unknown feedback action: %s", f.Action)
+	}
+	if f.CreatedAt.IsZero() {
+		f.CreatedAt = time.Now()
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var opposite string
+	switch f.Action {
+	case FeedbackUp:
+		opposite = FeedbackDown
+	case FeedbackDown:
+		opposite = FeedbackUp
+	}
+	if opposite != "" {
+		if _, err := tx.Exec(
+			"DELETE FROM feedback WHERE article_id = ? AND channel = ? AND user_ref = ? AND action = ?",
+			f.ArticleID, f.Channel, f.UserRef, opposite,
+		); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`
+		INSERT OR IGNORE INTO feedback (article_id, action, channel, user_ref, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, f.ArticleID, f.Action, f.Channel, f.UserRef, f.CreatedAt.UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MuteBlog marks a blog as muted so its articles are left out of digests.
+func (s *Store) MuteBlog(domain string) error {
+	_, err := s.db.Exec(
+		"UPDATE blogs SET muted_at = ? WHERE domain = ? AND muted_at IS NULL",
+		time.Now().UTC().Format(time.RFC3339), domain,
+	)
+	return err
+}
+
+// GetArticle returns a single article by ID, or nil if it does not exist.
+func (s *Store) GetArticle(id int64) (*Article, error) {
+	var a Article
+	err := s.db.QueryRow(`
+		SELECT id, blog_domain, title, url, summary, published_at, scraped_at
+		FROM articles
+		WHERE id = ?
+	`, id).Scan(&a.ID, &a.BlogDomain, &a.Title, &a.URL, &a.Summary, &a.PublishedAt, &a.ScrapedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// FeedbackStatsForWindow returns feedback totals and the most reacted-to
+// articles for feedback received within the given window.
+func (s *Store) FeedbackStatsForWindow(window string, limit int) (*FeedbackStats, error) {
+	cutoff, err := windowCutoff(window)
+	if err != nil {
+		return nil, err
+	}
+
+	var st FeedbackStats
+	err = s.db.QueryRow(`
+		SELECT
+			COALESCE(SUM(action = 'up'), 0),
+			COALESCE(SUM(action = 'down'), 0),
+			COALESCE(SUM(action = 'more'), 0),
+			COALESCE(SUM(action = 'mute'), 0)
+		FROM feedback
+		WHERE created_at >= ?
+	`, cutoff).Scan(&st.Totals.Up, &st.Totals.Down, &st.Totals.More, &st.Totals.Mute)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT a.id, a.title, a.blog_domain,
+		       SUM(f.action = 'up'), SUM(f.action = 'down'),
+		       SUM(f.action = 'more'), SUM(f.action = 'mute')
+		FROM feedback f
+		JOIN articles a ON a.id = f.article_id
+		WHERE f.created_at >= ?
+		GROUP BY a.id
+		ORDER BY COUNT(*) DESC, a.published_at DESC
+		LIMIT ?
+	`, cutoff, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	st.Articles = []ArticleFeedback{}
+	for rows.Next() {
+		var af ArticleFeedback
+		if err := rows.Scan(&af.ArticleID, &af.Title, &af.Source, &af.Up, &af.Down, &af.More, &af.Mute); err != nil {
+			return nil, err
+		}
+		st.Articles = append(st.Articles, af)
+	}
+	return &st, rows.Err()
+}
+
+// Click is a reader following an article link.
+type Click struct {
+	ArticleID int64
+	Channel   string
+	UserRef   string
+	CreatedAt time.Time
+}
+
+// SaveClick records an article link click. Every click is kept.
+func (s *Store) SaveClick(c Click) error {
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = time.Now()
+	}
+	_, err := s.db.Exec(
+		"INSERT INTO clicks (article_id, channel, user_ref, created_at) VALUES (?, ?, ?, ?)",
+		c.ArticleID, c.Channel, c.UserRef, c.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	return err
+}
+
+// ClickCounts returns the number of clicks on each of the given articles.
+// Articles without clicks are left out.
+func (s *Store) ClickCounts(articleIDs []int64) (map[int64]int, error) {
+	counts := make(map[int64]int)
+	if len(articleIDs) == 0 {
+		return counts, nil
+	}
+	placeholders := make([]string, len(articleIDs))
+	args := make([]any, len(articleIDs))
+	for i, id := range articleIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	rows, err := s.db.Query(fmt.Sprintf(
+		"SELECT article_id, COUNT(*) FROM clicks WHERE article_id IN (%s) GROUP BY article_id",
+		strings.Join(placeholders, ","),
+	), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var n int
+		if err := rows.Scan(&id, &n); err != nil {
+			return nil, err
+		}
+		counts[id] = n
+	}
+	return counts, rows.Err()
+}
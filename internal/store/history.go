@@ -0,0 +1,83 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Origins of analysis versions.
+const (
+	VersionOriginModel   = "model"   // scoring and summary results
+	VersionOriginEdit    = "edit"    // moderator edits through the admin API
+	VersionOriginRescore = "rescore" // interest profile changes (newsbot rescore)
+)
+
+// AnalysisVersion is an analysis of an article as it was saved at one
+// point. The embedded analysis's ID is the version's, and AnalyzedAt is
+// when the version was recorded.
+type AnalysisVersion struct {
+	ArticleAnalysis
+	Origin string
+}
+
+// versionColumns are the analysis columns copied into analysis_versions.
+const versionColumns = `relevance, quality, timeliness, total_score, score_adjustment,
+	category, keywords, ai_summary, title_cn, recommend_reason, model, prompt_version`
+
+// recordVersion appends the current analysis of an article to its history.
+func recordVersion(tx *sql.Tx, articleID int64, origin string, at time.Time) error {
+	_, err := tx.Exec(`
+		INSERT INTO analysis_versions (article_id, origin, created_at, `+versionColumns+`)
+		SELECT article_id, ?, ?, `+versionColumns+`
+		FROM article_analysis
+		WHERE article_id = ?
+	`, origin, at.UTC().Format(time.RFC3339), articleID)
+	return err
+}
+
+// backfillAnalysisVersions starts the history of analyses made before it
+// was kept with their current state, once.
+func (s *Store) backfillAnalysisVersions() error {
+	var n int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM analysis_versions").Scan(&n); err != nil || n > 0 {
+		return err
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO analysis_versions (article_id, origin, created_at, `+versionColumns+`)
+		SELECT article_id, ?, analyzed_at, `+versionColumns+`
+		FROM article_analysis
+		ORDER BY article_id
+	`, VersionOriginModel)
+	return err
+}
+
+// AnalysisHistory returns every recorded analysis of an article, newest
+// first. The newest is the one in article_analysis.
+func (s *Store) AnalysisHistory(articleID int64) ([]AnalysisVersion, error) {
+	rows, err := s.db.Query(`
+		SELECT id, article_id, origin, created_at, `+versionColumns+`
+		FROM analysis_versions
+		WHERE article_id = ?
+		ORDER BY id DESC
+	`, articleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []AnalysisVersion
+	for rows.Next() {
+		var v AnalysisVersion
+		a := &v.ArticleAnalysis
+		if err := rows.Scan(
+			&a.ID, &a.ArticleID, &v.Origin, &a.AnalyzedAt,
+			&a.Relevance, &a.Quality, &a.Timeliness, &a.TotalScore, &a.ScoreAdjustment,
+			&a.Category, &a.Keywords, &a.AISummary, &a.TitleCN, &a.RecommendReason,
+			&a.Model, &a.PromptVersion,
+		); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// AdjustFunc returns the interest adjustment for an article from domain with
+// the given category and comma-separated keywords.
+type AdjustFunc func(domain, category, keywords string) int
+
+// RescoreAnalyses recomputes the score adjustment and total score of every
+// analysis with adjust, without asking the model again, and records each
+// changed analysis in its history. Returns the number of analyses whose
+// score changed.
+func (s *Store) RescoreAnalyses(adjust AdjustFunc) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	ids, err := rescore(tx, "", adjust)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	for _, id := range ids {
+		if err := recordVersion(tx, id, VersionOriginRescore, now); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), tx.Commit()
+}
+
+// rescore recomputes the score adjustment and total score of the analyses
+// selected by where and returns the IDs of the articles whose score changed.
+func rescore(tx *sql.Tx, where string, adjust AdjustFunc, args ...any) ([]int64, error) {
+	rows, err := tx.Query(`
+		SELECT a.id, a.blog_domain, aa.category, aa.keywords,
+		       aa.relevance, aa.quality, aa.timeliness, aa.total_score, aa.score_adjustment
+		FROM articles a
+		JOIN article_analysis aa ON aa.article_id = a.id
+		`+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	type update struct {
+		id                int64
+		adjustment, total int
+	}
+	var updates []update
+	for rows.Next() {
+		var id int64
+		var domain, category, keywords string
+		var relevance, quality, timeliness, total, adjustment int
+		if err := rows.Scan(&id, &domain, &category, &keywords, &relevance, &quality, &timeliness, &total, &adjustment); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		adj := adjust(domain, category, keywords)
+		if t := AdjustedTotal(relevance, quality, timeliness, adj); adj != adjustment || t != total {
+			updates = append(updates, update{id, adj, t})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(updates))
+	for _, u := range updates {
+		if _, err := tx.Exec(
+			"UPDATE article_analysis SET score_adjustment = ?, total_score = ? WHERE article_id = ?",
+			u.adjustment, u.total, u.id,
+		); err != nil {
+			return nil, err
+		}
+		ids = append(ids, u.id)
+	}
+	return ids, nil
+}
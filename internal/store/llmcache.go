@@ -0,0 +1,66 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// LLMCacheEntry is a cached model response.
+type LLMCacheEntry struct {
+	Key       string // hash of provider, model, prompts and temperature
+	Model     string
+	Operation string
+	Response  string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// CachedLLMResponse returns the unexpired response stored under key.
+func (s *Store) CachedLLMResponse(key string) (string, bool, error) {
+	var resp string
+	err := s.db.QueryRow(
+		"SELECT response FROM llm_cache WHERE key = ? AND expires_at > ?",
+		key, time.Now().UTC().Format(time.RFC3339),
+	).Scan(&resp)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return resp, true, nil
+}
+
+// SaveLLMResponse stores e, replacing any entry with the same key.
+func (s *Store) SaveLLMResponse(e LLMCacheEntry) error {
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	_, err := s.db.Exec(
+		`INSERT OR REPLACE INTO llm_cache (key, model, operation, response, created_at, expires_at)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		e.Key, e.Model, e.Operation, e.Response,
+		e.CreatedAt.UTC().Format(time.RFC3339), e.ExpiresAt.UTC().Format(time.RFC3339),
+	)
+	return err
+}
+
+// DeleteLLMResponse removes the entry stored under key, if any.
+func (s *Store) DeleteLLMResponse(key string) error {
+	_, err := s.db.Exec("DELETE FROM llm_cache WHERE key = ?", key)
+	return err
+}
+
+// PruneLLMCache deletes expired entries, or every entry if all is set, and
+// returns the number removed.
+func (s *Store) PruneLLMCache(all bool) (int64, error) {
+	query, args := "DELETE FROM llm_cache WHERE expires_at <= ?", []any{time.Now().UTC().Format(time.RFC3339)}
+	if all {
+		query, args = "DELETE FROM llm_cache", nil
+	}
+	res, err := s.db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
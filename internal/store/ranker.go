@@ -0,0 +1,104 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// TrainingExample is an analyzed article together with the reader signals
+// it received.
+type TrainingExample struct {
+	ArticleWithAnalysis
+	FeedbackTotals
+	Clicks int
+	// Notified reports whether the article went out in a notification, so a
+	// lack of signals means it was seen and ignored.
+	Notified bool
+}
+
+// TrainingExamples returns every analyzed article that received feedback or
+// clicks or was sent in a notification, oldest first.
+func (s *Store) TrainingExamples() ([]TrainingExample, error) {
+	rows, err := s.db.Query(`
+		SELECT a.id, a.blog_domain, a.title, a.url, a.summary, a.published_at, a.scraped_at,
+		       aa.id, aa.article_id, aa.relevance, aa.quality, aa.timeliness, aa.total_score,
+		       aa.score_adjustment, aa.category, aa.keywords, aa.analyzed_at,
+		       COALESCE(f.up, 0), COALESCE(f.down, 0), COALESCE(f.more, 0), COALESCE(f.mute, 0),
+		       COALESCE(c.n, 0), aa.notified_at IS NOT NULL
+		FROM articles a
+		JOIN article_analysis aa ON aa.article_id = a.id
+		LEFT JOIN (
+			SELECT article_id,
+			       SUM(action = 'up') AS up, SUM(action = 'down') AS down,
+			       SUM(action = 'more') AS more, SUM(action = 'mute') AS mute
+			FROM feedback
+			GROUP BY article_id
+		) f ON f.article_id = a.id
+		LEFT JOIN (
+			SELECT article_id, COUNT(*) AS n FROM clicks GROUP BY article_id
+		) c ON c.article_id = a.id
+		WHERE f.article_id IS NOT NULL OR c.article_id IS NOT NULL OR aa.notified_at IS NOT NULL
+		ORDER BY a.published_at, a.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var examples []TrainingExample
+	for rows.Next() {
+		var e TrainingExample
+		if err := rows.Scan(
+			&e.Article.ID, &e.Article.BlogDomain, &e.Article.Title, &e.Article.URL,
+			&e.Article.Summary, &e.Article.PublishedAt, &e.Article.ScrapedAt,
+			&e.ArticleAnalysis.ID, &e.ArticleAnalysis.ArticleID,
+			&e.ArticleAnalysis.Relevance, &e.ArticleAnalysis.Quality, &e.ArticleAnalysis.Timeliness,
+			&e.ArticleAnalysis.TotalScore, &e.ArticleAnalysis.ScoreAdjustment,
+			&e.ArticleAnalysis.Category, &e.ArticleAnalysis.Keywords, &e.ArticleAnalysis.AnalyzedAt,
+			&e.Up, &e.Down, &e.More, &e.Mute, &e.Clicks, &e.Notified,
+		); err != nil {
+			return nil, err
+		}
+		examples = append(examples, e)
+	}
+	return examples, rows.Err()
+}
+
+// RankerModel is a serialized ranking model.
+type RankerModel struct {
+	ID        int64
+	TrainedAt time.Time
+	Examples  int
+	Model     string
+}
+
+// SaveRankerModel stores a newly trained model; the latest one is used.
+func (s *Store) SaveRankerModel(m RankerModel) error {
+	if m.TrainedAt.IsZero() {
+		m.TrainedAt = time.Now()
+	}
+	_, err := s.db.Exec(
+		"INSERT INTO ranker_models (trained_at, examples, model) VALUES (?, ?, ?)",
+		m.TrainedAt.UTC().Format(time.RFC3339), m.Examples, m.Model,
+	)
+	return err
+}
+
+// LatestRankerModel returns the most recently trained model, or nil if none
+// has been trained.
+func (s *Store) LatestRankerModel() (*RankerModel, error) {
+	var m RankerModel
+	err := s.db.QueryRow(`
+		SELECT id, trained_at, examples, model
+		FROM ranker_models
+		ORDER BY id DESC
+		LIMIT 1
+	`).Scan(&m.ID, &m.TrainedAt, &m.Examples, &m.Model)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
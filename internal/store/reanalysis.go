@@ -0,0 +1,196 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// ReanalysisFilter selects analyzed articles to analyze again. Zero fields
+// don't filter; hidden articles are never selected.
+type ReanalysisFilter struct {
+	// Window (24h, 3days or 7days) selects articles published since its
+	// start. A run fixes the start as Since so resuming doesn't shift it.
+	Window   string    `json:"window,omitempty"`
+	Since    time.Time `json:"since,omitzero"`
+	Category string    `json:"category,omitempty"`
+	// MinScore and MaxScore bound the current total score, inclusive.
+	MinScore *int `json:"min_score,omitempty"`
+	MaxScore *int `json:"max_score,omitempty"`
+	// Outdated selects analyses not made with both Model and PromptVersion.
+	Outdated      bool   `json:"outdated,omitempty"`
+	Model         string `json:"model,omitempty"`
+	PromptVersion string `json:"prompt_version,omitempty"`
+	// Limit caps the number of articles a run analyzes.
+	Limit int `json:"limit,omitempty"`
+}
+
+// cutoff returns the earliest publication time f selects, or "" for all.
+func (f ReanalysisFilter) cutoff() (string, error) {
+	if !f.Since.IsZero() {
+		return f.Since.UTC().Format(time.RFC3339), nil
+	}
+	if f.Window == "" {
+		return "", nil
+	}
+	return windowCutoff(f.Window)
+}
+
+// ReanalysisArticles returns up to limit articles (0 for all) matching f
+// with an ID above afterID, in ID order so a run can resume where it
+// stopped.
+func (s *Store) ReanalysisArticles(f ReanalysisFilter, afterID int64, limit int) ([]ArticleWithAnalysis, error) {
+	conds := []string{"aa.hidden_at IS NULL", "a.id > ?"}
+	args := []any{afterID}
+	cutoff, err := f.cutoff()
+	if err != nil {
+		return nil, err
+	}
+	if cutoff != "" {
+		conds, args = append(conds, "a.published_at >= ?"), append(args, cutoff)
+	}
+	if f.Category != "" {
+		conds, args = append(conds, "aa.category = ?"), append(args, f.Category)
+	}
+	if f.MinScore != nil {
+		conds, args = append(conds, "aa.total_score >= ?"), append(args, *f.MinScore)
+	}
+	if f.MaxScore != nil {
+		conds, args = append(conds, "aa.total_score <= ?"), append(args, *f.MaxScore)
+	}
+	if f.Outdated {
+		conds, args = append(conds, "(aa.model != ? OR aa.prompt_version != ?)"), append(args, f.Model, f.PromptVersion)
+	}
+	query := `
+		SELECT a.id, a.blog_domain, a.title, a.url, a.summary, a.published_at, a.scraped_at,
+		       aa.id, aa.article_id, aa.relevance, aa.quality, aa.timeliness, aa.total_score,
+		       aa.category, aa.keywords, aa.ai_summary, aa.title_cn, aa.recommend_reason, aa.analyzed_at,
+		       aa.model, aa.prompt_version, aa.score_adjustment
+		FROM articles a
+		JOIN article_analysis aa ON a.id = aa.article_id
+		WHERE ` + strings.Join(conds, " AND ") + `
+		ORDER BY a.id`
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []ArticleWithAnalysis
+	for rows.Next() {
+		var r ArticleWithAnalysis
+		if err := rows.Scan(
+			&r.Article.ID, &r.Article.BlogDomain, &r.Article.Title, &r.Article.URL,
+			&r.Article.Summary, &r.Article.PublishedAt, &r.Article.ScrapedAt,
+			&r.ArticleAnalysis.ID, &r.ArticleAnalysis.ArticleID,
+			&r.ArticleAnalysis.Relevance, &r.ArticleAnalysis.Quality, &r.ArticleAnalysis.Timeliness,
+			&r.ArticleAnalysis.TotalScore, &r.ArticleAnalysis.Category, &r.ArticleAnalysis.Keywords,
+			&r.ArticleAnalysis.AISummary, &r.ArticleAnalysis.TitleCN, &r.ArticleAnalysis.RecommendReason,
+			&r.ArticleAnalysis.AnalyzedAt, &r.ArticleAnalysis.Model, &r.ArticleAnalysis.PromptVersion, &r.ArticleAnalysis.ScoreAdjustment,
+		); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// ReanalysisRun is the progress of one `newsbot reanalyze` run.
+type ReanalysisRun struct {
+	ID         int64
+	Filter     ReanalysisFilter
+	StartedAt  time.Time
+	FinishedAt *time.Time
+	// Total is the number of articles selected when the run started; Done
+	// and Failed count those processed so far.
+	Total  int
+	Done   int
+	Failed int
+	// LastArticleID is the last article processed; a resumed run continues
+	// after it.
+	LastArticleID int64
+}
+
+// Remaining returns how many of the selected articles are left.
+func (r *ReanalysisRun) Remaining() int {
+	return max(r.Total-r.Done-r.Failed, 0)
+}
+
+// StartReanalysisRun records a new run over total articles. A window in f
+// is fixed to its current start.
+func (s *Store) StartReanalysisRun(f ReanalysisFilter, total int) (*ReanalysisRun, error) {
+	cutoff, err := f.cutoff()
+	if err != nil {
+		return nil, err
+	}
+	if cutoff != "" {
+		f.Since, _ = time.Parse(time.RFC3339, cutoff)
+	}
+	filter, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+	run := &ReanalysisRun{Filter: f, StartedAt: time.Now().UTC().Truncate(time.Second), Total: total}
+	res, err := s.db.Exec(
+		"INSERT INTO reanalysis_runs (filter, started_at, total) VALUES (?, ?, ?)",
+		string(filter), run.StartedAt.Format(time.RFC3339), total,
+	)
+	if err != nil {
+		return nil, err
+	}
+	run.ID, err = res.LastInsertId()
+	return run, err
+}
+
+// LatestReanalysisRun returns the most recently started run, or nil if
+// there is none.
+func (s *Store) LatestReanalysisRun() (*ReanalysisRun, error) {
+	var run ReanalysisRun
+	var filter string
+	var finishedAt sql.NullString
+	err := s.db.QueryRow(`
+		SELECT id, filter, started_at, finished_at, total, done, failed, last_article_id
+		FROM reanalysis_runs
+		ORDER BY id DESC
+		LIMIT 1
+	`).Scan(&run.ID, &filter, &run.StartedAt, &finishedAt, &run.Total, &run.Done, &run.Failed, &run.LastArticleID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(filter), &run.Filter); err != nil {
+		return nil, err
+	}
+	run.FinishedAt = parseNullTime(finishedAt)
+	return &run, nil
+}
+
+// SaveReanalysisProgress stores a run's counts and last processed article.
+func (s *Store) SaveReanalysisProgress(run *ReanalysisRun) error {
+	_, err := s.db.Exec(
+		"UPDATE reanalysis_runs SET done = ?, failed = ?, last_article_id = ? WHERE id = ?",
+		run.Done, run.Failed, run.LastArticleID, run.ID,
+	)
+	return err
+}
+
+// FinishReanalysisRun marks a run as complete.
+func (s *Store) FinishReanalysisRun(run *ReanalysisRun) error {
+	now := time.Now().UTC().Truncate(time.Second)
+	_, err := s.db.Exec(
+		"UPDATE reanalysis_runs SET finished_at = ?, done = ?, failed = ?, last_article_id = ? WHERE id = ?",
+		now.Format(time.RFC3339), run.Done, run.Failed, run.LastArticleID, run.ID,
+	)
+	if err == nil {
+		run.FinishedAt = &now
+	}
+	return err
+}
@@ -10,13 +10,23 @@ import (
 )
 
 type Blog struct {
-	ID     int64
-	Domain string
-	Score  int
-	Author string
-	Rank   int
+	ID      int64
+	Domain  string
+	Score   int
+	Author  string
+	Rank    int
+	Source  string // BlogSourceHN or BlogSourceManual
+	MutedAt *time.Time
+	// DemotedAt is set while the source policy scrapes the blog less often.
+	DemotedAt *time.Time
 }
 
+// Where a blog came from.
+const (
+	BlogSourceHN     = "hn"     // HN popularity list, refreshed every run
+	BlogSourceManual = "manual" // added through the admin API
+)
+
 type Article struct {
 	ID          int64
 	BlogDomain  string
@@ -41,6 +51,23 @@ type ArticleAnalysis struct {
 	RecommendReason string
 	AnalyzedAt      time.Time
 	NotifiedAt      *time.Time
+	// HiddenAt is set when a moderator hid the analysis; hidden articles are
+	// left out of listings and digests.
+	HiddenAt *time.Time
+	// Model and PromptVersion identify the model and prompts the analysis
+	// was made with.
+	Model         string
+	PromptVersion string
+	// ScoreAdjustment is the interest-profile adjustment included in
+	// TotalScore; Relevance, Quality and Timeliness stay as the model
+	// returned them.
+	ScoreAdjustment int
+}
+
+// AdjustedTotal is the total score of the given model dimensions and
+// interest adjustment, never below zero.
+func AdjustedTotal(relevance, quality, timeliness, adjustment int) int {
+	return max(relevance+quality+timeliness+adjustment, 0)
 }
 
 type ArticleWithAnalysis struct {
@@ -48,11 +75,86 @@ type ArticleWithAnalysis struct {
 	ArticleAnalysis
 }
 
+// Subscriber statuses.
+const (
+	SubscriberPending   = "pending"
+	SubscriberConfirmed = "confirmed"
+	// SubscriberSuspended is set after repeated permanent delivery failures;
+	// confirming the address again reactivates it.
+	SubscriberSuspended = "suspended"
+)
+
+// Digest frequencies a subscriber can choose.
+const (
+	FrequencyInstant = "instant"
+	FrequencyDaily   = "daily"
+	FrequencyWeekly  = "weekly"
+)
+
+// Digest content languages every analysis has. Subscribers may also choose
+// a translation language, given by its code such as "ja".
+const (
+	LanguageChinese   = "zh"
+	LanguageEnglish   = "en"
+	LanguageBilingual = "bilingual"
+)
+
 type Subscriber struct {
-	ID        int64
-	Email     string
-	Token     string
-	CreatedAt time.Time
+	ID          int64
+	Email       string
+	Token       string
+	Status      string
+	CreatedAt   time.Time
+	ConfirmedAt *time.Time
+	SubscriberPreferences
+	LastSentAt *time.Time
+	// BounceCount is the number of permanent delivery failures since the
+	// last successful send.
+	BounceCount int
+}
+
+// SubscriberPreferences controls what a subscriber receives and how often.
+type SubscriberPreferences struct {
+	Frequency  string   `json:"frequency"`
+	Categories []string `json:"categories"` // empty means all categories
+	MinScore   int      `json:"min_score"`
+	Language   string   `json:"language"`
+}
+
+// Validate checks that the preference values are supported.
+func (p SubscriberPreferences) Validate() error {
+	switch p.Frequency {
+	case FrequencyInstant, FrequencyDaily, FrequencyWeekly:
+	default:
+		return fmt.Errorf("unsupported frequency: %s", p.Frequency)
+	}
+	switch p.Language {
+	case LanguageChinese, LanguageEnglish, LanguageBilingual:
+	default:
+		if !validLanguageCode(p.Language) {
+			return fmt.Errorf("unsupported language: %s", p.Language)
+		}
+	}
+	if p.MinScore < 0 || p.MinScore > 30 {
+		return fmt.Errorf("min_score must be between 0 and 30")
+	}
+	return nil
+}
+
+// validLanguageCode reports whether code looks like a language tag: a
+// 2-3 letter primary subtag, optionally followed by "-" and a region or
+// script, e.g. "ja", "pt-BR" or "zh-Hant".
+func validLanguageCode(code string) bool {
+	primary, rest, hasRest := strings.Cut(code, "-")
+	if len(primary) < 2 || len(primary) > 3 || (hasRest && (len(rest) < 2 || len(rest) > 4)) {
+		return false
+	}
+	for _, c := range primary + rest {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
 }
 
 type Store struct {
@@ -148,6 +250,306 @@ func (s *Store) migrate() error {
 		return err
 	}
 
+	// Double opt-in: subscribers created before this existed count as confirmed
+	// (ignore errors if columns already exist).
+	s.db.Exec("ALTER TABLE subscribers ADD COLUMN status TEXT NOT NULL DEFAULT 'confirmed'")
+	s.db.Exec("ALTER TABLE subscribers ADD COLUMN confirmed_at DATETIME")
+
+	// When the last confirmation email went out, to throttle re-sends
+	// (ignore error if column already exists).
+	s.db.Exec("ALTER TABLE subscribers ADD COLUMN confirm_sent_at DATETIME")
+
+	// Per-subscriber digest preferences (ignore errors if columns already exist).
+	s.db.Exec("ALTER TABLE subscribers ADD COLUMN frequency TEXT NOT NULL DEFAULT 'instant'")
+	s.db.Exec("ALTER TABLE subscribers ADD COLUMN categories TEXT NOT NULL DEFAULT ''")
+	s.db.Exec("ALTER TABLE subscribers ADD COLUMN min_score INTEGER NOT NULL DEFAULT 0")
+	s.db.Exec("ALTER TABLE subscribers ADD COLUMN language TEXT NOT NULL DEFAULT 'bilingual'")
+	s.db.Exec("ALTER TABLE subscribers ADD COLUMN last_sent_at DATETIME")
+
+	// Reader feedback collected from Telegram inline keyboards (idempotent).
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS feedback (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			article_id INTEGER NOT NULL REFERENCES articles(id),
+			action     TEXT NOT NULL,
+			channel    TEXT NOT NULL DEFAULT '',
+			user_ref   TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(article_id, channel, user_ref, action)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_feedback_created_at ON feedback(created_at);
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Article link clicks, one row per click, and the rankers trained from
+	// them and the feedback above (idempotent).
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS clicks (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			article_id INTEGER NOT NULL REFERENCES articles(id),
+			channel    TEXT NOT NULL DEFAULT '',
+			user_ref   TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_clicks_article ON clicks(article_id);
+
+		CREATE TABLE IF NOT EXISTS ranker_models (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			trained_at DATETIME NOT NULL,
+			examples   INTEGER NOT NULL DEFAULT 0,
+			model      TEXT NOT NULL
+		);
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Article title, summary and recommendation reason per target language
+	// (idempotent).
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS translations (
+			article_id INTEGER NOT NULL REFERENCES articles(id),
+			language   TEXT NOT NULL,
+			title      TEXT NOT NULL DEFAULT '',
+			summary    TEXT NOT NULL DEFAULT '',
+			reason     TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL,
+			PRIMARY KEY (article_id, language)
+		);
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Progress of `newsbot reanalyze` runs so interrupted ones can resume
+	// (idempotent).
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS reanalysis_runs (
+			id              INTEGER PRIMARY KEY AUTOINCREMENT,
+			filter          TEXT NOT NULL,
+			started_at      DATETIME NOT NULL,
+			finished_at     DATETIME,
+			total           INTEGER NOT NULL DEFAULT 0,
+			done            INTEGER NOT NULL DEFAULT 0,
+			failed          INTEGER NOT NULL DEFAULT 0,
+			last_article_id INTEGER NOT NULL DEFAULT 0
+		);
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Bounce tracking: failures since the last successful send, plus a log of
+	// every bounce and complaint (idempotent).
+	s.db.Exec("ALTER TABLE subscribers ADD COLUMN bounce_count INTEGER NOT NULL DEFAULT 0")
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS bounces (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			email      TEXT NOT NULL,
+			kind       TEXT NOT NULL,
+			detail     TEXT NOT NULL DEFAULT '',
+			message_id TEXT,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(message_id, email)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_bounces_email ON bounces(email);
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Add muted_at column to blogs (ignore error if column already exists).
+	s.db.Exec("ALTER TABLE blogs ADD COLUMN muted_at DATETIME")
+
+	// Moderation: manually added blogs and hidden analyses (ignore errors if
+	// columns already exist).
+	s.db.Exec("ALTER TABLE blogs ADD COLUMN source TEXT NOT NULL DEFAULT 'hn'")
+	s.db.Exec("ALTER TABLE article_analysis ADD COLUMN hidden_at DATETIME")
+
+	// Source policy: demoted blogs and a log of every feed fetch (idempotent;
+	// ignore error if the column already exists).
+	s.db.Exec("ALTER TABLE blogs ADD COLUMN demoted_at DATETIME")
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS feed_fetches (
+			id          INTEGER PRIMARY KEY AUTOINCREMENT,
+			blog_domain TEXT NOT NULL,
+			fetched_at  DATETIME NOT NULL,
+			ok          INTEGER NOT NULL,
+			articles    INTEGER NOT NULL DEFAULT 0,
+			error       TEXT NOT NULL DEFAULT ''
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_feed_fetches_blog ON feed_fetches(blog_domain, fetched_at);
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Version of the prompts each analysis was made with (ignore error if
+	// column already exists).
+	s.db.Exec("ALTER TABLE article_analysis ADD COLUMN prompt_version TEXT NOT NULL DEFAULT ''")
+
+	// Model each analysis was made with (ignore error if column already
+	// exists).
+	s.db.Exec("ALTER TABLE article_analysis ADD COLUMN model TEXT NOT NULL DEFAULT ''")
+
+	// Interest-profile adjustment included in total_score (ignore error if
+	// column already exists).
+	s.db.Exec("ALTER TABLE article_analysis ADD COLUMN score_adjustment INTEGER NOT NULL DEFAULT 0")
+
+	// When an article was first analyzed; re-analysis only moves analyzed_at
+	// (ignore error if column already exists). Existing analyses start from
+	// their current analyzed_at.
+	s.db.Exec("ALTER TABLE article_analysis ADD COLUMN first_analyzed_at DATETIME")
+	s.db.Exec("UPDATE article_analysis SET first_analyzed_at = analyzed_at WHERE first_analyzed_at IS NULL")
+
+	// Stored trend reports, the articles each was generated from, and the
+	// articles each trend refers to (idempotent).
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS trend_reports (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			window     TEXT NOT NULL,
+			model      TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_trend_reports_window ON trend_reports(window, id);
+
+		CREATE TABLE IF NOT EXISTS trend_report_articles (
+			report_id  INTEGER NOT NULL REFERENCES trend_reports(id) ON DELETE CASCADE,
+			article_id INTEGER NOT NULL REFERENCES articles(id),
+			PRIMARY KEY (report_id, article_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_trend_report_articles_article ON trend_report_articles(article_id);
+
+		CREATE TABLE IF NOT EXISTS trends (
+			id          INTEGER PRIMARY KEY AUTOINCREMENT,
+			report_id   INTEGER NOT NULL REFERENCES trend_reports(id) ON DELETE CASCADE,
+			position    INTEGER NOT NULL DEFAULT 0,
+			title       TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			articles    TEXT NOT NULL DEFAULT '[]'
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_trends_report ON trends(report_id);
+
+		CREATE TABLE IF NOT EXISTS trend_articles (
+			trend_id   INTEGER NOT NULL REFERENCES trends(id) ON DELETE CASCADE,
+			article_id INTEGER NOT NULL REFERENCES articles(id),
+			PRIMARY KEY (trend_id, article_id)
+		);
+	`)
+	if err != nil {
+		return err
+	}
+
+	// One row per analysis keyword, for analytics (idempotent). Existing
+	// analyses are split from their comma-joined keywords once.
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS article_keywords (
+			article_id INTEGER NOT NULL REFERENCES articles(id),
+			keyword    TEXT NOT NULL,
+			PRIMARY KEY (article_id, keyword)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_article_keywords_keyword ON article_keywords(keyword);
+	`)
+	if err != nil {
+		return err
+	}
+	if err := s.backfillKeywords(); err != nil {
+		return err
+	}
+
+	// Cached LLM responses keyed by a hash of the request (idempotent).
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS llm_cache (
+			key        TEXT PRIMARY KEY,
+			model      TEXT NOT NULL DEFAULT '',
+			operation  TEXT NOT NULL DEFAULT '',
+			response   TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			expires_at DATETIME NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_llm_cache_expires ON llm_cache(expires_at);
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Token usage reported for each model call (idempotent).
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS llm_usage (
+			id                INTEGER PRIMARY KEY AUTOINCREMENT,
+			run_id            TEXT NOT NULL DEFAULT '',
+			operation         TEXT NOT NULL,
+			model             TEXT NOT NULL DEFAULT '',
+			article_id        INTEGER REFERENCES articles(id),
+			prompt_tokens     INTEGER NOT NULL DEFAULT 0,
+			completion_tokens INTEGER NOT NULL DEFAULT 0,
+			created_at        DATETIME NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_llm_usage_created ON llm_usage(created_at);
+		CREATE INDEX IF NOT EXISTS idx_llm_usage_run ON llm_usage(run_id);
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Every analysis saved for an article, including moderator edits;
+	// article_analysis holds the current one (idempotent). Existing
+	// analyses are copied in once.
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS analysis_versions (
+			id               INTEGER PRIMARY KEY AUTOINCREMENT,
+			article_id       INTEGER NOT NULL REFERENCES articles(id),
+			origin           TEXT NOT NULL,
+			relevance        INTEGER NOT NULL,
+			quality          INTEGER NOT NULL,
+			timeliness       INTEGER NOT NULL,
+			total_score      INTEGER NOT NULL,
+			score_adjustment INTEGER NOT NULL DEFAULT 0,
+			category         TEXT NOT NULL DEFAULT '',
+			keywords         TEXT NOT NULL DEFAULT '',
+			ai_summary       TEXT NOT NULL DEFAULT '',
+			title_cn         TEXT NOT NULL DEFAULT '',
+			recommend_reason TEXT NOT NULL DEFAULT '',
+			model            TEXT NOT NULL DEFAULT '',
+			prompt_version   TEXT NOT NULL DEFAULT '',
+			created_at       DATETIME NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_analysis_versions_article ON analysis_versions(article_id, id);
+	`)
+	if err != nil {
+		return err
+	}
+	if err := s.backfillAnalysisVersions(); err != nil {
+		return err
+	}
+
+	// Articles each subscriber has received by email, so a digest never
+	// repeats one (idempotent).
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS digest_articles (
+			subscriber_id INTEGER NOT NULL,
+			article_id    INTEGER NOT NULL REFERENCES articles(id),
+			sent_at       DATETIME NOT NULL,
+			PRIMARY KEY (subscriber_id, article_id)
+		);
+	`)
+	if err != nil {
+		return err
+	}
+
 	// Normalize existing published_at timestamps from Go's default format to RFC3339.
 	// e.g. "2026-02-17 12:01:45 +0000 UTC" → "2026-02-17T12:01:45Z"
 	s.db.Exec(`UPDATE articles SET published_at = REPLACE(SUBSTR(published_at, 1, 19), ' ', 'T') || 'Z' WHERE published_at LIKE '% +0000 UTC'`)
@@ -198,7 +600,11 @@ func (s *Store) SaveArticle(a Article) error {
 
 // ListBlogs returns all blogs ordered by rank.
 func (s *Store) ListBlogs() ([]Blog, error) {
-	rows, err := s.db.Query("SELECT id, domain, score, author, rank FROM blogs ORDER BY rank ASC")
+	return s.queryBlogs("SELECT id, domain, score, author, rank, source, muted_at, demoted_at FROM blogs ORDER BY rank ASC")
+}
+
+func (s *Store) queryBlogs(query string, args ...any) ([]Blog, error) {
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -207,9 +613,12 @@ func (s *Store) ListBlogs() ([]Blog, error) {
 	var blogs []Blog
 	for rows.Next() {
 		var b Blog
-		if err := rows.Scan(&b.ID, &b.Domain, &b.Score, &b.Author, &b.Rank); err != nil {
+		var mutedAt, demotedAt sql.NullString
+		if err := rows.Scan(&b.ID, &b.Domain, &b.Score, &b.Author, &b.Rank, &b.Source, &mutedAt, &demotedAt); err != nil {
 			return nil, err
 		}
+		b.MutedAt = parseNullTime(mutedAt)
+		b.DemotedAt = parseNullTime(demotedAt)
 		blogs = append(blogs, b)
 	}
 	return blogs, rows.Err()
@@ -304,13 +713,15 @@ func (s *Store) GetArticleWithAnalysis(id int64) (*ArticleWithAnalysis, error) {
 	row := s.db.QueryRow(`
 		SELECT a.id, a.blog_domain, a.title, a.url, a.summary, a.published_at, a.scraped_at,
 		       aa.id, aa.article_id, aa.relevance, aa.quality, aa.timeliness, aa.total_score,
-		       aa.category, aa.keywords, aa.ai_summary, aa.title_cn, aa.recommend_reason, aa.analyzed_at
+		       aa.category, aa.keywords, aa.ai_summary, aa.title_cn, aa.recommend_reason, aa.analyzed_at,
+		       aa.hidden_at, aa.model, aa.prompt_version, aa.score_adjustment
 		FROM articles a
 		JOIN article_analysis aa ON a.id = aa.article_id
 		WHERE a.id = ?
 	`, id)
 
 	var r ArticleWithAnalysis
+	var hiddenAt sql.NullString
 	err := row.Scan(
 		&r.Article.ID, &r.Article.BlogDomain, &r.Article.Title, &r.Article.URL,
 		&r.Article.Summary, &r.Article.PublishedAt, &r.Article.ScrapedAt,
@@ -318,7 +729,7 @@ func (s *Store) GetArticleWithAnalysis(id int64) (*ArticleWithAnalysis, error) {
 		&r.ArticleAnalysis.Relevance, &r.ArticleAnalysis.Quality, &r.ArticleAnalysis.Timeliness,
 		&r.ArticleAnalysis.TotalScore, &r.ArticleAnalysis.Category, &r.ArticleAnalysis.Keywords,
 		&r.ArticleAnalysis.AISummary, &r.ArticleAnalysis.TitleCN, &r.ArticleAnalysis.RecommendReason,
-		&r.ArticleAnalysis.AnalyzedAt,
+		&r.ArticleAnalysis.AnalyzedAt, &hiddenAt, &r.ArticleAnalysis.Model, &r.ArticleAnalysis.PromptVersion, &r.ArticleAnalysis.ScoreAdjustment,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -326,6 +737,7 @@ func (s *Store) GetArticleWithAnalysis(id int64) (*ArticleWithAnalysis, error) {
 	if err != nil {
 		return nil, err
 	}
+	r.ArticleAnalysis.HiddenAt = parseNullTime(hiddenAt)
 	return &r, nil
 }
 
@@ -340,12 +752,14 @@ func (s *Store) UnsummarizedHighScoreArticles(window string, minScore int) ([]Ar
 	rows, err := s.db.Query(`
 		SELECT a.id, a.blog_domain, a.title, a.url, a.summary, a.published_at, a.scraped_at,
 		       aa.id, aa.article_id, aa.relevance, aa.quality, aa.timeliness, aa.total_score,
-		       aa.category, aa.keywords, aa.ai_summary, aa.title_cn, aa.recommend_reason, aa.analyzed_at
+		       aa.category, aa.keywords, aa.ai_summary, aa.title_cn, aa.recommend_reason, aa.analyzed_at,
+		       aa.model, aa.prompt_version, aa.score_adjustment
 		FROM articles a
 		JOIN article_analysis aa ON a.id = aa.article_id
 		WHERE a.published_at >= ?
 		  AND aa.total_score >= ?
 		  AND aa.ai_summary = ''
+		  AND aa.hidden_at IS NULL
 		ORDER BY aa.total_score DESC, a.published_at DESC
 	`, cutoff, minScore)
 	if err != nil {
@@ -363,7 +777,7 @@ func (s *Store) UnsummarizedHighScoreArticles(window string, minScore int) ([]Ar
 			&r.ArticleAnalysis.Relevance, &r.ArticleAnalysis.Quality, &r.ArticleAnalysis.Timeliness,
 			&r.ArticleAnalysis.TotalScore, &r.ArticleAnalysis.Category, &r.ArticleAnalysis.Keywords,
 			&r.ArticleAnalysis.AISummary, &r.ArticleAnalysis.TitleCN, &r.ArticleAnalysis.RecommendReason,
-			&r.ArticleAnalysis.AnalyzedAt,
+			&r.ArticleAnalysis.AnalyzedAt, &r.ArticleAnalysis.Model, &r.ArticleAnalysis.PromptVersion, &r.ArticleAnalysis.ScoreAdjustment,
 		); err != nil {
 			return nil, err
 		}
@@ -372,11 +786,22 @@ func (s *Store) UnsummarizedHighScoreArticles(window string, minScore int) ([]Ar
 	return results, rows.Err()
 }
 
-// SaveArticleAnalysis upserts an analysis result for an article.
+// SaveArticleAnalysis upserts an analysis result for an article and appends
+// it to the article's analysis history. Translations of a changed title,
+// summary or reason are dropped.
 func (s *Store) SaveArticleAnalysis(a ArticleAnalysis) error {
-	_, err := s.db.Exec(`
-		INSERT INTO article_analysis (article_id, relevance, quality, timeliness, total_score, category, keywords, ai_summary, title_cn, recommend_reason, analyzed_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := dropStaleTranslations(tx, a.ArticleID, &a.TitleCN, &a.AISummary, &a.RecommendReason); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO article_analysis (article_id, relevance, quality, timeliness, total_score, category, keywords, ai_summary, title_cn, recommend_reason, analyzed_at, first_analyzed_at, model, prompt_version, score_adjustment)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(article_id) DO UPDATE SET
 			relevance        = excluded.relevance,
 			quality          = excluded.quality,
@@ -387,9 +812,21 @@ func (s *Store) SaveArticleAnalysis(a ArticleAnalysis) error {
 			ai_summary       = excluded.ai_summary,
 			title_cn         = excluded.title_cn,
 			recommend_reason = excluded.recommend_reason,
-			analyzed_at      = excluded.analyzed_at
-	`, a.ArticleID, a.Relevance, a.Quality, a.Timeliness, a.TotalScore, a.Category, a.Keywords, a.AISummary, a.TitleCN, a.RecommendReason, a.AnalyzedAt.UTC().Format(time.RFC3339))
-	return err
+			analyzed_at      = excluded.analyzed_at,
+			model            = excluded.model,
+			prompt_version   = excluded.prompt_version,
+			score_adjustment = excluded.score_adjustment
+	`, a.ArticleID, a.Relevance, a.Quality, a.Timeliness, a.TotalScore, a.Category, a.Keywords, a.AISummary, a.TitleCN, a.RecommendReason, a.AnalyzedAt.UTC().Format(time.RFC3339), a.AnalyzedAt.UTC().Format(time.RFC3339), a.Model, a.PromptVersion, a.ScoreAdjustment)
+	if err != nil {
+		return err
+	}
+	if err := replaceKeywords(tx, a.ArticleID, a.Keywords); err != nil {
+		return err
+	}
+	if err := recordVersion(tx, a.ArticleID, VersionOriginModel, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // TopScoredArticles returns top scored articles with their analysis within a time window.
@@ -405,7 +842,7 @@ func (s *Store) TopScoredArticles(limit int, window string) ([]ArticleWithAnalys
 		       aa.category, aa.keywords, aa.ai_summary, aa.title_cn, aa.recommend_reason, aa.analyzed_at
 		FROM articles a
 		JOIN article_analysis aa ON a.id = aa.article_id
-		WHERE a.published_at >= ?
+		WHERE a.published_at >= ? AND aa.hidden_at IS NULL
 		ORDER BY aa.total_score DESC, a.published_at DESC
 		LIMIT ?
 	`, cutoff, limit)
@@ -451,7 +888,7 @@ func (s *Store) TopScoredArticlesByCategory(limit int, window, category string)
 		       aa.category, aa.keywords, aa.ai_summary, aa.title_cn, aa.recommend_reason, aa.analyzed_at
 		FROM articles a
 		JOIN article_analysis aa ON a.id = aa.article_id
-		WHERE a.published_at >= ? AND aa.category = ?
+		WHERE a.published_at >= ? AND aa.category = ? AND aa.hidden_at IS NULL
 		ORDER BY aa.total_score DESC, a.published_at DESC
 		LIMIT ?
 	`, cutoff, category, limit)
@@ -522,7 +959,7 @@ func (s *Store) CategoriesForWindow(window string) ([]string, error) {
 		SELECT DISTINCT aa.category
 		FROM articles a
 		JOIN article_analysis aa ON a.id = aa.article_id
-		WHERE a.published_at >= ? AND aa.category != ''
+		WHERE a.published_at >= ? AND aa.category != '' AND aa.hidden_at IS NULL
 		ORDER BY aa.category
 	`, cutoff)
 	if err != nil {
@@ -541,8 +978,9 @@ func (s *Store) CategoriesForWindow(window string) ([]string, error) {
 	return categories, rows.Err()
 }
 
-// UnnotifiedAnalyses returns top scored articles that have not been notified yet within a time window.
-func (s *Store) UnnotifiedAnalyses(window string) ([]ArticleWithAnalysis, error) {
+// UnnotifiedAnalyses returns up to limit top scored articles that have not been notified yet within a time window.
+// Articles from muted blogs are skipped.
+func (s *Store) UnnotifiedAnalyses(window string, limit int) ([]ArticleWithAnalysis, error) {
 	cutoff, err := windowCutoff(window)
 	if err != nil {
 		return nil, err
@@ -551,14 +989,17 @@ func (s *Store) UnnotifiedAnalyses(window string) ([]ArticleWithAnalysis, error)
 	rows, err := s.db.Query(`
 		SELECT a.id, a.blog_domain, a.title, a.url, a.summary, a.published_at, a.scraped_at,
 		       aa.id, aa.article_id, aa.relevance, aa.quality, aa.timeliness, aa.total_score,
-		       aa.category, aa.keywords, aa.ai_summary, aa.title_cn, aa.recommend_reason, aa.analyzed_at
+		       aa.category, aa.keywords, aa.ai_summary, aa.title_cn, aa.recommend_reason, aa.analyzed_at,
+		       aa.score_adjustment
 		FROM articles a
 		JOIN article_analysis aa ON a.id = aa.article_id
 		WHERE a.published_at >= ?
 		  AND aa.notified_at IS NULL
+		  AND aa.hidden_at IS NULL
+		  AND a.blog_domain NOT IN (SELECT domain FROM blogs WHERE muted_at IS NOT NULL)
 		ORDER BY aa.total_score DESC, a.published_at DESC
-		LIMIT 20
-	`, cutoff)
+		LIMIT ?
+	`, cutoff, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -574,7 +1015,61 @@ func (s *Store) UnnotifiedAnalyses(window string) ([]ArticleWithAnalysis, error)
 			&r.ArticleAnalysis.Relevance, &r.ArticleAnalysis.Quality, &r.ArticleAnalysis.Timeliness,
 			&r.ArticleAnalysis.TotalScore, &r.ArticleAnalysis.Category, &r.ArticleAnalysis.Keywords,
 			&r.ArticleAnalysis.AISummary, &r.ArticleAnalysis.TitleCN, &r.ArticleAnalysis.RecommendReason,
-			&r.ArticleAnalysis.AnalyzedAt,
+			&r.ArticleAnalysis.AnalyzedAt, &r.ArticleAnalysis.ScoreAdjustment,
+		); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// DigestArticles returns top scored articles first analyzed since the given
+// time, restricted to the given categories (all when empty) and minimum total
+// score. Articles from muted blogs and articles already sent to the subscriber
+// are skipped.
+func (s *Store) DigestArticles(subscriberID int64, since time.Time, categories []string, minScore, limit int) ([]ArticleWithAnalysis, error) {
+	query := `
+		SELECT a.id, a.blog_domain, a.title, a.url, a.summary, a.published_at, a.scraped_at,
+		       aa.id, aa.article_id, aa.relevance, aa.quality, aa.timeliness, aa.total_score,
+		       aa.category, aa.keywords, aa.ai_summary, aa.title_cn, aa.recommend_reason, aa.analyzed_at,
+		       aa.score_adjustment
+		FROM articles a
+		JOIN article_analysis aa ON a.id = aa.article_id
+		WHERE aa.first_analyzed_at >= ?
+		  AND aa.total_score >= ?
+		  AND aa.hidden_at IS NULL
+		  AND a.blog_domain NOT IN (SELECT domain FROM blogs WHERE muted_at IS NOT NULL)
+		  AND a.id NOT IN (SELECT article_id FROM digest_articles WHERE subscriber_id = ?)`
+	args := []interface{}{since.UTC().Format(time.RFC3339), minScore, subscriberID}
+	if len(categories) > 0 {
+		placeholders := make([]string, len(categories))
+		for i, c := range categories {
+			placeholders[i] = "?"
+			args = append(args, c)
+		}
+		query += fmt.Sprintf(" AND aa.category IN (%s)", strings.Join(placeholders, ","))
+	}
+	query += " ORDER BY aa.total_score DESC, a.published_at DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []ArticleWithAnalysis
+	for rows.Next() {
+		var r ArticleWithAnalysis
+		if err := rows.Scan(
+			&r.Article.ID, &r.Article.BlogDomain, &r.Article.Title, &r.Article.URL,
+			&r.Article.Summary, &r.Article.PublishedAt, &r.Article.ScrapedAt,
+			&r.ArticleAnalysis.ID, &r.ArticleAnalysis.ArticleID,
+			&r.ArticleAnalysis.Relevance, &r.ArticleAnalysis.Quality, &r.ArticleAnalysis.Timeliness,
+			&r.ArticleAnalysis.TotalScore, &r.ArticleAnalysis.Category, &r.ArticleAnalysis.Keywords,
+			&r.ArticleAnalysis.AISummary, &r.ArticleAnalysis.TitleCN, &r.ArticleAnalysis.RecommendReason,
+			&r.ArticleAnalysis.AnalyzedAt, &r.ArticleAnalysis.ScoreAdjustment,
 		); err != nil {
 			return nil, err
 		}
@@ -604,11 +1099,37 @@ func (s *Store) MarkNotified(articleIDs []int64) error {
 	return err
 }
 
-// AddSubscriber inserts a new email subscriber. Returns false if already exists.
+// AddSubscriber inserts a new pending email subscriber. Returns false if the
+// address already exists.
 func (s *Store) AddSubscriber(email, token string) (bool, error) {
 	res, err := s.db.Exec(
-		"INSERT OR IGNORE INTO subscribers (email, token, created_at) VALUES (?, ?, ?)",
-		email, token, time.Now().UTC().Format(time.RFC3339),
+		"INSERT OR IGNORE INTO subscribers (email, token, status, created_at) VALUES (?, ?, ?, ?)",
+		email, token, SubscriberPending, time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// GetSubscriberByEmail returns the subscriber with the given address, or nil.
+func (s *Store) GetSubscriberByEmail(email string) (*Subscriber, error) {
+	row := s.db.QueryRow("SELECT "+subscriberColumns+" FROM subscribers WHERE email = ?", email)
+	sub, err := scanSubscriber(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return sub, err
+}
+
+// ConfirmSubscriber marks a pending or suspended subscriber as confirmed and
+// clears its bounce count. Returns false if the address is unknown or was
+// already confirmed.
+func (s *Store) ConfirmSubscriber(email string) (bool, error) {
+	res, err := s.db.Exec(
+		"UPDATE subscribers SET status = ?, confirmed_at = ?, bounce_count = 0 WHERE email = ? AND status IN (?, ?)",
+		SubscriberConfirmed, time.Now().UTC().Format(time.RFC3339), email, SubscriberPending, SubscriberSuspended,
 	)
 	if err != nil {
 		return false, err
@@ -617,9 +1138,41 @@ func (s *Store) AddSubscriber(email, token string) (bool, error) {
 	return n > 0, nil
 }
 
-// ListSubscribers returns all subscribers.
+// ClaimConfirmationSend records that a confirmation email is being sent to
+// an address, unless one was sent less than cooldown ago. It returns false
+// when the send should be skipped.
+func (s *Store) ClaimConfirmationSend(email string, cooldown time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	res, err := s.db.Exec(
+		"UPDATE subscribers SET confirm_sent_at = ? WHERE email = ? AND (confirm_sent_at IS NULL OR confirm_sent_at <= ?)",
+		now.Format(time.RFC3339), email, now.Add(-cooldown).Format(time.RFC3339),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// DeletePendingSubscribers removes subscribers that never confirmed and were
+// created before the cutoff. Returns the number of rows removed.
+func (s *Store) DeletePendingSubscribers(before time.Time) (int64, error) {
+	res, err := s.db.Exec(
+		"DELETE FROM subscribers WHERE status = ? AND created_at < ?",
+		SubscriberPending, before.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ListSubscribers returns all confirmed subscribers.
 func (s *Store) ListSubscribers() ([]Subscriber, error) {
-	rows, err := s.db.Query("SELECT id, email, token, created_at FROM subscribers ORDER BY id")
+	rows, err := s.db.Query(
+		"SELECT "+subscriberColumns+" FROM subscribers WHERE status = ? ORDER BY id",
+		SubscriberConfirmed,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -627,17 +1180,102 @@ func (s *Store) ListSubscribers() ([]Subscriber, error) {
 
 	var results []Subscriber
 	for rows.Next() {
-		var sub Subscriber
-		var createdAt string
-		if err := rows.Scan(&sub.ID, &sub.Email, &sub.Token, &createdAt); err != nil {
+		sub, err := scanSubscriber(rows)
+		if err != nil {
 			return nil, err
 		}
-		sub.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
-		results = append(results, sub)
+		results = append(results, *sub)
 	}
 	return results, rows.Err()
 }
 
+// GetSubscriberByToken returns the subscriber owning the given token, or nil.
+func (s *Store) GetSubscriberByToken(token string) (*Subscriber, error) {
+	row := s.db.QueryRow("SELECT "+subscriberColumns+" FROM subscribers WHERE token = ?", token)
+	sub, err := scanSubscriber(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return sub, err
+}
+
+// UpdateSubscriberPreferences stores new digest preferences for the
+// subscriber owning token. Returns false if the token is unknown.
+func (s *Store) UpdateSubscriberPreferences(token string, p SubscriberPreferences) (bool, error) {
+	if err := p.Validate(); err != nil {
+		return false, err
+	}
+	res, err := s.db.Exec(
+		"UPDATE subscribers SET frequency = ?, categories = ?, min_score = ?, language = ? WHERE token = ?",
+		p.Frequency, strings.Join(p.Categories, ","), p.MinScore, p.Language, token,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// MarkSubscriberSent records when a subscriber last received a digest and
+// the articles it contained. A successful send also resets the bounce count.
+func (s *Store) MarkSubscriberSent(id int64, at time.Time, articleIDs []int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sentAt := at.UTC().Format(time.RFC3339)
+	if _, err := tx.Exec("UPDATE subscribers SET last_sent_at = ?, bounce_count = 0 WHERE id = ?", sentAt, id); err != nil {
+		return err
+	}
+	for _, articleID := range articleIDs {
+		if _, err := tx.Exec(
+			"INSERT OR IGNORE INTO digest_articles (subscriber_id, article_id, sent_at) VALUES (?, ?, ?)",
+			id, articleID, sentAt,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+const subscriberColumns = "id, email, token, status, created_at, confirmed_at, frequency, categories, min_score, language, last_sent_at, bounce_count"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSubscriber(row rowScanner) (*Subscriber, error) {
+	var sub Subscriber
+	var createdAt, categories string
+	var confirmedAt, lastSentAt sql.NullString
+	if err := row.Scan(&sub.ID, &sub.Email, &sub.Token, &sub.Status, &createdAt, &confirmedAt,
+		&sub.Frequency, &categories, &sub.MinScore, &sub.Language, &lastSentAt, &sub.BounceCount); err != nil {
+		return nil, err
+	}
+	sub.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	sub.ConfirmedAt = parseNullTime(confirmedAt)
+	sub.LastSentAt = parseNullTime(lastSentAt)
+	for _, c := range strings.Split(categories, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			sub.Categories = append(sub.Categories, c)
+		}
+	}
+	return &sub, nil
+}
+
+func parseNullTime(v sql.NullString) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, v.String)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
 // RemoveSubscriberByToken deletes a subscriber by their unsubscribe token.
 func (s *Store) RemoveSubscriberByToken(token string) (bool, error) {
 	res, err := s.db.Exec("DELETE FROM subscribers WHERE token = ?", token)
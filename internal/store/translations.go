@@ -0,0 +1,132 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Translation is an article's title, summary and recommendation reason in
+// one target language.
+type Translation struct {
+	ArticleID int64
+	Language  string
+	Title     string
+	Summary   string
+	Reason    string
+	CreatedAt time.Time
+}
+
+// SaveTranslation inserts or replaces the translation of an article into
+// t.Language.
+func (s *Store) SaveTranslation(t Translation) error {
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = time.Now()
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO translations (article_id, language, title, summary, reason, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(article_id, language) DO UPDATE SET
+			title = excluded.title,
+			summary = excluded.summary,
+			reason = excluded.reason,
+			created_at = excluded.created_at
+	`, t.ArticleID, t.Language, t.Title, t.Summary, t.Reason, t.CreatedAt.UTC().Format(time.RFC3339))
+	return err
+}
+
+// dropStaleTranslations deletes an article's translations when its saved
+// title, summary or reason differs from the one about to be saved, so they
+// are translated again. A nil field keeps its saved value.
+func dropStaleTranslations(tx *sql.Tx, articleID int64, titleCN, summary, reason *string) error {
+	_, err := tx.Exec(`
+		DELETE FROM translations
+		WHERE article_id = ?
+		  AND EXISTS (
+			SELECT 1 FROM article_analysis
+			WHERE article_id = ?
+			  AND (title_cn != COALESCE(?, title_cn)
+			       OR ai_summary != COALESCE(?, ai_summary)
+			       OR recommend_reason != COALESCE(?, recommend_reason))
+		  )
+	`, articleID, articleID, titleCN, summary, reason)
+	return err
+}
+
+// Translations returns the translations into language of the given
+// articles, keyed by article ID. Articles without one are left out.
+func (s *Store) Translations(articleIDs []int64, language string) (map[int64]Translation, error) {
+	result := make(map[int64]Translation)
+	if len(articleIDs) == 0 {
+		return result, nil
+	}
+	placeholders := make([]string, len(articleIDs))
+	args := []any{language}
+	for i, id := range articleIDs {
+		placeholders[i] = "?"
+		args = append(args, id)
+	}
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT article_id, language, title, summary, reason, created_at
+		FROM translations
+		WHERE language = ? AND article_id IN (%s)
+	`, strings.Join(placeholders, ",")), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t Translation
+		if err := rows.Scan(&t.ArticleID, &t.Language, &t.Title, &t.Summary, &t.Reason, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		result[t.ArticleID] = t
+	}
+	return result, rows.Err()
+}
+
+// UntranslatedArticles returns summarized articles within the window that
+// have no translation into language yet, highest scored first.
+func (s *Store) UntranslatedArticles(window, language string) ([]ArticleWithAnalysis, error) {
+	cutoff, err := windowCutoff(window)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT a.id, a.blog_domain, a.title, a.url, a.summary, a.published_at, a.scraped_at,
+		       aa.id, aa.article_id, aa.total_score, aa.category, aa.keywords,
+		       aa.ai_summary, aa.title_cn, aa.recommend_reason, aa.analyzed_at
+		FROM articles a
+		JOIN article_analysis aa ON a.id = aa.article_id
+		WHERE a.published_at >= ?
+		  AND aa.ai_summary != ''
+		  AND aa.hidden_at IS NULL
+		  AND NOT EXISTS (
+			SELECT 1 FROM translations t WHERE t.article_id = a.id AND t.language = ?
+		  )
+		ORDER BY aa.total_score DESC, a.published_at DESC
+	`, cutoff, language)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []ArticleWithAnalysis
+	for rows.Next() {
+		var r ArticleWithAnalysis
+		if err := rows.Scan(
+			&r.Article.ID, &r.Article.BlogDomain, &r.Article.Title, &r.Article.URL,
+			&r.Article.Summary, &r.Article.PublishedAt, &r.Article.ScrapedAt,
+			&r.ArticleAnalysis.ID, &r.ArticleAnalysis.ArticleID, &r.ArticleAnalysis.TotalScore,
+			&r.ArticleAnalysis.Category, &r.ArticleAnalysis.Keywords,
+			&r.ArticleAnalysis.AISummary, &r.ArticleAnalysis.TitleCN, &r.ArticleAnalysis.RecommendReason,
+			&r.ArticleAnalysis.AnalyzedAt,
+		); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,228 @@
+package store
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// TrendReport is a stored trend analysis over a set of articles.
+type TrendReport struct {
+	ID         int64
+	Window     string
+	Model      string
+	CreatedAt  time.Time
+	ArticleIDs []int64 // articles the report was generated from
+	Trends     []Trend
+}
+
+// Trend is one trend in a report. Articles holds the titles named by the
+// model; ArticleIDs those of them that matched an input article.
+type Trend struct {
+	Title       string
+	Description string
+	Articles    []string
+	ArticleIDs  []int64
+}
+
+// SaveTrendReport stores r with its trends and article links, setting its ID
+// and, if zero, its creation time.
+func (s *Store) SaveTrendReport(r *TrendReport) error {
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = time.Now()
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		"INSERT INTO trend_reports (window, model, created_at) VALUES (?, ?, ?)",
+		r.Window, r.Model, r.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return err
+	}
+	reportID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	for _, id := range r.ArticleIDs {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO trend_report_articles (report_id, article_id) VALUES (?, ?)", reportID, id); err != nil {
+			return err
+		}
+	}
+
+	for i, t := range r.Trends {
+		titles, _ := json.Marshal(t.Articles)
+		res, err := tx.Exec(
+			"INSERT INTO trends (report_id, position, title, description, articles) VALUES (?, ?, ?, ?, ?)",
+			reportID, i, t.Title, t.Description, string(titles),
+		)
+		if err != nil {
+			return err
+		}
+		trendID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		for _, id := range t.ArticleIDs {
+			if _, err := tx.Exec("INSERT OR IGNORE INTO trend_articles (trend_id, article_id) VALUES (?, ?)", trendID, id); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	r.ID = reportID
+	return nil
+}
+
+// LatestTrendReport returns the newest report for window, or for any window
+// when it is empty. Returns nil if there is none.
+func (s *Store) LatestTrendReport(window string) (*TrendReport, error) {
+	reports, err := s.TrendReports(window, 0, 1)
+	if err != nil || len(reports) == 0 {
+		return nil, err
+	}
+	return &reports[0], nil
+}
+
+// TrendReports returns reports newest first, optionally for one window only.
+// before, when non-zero, restricts the result to reports with a smaller ID
+// for paging.
+func (s *Store) TrendReports(window string, before int64, limit int) ([]TrendReport, error) {
+	query := "SELECT id, window, model, created_at FROM trend_reports WHERE 1 = 1"
+	var args []any
+	if window != "" {
+		query += " AND window = ?"
+		args = append(args, window)
+	}
+	if before > 0 {
+		query += " AND id < ?"
+		args = append(args, before)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+	return s.queryTrendReports(query, args...)
+}
+
+// TrendReportCovering returns the newest report for window that was generated
+// from at least all of articleIDs and is no older than maxAge, so notifiers
+// can reuse it instead of asking the model again. Returns nil if none fits.
+func (s *Store) TrendReportCovering(window string, articleIDs []int64, maxAge time.Duration) (*TrendReport, error) {
+	if len(articleIDs) == 0 {
+		return nil, nil
+	}
+	placeholders := make([]string, len(articleIDs))
+	args := []any{window, time.Now().Add(-maxAge).UTC().Format(time.RFC3339)}
+	for i, id := range articleIDs {
+		placeholders[i] = "?"
+		args = append(args, id)
+	}
+	args = append(args, len(articleIDs))
+
+	reports, err := s.queryTrendReports(`
+		SELECT r.id, r.window, r.model, r.created_at
+		FROM trend_reports r
+		JOIN trend_report_articles ra ON ra.report_id = r.id
+		WHERE r.window = ? AND r.created_at >= ? AND ra.article_id IN (`+strings.Join(placeholders, ",")+`)
+		GROUP BY r.id
+		HAVING COUNT(*) = ?
+		ORDER BY r.id DESC
+		LIMIT 1
+	`, args...)
+	if err != nil || len(reports) == 0 {
+		return nil, err
+	}
+	return &reports[0], nil
+}
+
+// queryTrendReports runs a query selecting id, window, model, created_at
+// from trend_reports and loads the trends and article links of each row.
+func (s *Store) queryTrendReports(query string, args ...any) ([]TrendReport, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var reports []TrendReport
+	for rows.Next() {
+		var r TrendReport
+		var createdAt string
+		if err := rows.Scan(&r.ID, &r.Window, &r.Model, &createdAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		r.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		reports = append(reports, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range reports {
+		if err := s.loadTrends(&reports[i]); err != nil {
+			return nil, err
+		}
+	}
+	return reports, nil
+}
+
+func (s *Store) loadTrends(r *TrendReport) error {
+	ids, err := s.int64s("SELECT article_id FROM trend_report_articles WHERE report_id = ? ORDER BY article_id", r.ID)
+	if err != nil {
+		return err
+	}
+	r.ArticleIDs = ids
+
+	rows, err := s.db.Query("SELECT id, title, description, articles FROM trends WHERE report_id = ? ORDER BY position", r.ID)
+	if err != nil {
+		return err
+	}
+	var trendIDs []int64
+	for rows.Next() {
+		var id int64
+		var t Trend
+		var titles string
+		if err := rows.Scan(&id, &t.Title, &t.Description, &titles); err != nil {
+			rows.Close()
+			return err
+		}
+		json.Unmarshal([]byte(titles), &t.Articles) //nolint:errcheck
+		trendIDs = append(trendIDs, id)
+		r.Trends = append(r.Trends, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i, id := range trendIDs {
+		if r.Trends[i].ArticleIDs, err = s.int64s("SELECT article_id FROM trend_articles WHERE trend_id = ? ORDER BY article_id", id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// int64s returns the single integer column selected by query.
+func (s *Store) int64s(query string, args ...any) ([]int64, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
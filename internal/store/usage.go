@@ -0,0 +1,118 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// LLMUsage is the token usage reported for one model call.
+type LLMUsage struct {
+	RunID            string // pipeline run, empty outside a run
+	Operation        string
+	Model            string
+	ArticleID        int64 // 0 when the call is not about one article
+	PromptTokens     int
+	CompletionTokens int
+	CreatedAt        time.Time
+}
+
+// UsageTotal sums the calls and tokens of one group of model calls.
+type UsageTotal struct {
+	Operation        string    `json:"operation,omitempty"`
+	Model            string    `json:"model,omitempty"`
+	RunID            string    `json:"run_id,omitempty"`
+	StartedAt        time.Time `json:"started_at,omitzero"`
+	Calls            int       `json:"calls"`
+	PromptTokens     int64     `json:"prompt_tokens"`
+	CompletionTokens int64     `json:"completion_tokens"`
+}
+
+// Tokens returns prompt plus completion tokens.
+func (u UsageTotal) Tokens() int64 {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// SaveLLMUsage records the usage of one model call.
+func (s *Store) SaveLLMUsage(u LLMUsage) error {
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = time.Now()
+	}
+	var articleID any
+	if u.ArticleID != 0 {
+		articleID = u.ArticleID
+	}
+	_, err := s.db.Exec(
+		`INSERT INTO llm_usage (run_id, operation, model, article_id, prompt_tokens, completion_tokens, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		u.RunID, u.Operation, u.Model, articleID, u.PromptTokens, u.CompletionTokens,
+		u.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	return err
+}
+
+// LLMTokensSince returns the prompt plus completion tokens used since t.
+func (s *Store) LLMTokensSince(t time.Time) (int64, error) {
+	var n int64
+	err := s.db.QueryRow(
+		"SELECT COALESCE(SUM(prompt_tokens + completion_tokens), 0) FROM llm_usage WHERE created_at >= ?",
+		t.UTC().Format(time.RFC3339),
+	).Scan(&n)
+	return n, err
+}
+
+// UsageByOperation totals model calls in [from, to) per operation and model,
+// largest first.
+func (s *Store) UsageByOperation(from, to time.Time) ([]UsageTotal, error) {
+	rows, err := s.db.Query(`
+		SELECT operation, model, COUNT(*), SUM(prompt_tokens), SUM(completion_tokens)
+		FROM llm_usage
+		WHERE created_at >= ? AND created_at < ?
+		GROUP BY operation, model
+		ORDER BY SUM(prompt_tokens + completion_tokens) DESC, operation, model
+	`, from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []UsageTotal
+	for rows.Next() {
+		var u UsageTotal
+		if err := rows.Scan(&u.Operation, &u.Model, &u.Calls, &u.PromptTokens, &u.CompletionTokens); err != nil {
+			return nil, err
+		}
+		results = append(results, u)
+	}
+	return results, rows.Err()
+}
+
+// UsageByRun totals model calls in [from, to) per pipeline run, most recent
+// run first.
+func (s *Store) UsageByRun(from, to time.Time, limit int) ([]UsageTotal, error) {
+	rows, err := s.db.Query(`
+		SELECT run_id, MIN(created_at), COUNT(*), SUM(prompt_tokens), SUM(completion_tokens)
+		FROM llm_usage
+		WHERE created_at >= ? AND created_at < ? AND run_id != ''
+		GROUP BY run_id
+		ORDER BY MIN(created_at) DESC
+		LIMIT ?
+	`, from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []UsageTotal
+	for rows.Next() {
+		var u UsageTotal
+		var started sql.NullString
+		if err := rows.Scan(&u.RunID, &started, &u.Calls, &u.PromptTokens, &u.CompletionTokens); err != nil {
+			return nil, err
+		}
+		if t, err := time.Parse(time.RFC3339, started.String); err == nil {
+			u.StartedAt = t
+		}
+		results = append(results, u)
+	}
+	return results, rows.Err()
+}
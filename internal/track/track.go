@@ -0,0 +1,85 @@
+// Package track builds and reads the signed click-tracking links used in
+// digests. A link names the article, the channel it was sent on and a keyed
+// hash of the recipient, so clicks can be counted per reader without storing
+// who the reader is.
+package track
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chyiyaqing/newsbot/internal/config"
+	"github.com/chyiyaqing/newsbot/internal/token"
+)
+
+// linkTTL is how long a digest link keeps counting clicks.
+const linkTTL = 365 * 24 * time.Hour
+
+// Click is what a tracking link records when followed.
+type Click struct {
+	ArticleID int64
+	Channel   string
+	UserRef   string
+}
+
+// Linker returns the click-tracking URL for an article.
+type Linker func(articleID int64) string
+
+// NewLinker returns a Linker for links sent on channel to recipient (an
+// email address or chat ID; may be empty), or nil when siteURL or secret is
+// empty and articles should link directly. Links are signed with a key
+// derived from secret, so other tokens signed with it are not valid links.
+func NewLinker(siteURL string, secret []byte, channel, recipient string) Linker {
+	if siteURL == "" || len(secret) == 0 {
+		return nil
+	}
+	base := strings.TrimRight(siteURL, "/") + "/r/"
+	key := token.Derive(secret, "click")
+	ref := UserRef(secret, recipient)
+	return func(articleID int64) string {
+		payload := strconv.FormatInt(articleID, 10) + "|" + channel + "|" + ref
+		return base + token.Sign(key, payload, time.Now().Add(linkTTL))
+	}
+}
+
+// ConfigLinker is NewLinker with the site URL and confirmation secret from
+// cfg; links need both to be set. A missing secret is logged, since the
+// links would otherwise silently go untracked.
+func ConfigLinker(cfg *config.Config, channel, recipient string) Linker {
+	if cfg.SMTP.SiteURL != "" && cfg.SMTP.ConfirmSecret == "" {
+		slog.Warn("CONFIRM_SECRET not set, digest links are not tracked", "channel", channel)
+	}
+	return NewLinker(cfg.SMTP.SiteURL, []byte(cfg.SMTP.ConfirmSecret), channel, recipient)
+}
+
+// Parse verifies a link token and returns the click it records.
+func Parse(secret []byte, tok string, now time.Time) (Click, error) {
+	payload, err := token.Verify(token.Derive(secret, "click"), tok, now)
+	if err != nil {
+		return Click{}, err
+	}
+	idStr, rest, _ := strings.Cut(payload, "|")
+	channel, ref, _ := strings.Cut(rest, "|")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		return Click{}, fmt.Errorf("%w: bad article id", token.ErrMalformed)
+	}
+	return Click{ArticleID: id, Channel: channel, UserRef: ref}, nil
+}
+
+// UserRef returns a keyed hash identifying recipient, or "" if recipient is
+// empty.
+func UserRef(secret []byte, recipient string) string {
+	if recipient == "" {
+		return ""
+	}
+	h := hmac.New(sha256.New, token.Derive(secret, "recipient"))
+	h.Write([]byte(strings.ToLower(recipient)))
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
@@ -1,29 +1,58 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/chyiyaqing/newsbot/internal/ai"
+	"github.com/chyiyaqing/newsbot/internal/bounce"
 	"github.com/chyiyaqing/newsbot/internal/config"
+	"github.com/chyiyaqing/newsbot/internal/eval"
+	"github.com/chyiyaqing/newsbot/internal/events"
 	"github.com/chyiyaqing/newsbot/internal/hnpopular"
+	"github.com/chyiyaqing/newsbot/internal/interest"
+	"github.com/chyiyaqing/newsbot/internal/logging"
 	"github.com/chyiyaqing/newsbot/internal/notify/email"
 	"github.com/chyiyaqing/newsbot/internal/notify/telegram"
+	"github.com/chyiyaqing/newsbot/internal/ranker"
+	"github.com/chyiyaqing/newsbot/internal/render"
 	"github.com/chyiyaqing/newsbot/internal/scheduler"
 	"github.com/chyiyaqing/newsbot/internal/scraper"
 	"github.com/chyiyaqing/newsbot/internal/server"
+	"github.com/chyiyaqing/newsbot/internal/sources"
 	"github.com/chyiyaqing/newsbot/internal/store"
+	"github.com/chyiyaqing/newsbot/internal/token"
+	"github.com/chyiyaqing/newsbot/internal/track"
 )
 
 const dbPath = "data/newsbot.db"
 
+// noCache is set by --no-cache: model responses are not read from the cache.
+var noCache bool
+
 func main() {
+	args := os.Args[:1]
+	for _, arg := range os.Args[1:] {
+		if arg == "--no-cache" {
+			noCache = true
+		} else {
+			args = append(args, arg)
+		}
+	}
+	os.Args = args
+
 	if len(os.Args) < 2 {
 		usage()
 		os.Exit(1)
@@ -31,12 +60,15 @@ func main() {
 
 	cfg, err := config.Load("newsbot.yaml")
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		fatal("failed to load config", "err", err)
+	}
+	if err := logging.Setup(cfg.Log); err != nil {
+		fatal("invalid log config", "err", err)
 	}
 
 	db, err := store.New(dbPath)
 	if err != nil {
-		log.Fatalf("Failed to open database: %v", err)
+		fatal("failed to open database", "err", err)
 	}
 	defer db.Close()
 
@@ -44,7 +76,7 @@ func main() {
 	case "fetch-blogs":
 		cmdFetchBlogs(db)
 	case "scrape":
-		cmdScrape(db)
+		cmdScrape(db, cfg)
 	case "analyze":
 		window := "24h"
 		if len(os.Args) > 2 {
@@ -52,11 +84,15 @@ func main() {
 		}
 		cmdAnalyze(db, cfg, window)
 	case "report":
-		window := "24h"
-		if len(os.Args) > 2 {
-			window = os.Args[2]
+		window, markdown := "24h", false
+		for _, arg := range os.Args[2:] {
+			if arg == "--markdown" {
+				markdown = true
+			} else {
+				window = arg
+			}
 		}
-		cmdReport(db, cfg, window)
+		cmdReport(db, cfg, window, markdown)
 	case "notify":
 		window := "24h"
 		if len(os.Args) > 2 {
@@ -65,12 +101,49 @@ func main() {
 		cmdNotify(db, cfg, window)
 	case "run":
 		cmdRun(db, cfg)
+	case "admin-token":
+		name, ttl := "admin", "720h"
+		if len(os.Args) > 2 {
+			name = os.Args[2]
+		}
+		if len(os.Args) > 3 {
+			ttl = os.Args[3]
+		}
+		cmdAdminToken(cfg, name, ttl)
+	case "usage":
+		days := 7
+		if len(os.Args) > 2 {
+			n, err := strconv.Atoi(os.Args[2])
+			if err != nil || n <= 0 {
+				fatal("invalid number of days", "days", os.Args[2])
+			}
+			days = n
+		}
+		cmdUsage(db, cfg, days)
+	case "rescore":
+		cmdRescore(db, cfg)
+	case "reanalyze":
+		cmdReanalyze(db, cfg, os.Args[2:])
+	case "blog-stats":
+		cmdBlogStats(db, cfg, slices.Contains(os.Args[2:], "--apply"))
+	case "train-ranker":
+		cmdTrainRanker(db, cfg, slices.Contains(os.Args[2:], "--dry-run"))
+	case "eval":
+		cmdEval(db, cfg, os.Args[2:])
+	case "cache":
+		cmdCache(db, os.Args[2:])
 	default:
 		usage()
 		os.Exit(1)
 	}
 }
 
+// fatal logs msg at error level and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
 func usage() {
 	fmt.Fprintf(os.Stderr, `Usage: newsbot <command>
 
@@ -78,46 +151,85 @@ Commands:
   fetch-blogs          Fetch top blogs from HN Popularity and store them
   scrape               Scrape latest articles from all stored blogs
   analyze [24h|3days|7days]  Score and summarize articles with AI
-  report  [24h|3days|7days]  Generate trend report from analyzed articles
+  report  [24h|3days|7days] [--markdown]
+                             Generate trend report from analyzed articles
   notify  [24h|3days|7days]  Send report via Telegram
   run     [cron-expr]        Start scheduler (cron mode)
+  admin-token [name] [ttl]   Print a signed admin API token (default ttl 720h)
+  rescore                    Re-apply the interest profile to all stored scores
+  reanalyze [--window 24h|3days|7days] [--category c] [--min-score n] [--max-score n]
+            [--outdated] [--limit n] [--dry-run] [--resume]
+                             Score and summarize analyzed articles again, e.g. after
+                             changing the model or prompts; --outdated selects those
+                             made with another model or prompt version
+  train-ranker [--dry-run]   Train the digest ranking model on reader feedback
+  blog-stats [--apply]       Show per-blog score and feed stats; --apply demotes or
+                             mutes failing blogs per sources.action
+  eval <fixtures.jsonl> [--models a,b] [--prompts dir1,dir2] [--format markdown|html] [--out file]
+                             Compare models / prompt versions on labeled articles
+  cache prune [--all]        Delete expired (or all) cached LLM responses
+  usage   [days]             Show LLM token usage and cost (default 7 days)
+
+Options:
+  --no-cache                 analyze/report/notify: ask the model again instead of
+                             reusing cached responses
 `)
 }
 
+// newAIClient returns a model client using the configured prompts and the
+// response cache.
+func newAIClient(db *store.Store, cfg *config.Config) *ai.Client {
+	prompts, err := ai.LoadPrompts(cfg.Prompts, interest.New(cfg.Interests))
+	if err != nil {
+		fatal("failed to load prompts", "err", err)
+	}
+	client := ai.NewClient(cfg.Ollama.Address, cfg.Ollama.Model, cfg.Ollama.Username, cfg.Ollama.Password).
+		WithPrompts(prompts).
+		WithCache(db, cfg.Ollama.CacheWindow()).
+		WithUsage(db, cfg.Usage.DailyTokenBudget)
+	if noCache {
+		client.Refresh()
+	}
+	return client
+}
+
 func cmdFetchBlogs(db *store.Store) {
 	blogs, err := hnpopular.FetchTopBlogs(100)
 	if err != nil {
-		log.Fatalf("Failed to fetch blogs: %v", err)
+		fatal("failed to fetch blogs", "err", err)
 	}
 
 	if err := db.SaveBlogs(blogs); err != nil {
-		log.Fatalf("Failed to save blogs: %v", err)
+		fatal("failed to save blogs", "err", err)
 	}
 
-	log.Printf("Saved %d blogs", len(blogs))
+	slog.Info("saved blogs", "count", len(blogs))
 	for _, b := range blogs {
 		fmt.Printf("#%d %s (score: %d, author: %s)\n", b.Rank, b.Domain, b.Score, b.Author)
 	}
 }
 
-func cmdScrape(db *store.Store) {
+func cmdScrape(db *store.Store, cfg *config.Config) {
 	blogs, err := db.ListBlogs()
 	if err != nil {
-		log.Fatalf("Failed to list blogs: %v", err)
+		fatal("failed to list blogs", "err", err)
+	}
+	if blogs, err = sources.Due(db, blogs, cfg.Sources.ScrapeInterval(), time.Now()); err != nil {
+		fatal("failed to select blogs", "err", err)
 	}
 
 	if len(blogs) == 0 {
-		log.Fatal("No blogs in database. Run 'newsbot fetch-blogs' first.")
+		fatal("no blogs in database, run 'newsbot fetch-blogs' first")
 	}
 
-	ctx := context.Background()
+	ctx := logging.WithRunID(context.Background(), logging.NewRunID())
 	if err := scraper.ScrapeBlogs(ctx, blogs, db); err != nil {
-		log.Fatalf("Scrape failed: %v", err)
+		fatal("scrape failed", "err", err)
 	}
 
 	articles, err := db.LatestArticles(20)
 	if err != nil {
-		log.Fatalf("Failed to list articles: %v", err)
+		fatal("failed to list articles", "err", err)
 	}
 
 	fmt.Printf("\nLatest %d articles:\n", len(articles))
@@ -129,196 +241,190 @@ func cmdScrape(db *store.Store) {
 func cmdAnalyze(db *store.Store, cfg *config.Config, window string) {
 	articles, err := db.UnanalyzedArticles(window)
 	if err != nil {
-		log.Fatalf("Failed to get articles: %v", err)
+		fatal("failed to get articles", "err", err)
 	}
 
+	client := newAIClient(db, cfg)
+	profile := interest.New(cfg.Interests)
+	ctx := logging.WithRunID(context.Background(), logging.NewRunID())
+	defer translateArticles(ctx, db, client, cfg, window)
+
 	if len(articles) == 0 {
-		log.Printf("No unanalyzed articles found in %s window.", window)
+		slog.Info("no unanalyzed articles", "window", window)
 		return
 	}
 
-	client := ai.NewClient(cfg.Ollama.Address, cfg.Ollama.Model, cfg.Ollama.Username, cfg.Ollama.Password)
-	ctx := context.Background()
-
-	log.Printf("Analyzing %d articles from %s window...", len(articles), window)
+	slog.InfoContext(ctx, "analyzing articles", "count", len(articles), "window", window)
 
 	scored := 0
 	summarized := 0
 	for i, article := range articles {
-		log.Printf("[%d/%d] Scoring: %s", i+1, len(articles), article.Title)
+		slog.InfoContext(ctx, "scoring article", "n", i+1, "of", len(articles), "article_id", article.ID, "title", article.Title)
 
-		scoreResult, err := client.ScoreArticle(ctx, article)
+		analysis, err := scheduler.AnalyzeArticle(ctx, client, profile, article)
+		if errors.Is(err, ai.ErrBudgetExceeded) {
+			slog.WarnContext(ctx, "pausing analysis", "err", err)
+			return
+		}
 		if err != nil {
-			log.Printf("  WARNING: skip scoring: %v", err)
+			slog.WarnContext(ctx, "skip scoring", "article_id", article.ID, "err", err)
 			continue
 		}
-
-		totalScore := scoreResult.Relevance + scoreResult.Quality + scoreResult.Timeliness
-		analysis := store.ArticleAnalysis{
-			ArticleID:  article.ID,
-			Relevance:  scoreResult.Relevance,
-			Quality:    scoreResult.Quality,
-			Timeliness: scoreResult.Timeliness,
-			TotalScore: totalScore,
-			Category:   scoreResult.Category,
-			Keywords:   strings.Join(scoreResult.Keywords, ", "),
-			AnalyzedAt: time.Now(),
-		}
-
-		log.Printf("  Generating summary...")
-		summaryResult, err := client.SummarizeArticle(ctx, article)
-		if err != nil {
-			log.Printf("  WARNING: skip summary: %v", err)
-		} else {
-			analysis.AISummary = summaryResult.Summary
-			analysis.TitleCN = summaryResult.TitleCN
-			analysis.RecommendReason = summaryResult.RecommendReason
+		if analysis.AISummary != "" {
 			summarized++
 		}
 
 		if err := db.SaveArticleAnalysis(analysis); err != nil {
-			log.Printf("  WARNING: save analysis: %v", err)
+			slog.WarnContext(ctx, "save analysis", "article_id", article.ID, "err", err)
 			continue
 		}
 		scored++
 
-		fmt.Printf("  [%d] %s (%s) — %s\n",
-			totalScore, article.Title, scoreResult.Category, strings.Join(scoreResult.Keywords, ", "))
+		fmt.Printf("  [%d] %s (%s) — %s\n", analysis.TotalScore, article.Title, analysis.Category, analysis.Keywords)
 	}
 
-	log.Printf("Done: %d scored, %d summarized", scored, summarized)
+	slog.InfoContext(ctx, "analysis done", "scored", scored, "summarized", summarized)
 
 	// Retry summaries for high-score articles that failed previously
 	unsummarized, err := db.UnsummarizedHighScoreArticles(window, 0)
 	if err != nil {
-		log.Printf("WARNING: get unsummarized articles: %v", err)
+		slog.WarnContext(ctx, "get unsummarized articles", "err", err)
 		return
 	}
 	if len(unsummarized) > 0 {
-		log.Printf("Retrying summaries for %d high-score articles...", len(unsummarized))
+		slog.InfoContext(ctx, "retrying summaries for high-score articles", "count", len(unsummarized))
 		for _, item := range unsummarized {
-			log.Printf("  Summarizing: %s (score %d)", item.Article.Title, item.ArticleAnalysis.TotalScore)
+			slog.InfoContext(ctx, "summarizing article", "article_id", item.Article.ID, "title", item.Article.Title, "score", item.ArticleAnalysis.TotalScore)
 			summaryResult, err := client.SummarizeArticle(ctx, item.Article)
+			if errors.Is(err, ai.ErrBudgetExceeded) {
+				slog.WarnContext(ctx, "pausing analysis", "err", err)
+				return
+			}
 			if err != nil {
-				log.Printf("  WARNING: retry summarize: %v", err)
+				slog.WarnContext(ctx, "retry summarize", "article_id", item.Article.ID, "err", err)
 				continue
 			}
 			item.ArticleAnalysis.AISummary = summaryResult.Summary
 			item.ArticleAnalysis.TitleCN = summaryResult.TitleCN
 			item.ArticleAnalysis.RecommendReason = summaryResult.RecommendReason
 			if err := db.SaveArticleAnalysis(item.ArticleAnalysis); err != nil {
-				log.Printf("  WARNING: save: %v", err)
+				slog.WarnContext(ctx, "save analysis", "article_id", item.Article.ID, "err", err)
 			} else {
 				summarized++
 			}
 		}
-		log.Printf("Retry done, total summarized: %d", summarized)
+		slog.InfoContext(ctx, "summary retry done", "summarized", summarized)
+	}
+}
+
+// translateArticles translates the window's summaries into the configured
+// languages.
+func translateArticles(ctx context.Context, db *store.Store, client *ai.Client, cfg *config.Config, window string) {
+	if len(cfg.Languages) == 0 {
+		return
+	}
+	translated, err := scheduler.TranslateArticles(ctx, db, client, cfg.Languages, window)
+	if err != nil {
+		slog.WarnContext(ctx, "pausing translation", "err", err)
+	}
+	if translated > 0 {
+		slog.InfoContext(ctx, "translation done", "translated", translated)
 	}
 }
 
-func cmdReport(db *store.Store, cfg *config.Config, window string) {
+func cmdReport(db *store.Store, cfg *config.Config, window string, markdown bool) {
 	analyses, err := db.AnalysesByTimeWindow(window)
 	if err != nil {
-		log.Fatalf("Failed to get analyses: %v", err)
+		fatal("failed to get analyses", "err", err)
 	}
 
 	if len(analyses) == 0 {
-		log.Fatalf("No analyzed articles in %s window. Run 'newsbot analyze %s' first.", window, window)
+		fatal("no analyzed articles, run 'newsbot analyze' first", "window", window)
 	}
 
-	// Print top articles
-	fmt.Printf("\n=== Top Articles (%s) ===\n\n", window)
-	limit := 20
-	if len(analyses) < limit {
-		limit = len(analyses)
-	}
-	for i := 0; i < limit; i++ {
-		a := analyses[i]
-		fmt.Printf("%d. [Score: %d | %s] %s\n", i+1, a.ArticleAnalysis.TotalScore, a.ArticleAnalysis.Category, a.Article.Title)
-		if a.ArticleAnalysis.TitleCN != "" {
-			fmt.Printf("   中文: %s\n", a.ArticleAnalysis.TitleCN)
-		}
-		if a.ArticleAnalysis.RecommendReason != "" {
-			fmt.Printf("   推荐: %s\n", a.ArticleAnalysis.RecommendReason)
-		}
-		if a.ArticleAnalysis.AISummary != "" {
-			fmt.Printf("   摘要: %s\n", a.ArticleAnalysis.AISummary)
-		}
-		fmt.Printf("   链接: %s\n\n", a.Article.URL)
-	}
+	renderer := loadRenderer(cfg)
 
 	// Generate trend report
-	client := ai.NewClient(cfg.Ollama.Address, cfg.Ollama.Model, cfg.Ollama.Username, cfg.Ollama.Password)
-	ctx := context.Background()
+	client := newAIClient(db, cfg)
+	ctx := logging.WithRunID(context.Background(), logging.NewRunID())
 
-	log.Println("Generating trend report...")
-	report, err := client.AnalyzeTrends(ctx, analyses)
+	slog.InfoContext(ctx, "generating trend report")
+	report, err := client.TrendsFor(ctx, db, window, analyses)
 	if err != nil {
-		log.Fatalf("Failed to analyze trends: %v", err)
+		fatal("failed to analyze trends", "err", err)
 	}
 
-	fmt.Printf("\n=== 技术趋势总结 (%s) ===\n\n", window)
-	for i, t := range report.Trends {
-		fmt.Printf("%d. %s\n", i+1, t.Title)
-		fmt.Printf("   %s\n", t.Description)
-		if len(t.Articles) > 0 {
-			fmt.Printf("   相关文章: %s\n", strings.Join(t.Articles, "; "))
-		}
-		fmt.Println()
+	// Print top articles and trends
+	d := render.NewDigest(analyses, report, window)
+	var out string
+	if markdown {
+		out, err = renderer.Markdown(d)
+	} else {
+		out, err = renderer.Text(d)
+	}
+	if err != nil {
+		fatal("failed to render report", "err", err)
 	}
+	fmt.Print(out)
 
 	// Auto-send to Telegram if configured (only unnotified articles)
-	if tg := telegram.New(cfg.Telegram.BotToken, cfg.Telegram.ChatID); tg != nil {
-		newArticles, err := db.UnnotifiedAnalyses(window)
+	if tg := telegram.New(cfg.Telegram.BotToken, cfg.Telegram.ChatID, cfg.Telegram.Updates != ""); tg != nil {
+		model := loadRanker(db, cfg)
+		newArticles, err := db.UnnotifiedAnalyses(window, model.Candidates(scheduler.DigestSize))
 		if err != nil {
-			log.Printf("WARNING: get unnotified analyses: %v", err)
+			slog.WarnContext(ctx, "get unnotified analyses", "err", err)
 		} else if len(newArticles) == 0 {
-			log.Println("No new articles to send to Telegram")
+			slog.InfoContext(ctx, "no new articles to send", "channel", "telegram")
 		} else {
-			msg := telegram.FormatReport(newArticles, report, window)
-			if err := tg.Send(ctx, "", msg); err != nil {
-				log.Printf("WARNING: telegram send: %v", err)
+			newArticles = model.Top(newArticles, scheduler.DigestSize)
+			d := scheduler.LocalizeDigest(ctx, db, render.NewDigest(newArticles, report, window).
+				WithLinks(track.ConfigLinker(cfg, "telegram", cfg.Telegram.ChatID)), cfg.Telegram.Language)
+			if err := tg.SendReport(ctx, renderer, d); err != nil {
+				slog.WarnContext(ctx, "send report", "channel", "telegram", "err", err)
 			} else {
 				ids := make([]int64, len(newArticles))
 				for i, a := range newArticles {
 					ids[i] = a.Article.ID
 				}
 				if err := db.MarkNotified(ids); err != nil {
-					log.Printf("WARNING: mark notified: %v", err)
+					slog.WarnContext(ctx, "mark notified", "err", err)
 				}
-				log.Printf("Report sent to Telegram (%d new articles)", len(newArticles))
+				slog.InfoContext(ctx, "report sent", "channel", "telegram", "count", len(newArticles))
 			}
 		}
 	}
 }
 
 func cmdNotify(db *store.Store, cfg *config.Config, window string) {
-	tg := telegram.New(cfg.Telegram.BotToken, cfg.Telegram.ChatID)
+	tg := telegram.New(cfg.Telegram.BotToken, cfg.Telegram.ChatID, cfg.Telegram.Updates != "")
 	if tg == nil {
-		log.Fatal("Telegram not configured. Set TG_BOT_TOKEN and TG_CHAT_ID in .env")
+		fatal("telegram not configured, set TG_BOT_TOKEN and TG_CHAT_ID in .env")
 	}
 
-	newArticles, err := db.UnnotifiedAnalyses(window)
+	model := loadRanker(db, cfg)
+	newArticles, err := db.UnnotifiedAnalyses(window, model.Candidates(scheduler.DigestSize))
 	if err != nil {
-		log.Fatalf("Failed to get unnotified analyses: %v", err)
+		fatal("failed to get unnotified analyses", "err", err)
 	}
 	if len(newArticles) == 0 {
-		log.Printf("No new articles to notify in %s window.", window)
+		slog.Info("no new articles to notify", "window", window)
 		return
 	}
+	newArticles = model.Top(newArticles, scheduler.DigestSize)
 
-	client := ai.NewClient(cfg.Ollama.Address, cfg.Ollama.Model, cfg.Ollama.Username, cfg.Ollama.Password)
-	ctx := context.Background()
+	client := newAIClient(db, cfg)
+	ctx := logging.WithRunID(context.Background(), logging.NewRunID())
 
-	log.Printf("Generating trend report for %d new articles...", len(newArticles))
-	report, err := client.AnalyzeTrends(ctx, newArticles)
+	slog.InfoContext(ctx, "generating trend report", "count", len(newArticles))
+	report, err := client.TrendsFor(ctx, db, window, newArticles)
 	if err != nil {
-		log.Fatalf("Failed to analyze trends: %v", err)
+		fatal("failed to analyze trends", "err", err)
 	}
 
-	msg := telegram.FormatReport(newArticles, report, window)
-	if err := tg.Send(ctx, "", msg); err != nil {
-		log.Fatalf("Failed to send Telegram notification: %v", err)
+	d := scheduler.LocalizeDigest(ctx, db, render.NewDigest(newArticles, report, window).
+		WithLinks(track.ConfigLinker(cfg, "telegram", cfg.Telegram.ChatID)), cfg.Telegram.Language)
+	if err := tg.SendReport(ctx, loadRenderer(cfg), d); err != nil {
+		fatal("failed to send notification", "channel", "telegram", "err", err)
 	}
 
 	ids := make([]int64, len(newArticles))
@@ -326,9 +432,31 @@ func cmdNotify(db *store.Store, cfg *config.Config, window string) {
 		ids[i] = a.Article.ID
 	}
 	if err := db.MarkNotified(ids); err != nil {
-		log.Printf("WARNING: mark notified: %v", err)
+		slog.WarnContext(ctx, "mark notified", "err", err)
 	}
-	log.Printf("Notified %d new articles via Telegram", len(newArticles))
+	slog.InfoContext(ctx, "notified new articles", "channel", "telegram", "count", len(newArticles))
+}
+
+// loadRenderer parses the digest templates, honouring render.templates_dir.
+func loadRenderer(cfg *config.Config) *render.Renderer {
+	r, err := render.New(cfg.Render.TemplatesDir)
+	if err != nil {
+		fatal("failed to load digest templates", "err", err)
+	}
+	return r
+}
+
+// loadRanker returns the learned ranking model if ranking is enabled, or nil
+// to keep the total score order.
+func loadRanker(db *store.Store, cfg *config.Config) *ranker.Model {
+	if !cfg.Ranker.Enabled {
+		return nil
+	}
+	model, err := ranker.Load(db)
+	if err != nil {
+		fatal("failed to load ranker model", "err", err)
+	}
+	return model
 }
 
 func cmdRun(db *store.Store, cfg *config.Config) {
@@ -347,22 +475,476 @@ func cmdRun(db *store.Store, cfg *config.Config) {
 		}
 	}
 
+	// The server verifies the links the scheduler signs, so both need the
+	// same secret even when none is configured
+	if cfg.SMTP.ConfirmSecret == "" {
+		slog.Warn("CONFIRM_SECRET not set, confirmation and tracking links will not survive a restart")
+		cfg.SMTP.ConfirmSecret = string(token.RandomSecret())
+	}
+
 	// Build email client (nil if not configured)
 	var emailCl server.EmailClient
 	if ec := email.New(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.From, cfg.SMTP.SiteURL); ec != nil {
 		emailCl = ec
 	}
 
+	// Receive Telegram feedback button presses (polling or webhook)
+	var tgCl server.TelegramClient
+	if tg := telegram.New(cfg.Telegram.BotToken, cfg.Telegram.ChatID, true); tg != nil {
+		tg.WithAdmins(cfg.Telegram.AdminIDs)
+		switch cfg.Telegram.Updates {
+		case "polling":
+			go tg.Poll(ctx, db)
+			slog.Info("telegram feedback: polling for updates")
+		case "webhook":
+			if cfg.SMTP.SiteURL == "" || cfg.Telegram.WebhookSecret == "" {
+				slog.Warn("telegram webhook requires SITE_URL and TG_WEBHOOK_SECRET, feedback disabled")
+				break
+			}
+			hookURL := strings.TrimRight(cfg.SMTP.SiteURL, "/") + "/api/telegram/webhook"
+			if err := tg.SetWebhook(ctx, hookURL, cfg.Telegram.WebhookSecret); err != nil {
+				slog.Warn("telegram set webhook", "err", err)
+				break
+			}
+			tgCl = tg
+			slog.Info("telegram feedback: webhook registered", "url", hookURL)
+		}
+	}
+
+	// Unsubscribe addresses named in bounce and complaint reports
+	if cfg.Bounces.Source != "" {
+		src, err := bounce.NewSource(cfg.Bounces)
+		if err != nil {
+			fatal("bounce processing", "err", err)
+		}
+		go bounce.Poll(ctx, db, src, cfg.Bounces.Interval())
+		slog.Info("bounce processing: polling", "source", cfg.Bounces.Source, "interval", cfg.Bounces.Interval())
+	}
+
+	// Fail fast on broken prompt templates; the runner reloads them every run
+	if _, err := ai.LoadPrompts(cfg.Prompts, interest.New(cfg.Interests)); err != nil {
+		fatal("failed to load prompts", "err", err)
+	}
+
 	// Start HTTP server in background
-	srv := server.New(db, httpAddr, emailCl)
+	bus := events.NewBus()
+	runner := scheduler.NewRunner(ctx, db, cfg, bus)
+	srv := server.New(db, cfg, httpAddr, emailCl, tgCl, runner, bus)
 	go func() {
 		if err := srv.Start(ctx); err != nil {
-			log.Fatalf("HTTP server error: %v", err)
+			fatal("HTTP server error", "err", err)
 		}
 	}()
 
 	// Start cron scheduler (blocks until ctx is cancelled)
-	if err := scheduler.Run(ctx, db, cfg, schedule); err != nil {
-		log.Fatalf("Scheduler error: %v", err)
+	if err := runner.Run(schedule); err != nil {
+		fatal("scheduler error", "err", err)
+	}
+}
+
+func cmdAdminToken(cfg *config.Config, name, ttl string) {
+	if cfg.Admin.TokenSecret == "" {
+		fatal("ADMIN_TOKEN_SECRET is not set")
+	}
+	d, err := time.ParseDuration(ttl)
+	if err != nil || d <= 0 {
+		fatal("invalid ttl: use a Go duration such as 24h", "ttl", ttl)
+	}
+	exp := time.Now().Add(d)
+	fmt.Println(server.AdminToken(cfg.Admin.TokenSecret, name, exp))
+	slog.Info("admin token issued", "name", name, "expires_at", exp.UTC().Format(time.RFC3339))
+}
+
+func cmdCache(db *store.Store, args []string) {
+	if len(args) == 0 || args[0] != "prune" {
+		fatal("usage: newsbot cache prune [--all]")
+	}
+	all := len(args) > 1 && args[1] == "--all"
+	n, err := db.PruneLLMCache(all)
+	if err != nil {
+		fatal("failed to prune LLM cache", "err", err)
+	}
+	slog.Info("pruned LLM cache", "removed", n, "all", all)
+}
+
+func cmdUsage(db *store.Store, cfg *config.Config, days int) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from, to := today.AddDate(0, 0, -(days-1)), today.AddDate(0, 0, 1)
+
+	ops, err := db.UsageByOperation(from, to)
+	if err != nil {
+		fatal("failed to load usage", "err", err)
+	}
+	runs, err := db.UsageByRun(from, to, 10)
+	if err != nil {
+		fatal("failed to load usage", "err", err)
+	}
+	usedToday, err := db.LLMTokensSince(today)
+	if err != nil {
+		fatal("failed to load usage", "err", err)
+	}
+
+	fmt.Printf("LLM usage %s – %s (UTC)\n\n", from.Format("2006-01-02"), today.Format("2006-01-02"))
+	fmt.Printf("%-10s %-20s %6s %12s %12s %10s\n", "OPERATION", "MODEL", "CALLS", "PROMPT", "COMPLETION", "COST")
+	var total store.UsageTotal
+	for _, u := range ops {
+		fmt.Printf("%-10s %-20s %6d %12d %12d %10.4f\n", u.Operation, u.Model, u.Calls,
+			u.PromptTokens, u.CompletionTokens, cfg.Usage.Cost(u.PromptTokens, u.CompletionTokens))
+		total.Calls += u.Calls
+		total.PromptTokens += u.PromptTokens
+		total.CompletionTokens += u.CompletionTokens
+	}
+	fmt.Printf("%-10s %-20s %6d %12d %12d %10.4f\n", "total", "", total.Calls,
+		total.PromptTokens, total.CompletionTokens, cfg.Usage.Cost(total.PromptTokens, total.CompletionTokens))
+
+	if len(runs) > 0 {
+		fmt.Printf("\nRecent runs:\n")
+		for _, u := range runs {
+			fmt.Printf("  %s  %s  %4d calls  %10d tokens  %10.4f\n", u.RunID, u.StartedAt.Local().Format("2006-01-02 15:04"),
+				u.Calls, u.Tokens(), cfg.Usage.Cost(u.PromptTokens, u.CompletionTokens))
+		}
+	}
+
+	if budget := cfg.Usage.DailyTokenBudget; budget > 0 {
+		fmt.Printf("\nToday: %d of %d tokens (daily budget)\n", usedToday, budget)
+	} else {
+		fmt.Printf("\nToday: %d tokens (no daily budget)\n", usedToday)
+	}
+}
+
+func cmdRescore(db *store.Store, cfg *config.Config) {
+	n, err := db.RescoreAnalyses(interest.New(cfg.Interests).Adjustment)
+	if err != nil {
+		fatal("failed to rescore analyses", "err", err)
+	}
+	slog.Info("rescored analyses", "changed", n)
+}
+
+func cmdReanalyze(db *store.Store, cfg *config.Config, args []string) {
+	var filter store.ReanalysisFilter
+	var dryRun, resume bool
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--dry-run":
+			dryRun = true
+			continue
+		case "--resume":
+			resume = true
+			continue
+		case "--outdated":
+			filter.Outdated = true
+			continue
+		}
+		if i+1 >= len(args) {
+			fatal("missing value for reanalyze option", "option", arg)
+		}
+		i++
+		switch arg {
+		case "--window":
+			filter.Window = args[i]
+		case "--category":
+			filter.Category = args[i]
+		case "--min-score", "--max-score", "--limit":
+			n, err := strconv.Atoi(args[i])
+			if err != nil || n < 0 {
+				fatal("invalid number for reanalyze option", "option", arg, "value", args[i])
+			}
+			switch arg {
+			case "--min-score":
+				filter.MinScore = &n
+			case "--max-score":
+				filter.MaxScore = &n
+			default:
+				filter.Limit = n
+			}
+		default:
+			fatal("unknown reanalyze option", "option", arg)
+		}
+	}
+
+	client := newAIClient(db, cfg)
+	// Re-analysis should not get the cached answers back.
+	client.Refresh()
+	if filter.Outdated {
+		filter.Model, filter.PromptVersion = client.Model(), client.PromptVersion()
+	}
+
+	var run *store.ReanalysisRun
+	var articles []store.ArticleWithAnalysis
+	var err error
+	if resume {
+		run, err = db.LatestReanalysisRun()
+		if err != nil {
+			fatal("failed to load reanalysis run", "err", err)
+		}
+		if run == nil || run.FinishedAt != nil {
+			fatal("no interrupted reanalysis to resume")
+		}
+		// A run interrupted after its last article has nothing left; a limit
+		// of 0 would select every remaining match instead.
+		if run.Remaining() > 0 {
+			articles, err = db.ReanalysisArticles(run.Filter, run.LastArticleID, run.Remaining())
+		}
+	} else {
+		articles, err = db.ReanalysisArticles(filter, 0, filter.Limit)
+	}
+	if err != nil {
+		fatal("failed to select articles", "err", err)
+	}
+
+	if dryRun {
+		for _, a := range articles {
+			fmt.Printf("  #%-6d [%d] %s (%s, %s)\n", a.Article.ID, a.ArticleAnalysis.TotalScore, a.Article.Title,
+				cmp.Or(a.ArticleAnalysis.Model, "unknown model"), cmp.Or(a.ArticleAnalysis.PromptVersion, "unknown prompts"))
+		}
+		fmt.Printf("%d articles would be re-analyzed with %s (prompts %s)\n", len(articles), client.Model(), client.PromptVersion())
+		return
+	}
+	if !resume {
+		if run, err = db.StartReanalysisRun(filter, len(articles)); err != nil {
+			fatal("failed to start reanalysis run", "err", err)
+		}
+	}
+	if len(articles) == 0 {
+		slog.Info("no articles to re-analyze")
+		if err := db.FinishReanalysisRun(run); err != nil {
+			fatal("failed to finish reanalysis run", "err", err)
+		}
+		return
+	}
+
+	profile := interest.New(cfg.Interests)
+	ctx := logging.WithRunID(context.Background(), logging.NewRunID())
+	slog.InfoContext(ctx, "re-analyzing articles", "run", run.ID, "count", len(articles), "of", run.Total,
+		"model", client.Model(), "prompt_version", client.PromptVersion())
+	start := time.Now()
+	for i, item := range articles {
+		n := run.Done + run.Failed + 1
+		analysis, err := scheduler.AnalyzeArticle(ctx, client, profile, item.Article)
+		if errors.Is(err, ai.ErrBudgetExceeded) {
+			slog.WarnContext(ctx, "pausing re-analysis, continue with 'newsbot reanalyze --resume'", "err", err)
+			return
+		}
+		switch {
+		case err != nil:
+			slog.WarnContext(ctx, "skip re-analysis", "article_id", item.Article.ID, "err", err)
+			run.Failed++
+		case analysis.AISummary == "" && item.ArticleAnalysis.AISummary != "":
+			// Keep the old analysis rather than lose its summary.
+			slog.WarnContext(ctx, "skip re-analysis without summary", "article_id", item.Article.ID)
+			run.Failed++
+		default:
+			if err := db.SaveArticleAnalysis(analysis); err != nil {
+				fatal("failed to save analysis", "article_id", item.Article.ID, "err", err)
+			}
+			run.Done++
+			fmt.Printf("  [%d/%d] %d → %d  %s\n", n, run.Total, item.ArticleAnalysis.TotalScore, analysis.TotalScore, item.Article.Title)
+		}
+		run.LastArticleID = item.Article.ID
+		if err := db.SaveReanalysisProgress(run); err != nil {
+			fatal("failed to save reanalysis progress", "err", err)
+		}
+		if left := len(articles) - i - 1; left > 0 && (i+1)%10 == 0 {
+			eta := time.Since(start) / time.Duration(i+1) * time.Duration(left)
+			slog.InfoContext(ctx, "re-analysis progress", "done", n, "of", run.Total, "failed", run.Failed, "eta", eta.Round(time.Second))
+		}
+	}
+
+	if err := db.FinishReanalysisRun(run); err != nil {
+		fatal("failed to finish reanalysis run", "err", err)
+	}
+	slog.InfoContext(ctx, "re-analysis done", "run", run.ID, "updated", run.Done, "failed", run.Failed)
+}
+
+func cmdBlogStats(db *store.Store, cfg *config.Config, apply bool) {
+	now := time.Now()
+	since := cfg.Sources.Window(now)
+	stats, err := db.AllBlogStats(since, cfg.Sources.HitScore)
+	if err != nil {
+		fatal("failed to load blog stats", "err", err)
+	}
+	slices.SortStableFunc(stats, func(a, b store.BlogStats) int { return cmp.Compare(a.MeanScore, b.MeanScore) })
+
+	days := int(math.Round(now.Sub(since).Hours() / 24))
+	fmt.Printf("Blogs over the last %d days (hit: total score >= %d)\n\n", days, cfg.Sources.HitScore)
+	fmt.Printf("%-32s %6s %8s %6s %6s %5s %6s %6s %-8s %s\n",
+		"DOMAIN", "ARTS", "ANALYZED", "MEAN", "MEDIAN", "HIT%", "ERR%", "/WEEK", "STATE", "VERDICT")
+	for _, st := range stats {
+		state := st.Source
+		switch {
+		case st.MutedAt != nil:
+			state = "muted"
+		case st.DemotedAt != nil:
+			state = "demoted"
+		}
+		verdict, reason := sources.Judge(cfg.Sources, st)
+		if reason != "" {
+			verdict += ": " + reason
+		}
+		fmt.Printf("%-32s %6d %8d %6.1f %6.1f %5.0f %6.0f %6.1f %-8s %s\n",
+			st.Domain, st.Articles, st.Analyzed, st.MeanScore, st.MedianScore,
+			st.HitRate*100, st.ErrorRate*100, st.PostsPerWeek, state, verdict)
+	}
+
+	if !apply {
+		return
+	}
+	if cfg.Sources.Action == "" {
+		fatal("no source action configured, set sources.action (SOURCES_ACTION) to demote or mute")
+	}
+	decisions, err := sources.Apply(context.Background(), db, cfg.Sources, false)
+	if err != nil {
+		fatal("failed to apply source policy", "err", err)
+	}
+	fmt.Println()
+	changed := 0
+	for _, d := range decisions {
+		if d.Action != "" {
+			fmt.Printf("%-8s %s (%s)\n", d.Action, d.Domain, d.Verdict)
+			changed++
+		}
+	}
+	fmt.Printf("%d blogs changed\n", changed)
+}
+
+func cmdTrainRanker(db *store.Store, cfg *config.Config, dryRun bool) {
+	examples, err := db.TrainingExamples()
+	if err != nil {
+		fatal("failed to load training examples", "err", err)
+	}
+	model, rep, err := ranker.Train(examples, cfg.Ranker.MinExamples)
+	if err != nil {
+		fatal("failed to train ranker", "err", err)
+	}
+
+	fmt.Printf("Trained on %d labeled articles (%d positive)\n", rep.Examples, rep.Positives)
+	if rep.Holdout > 0 {
+		fmt.Printf("Holdout (%d newest): accuracy %.3f, AUC %.3f (total score AUC %.3f)\n",
+			rep.Holdout, rep.Accuracy, rep.AUC, rep.BaselineAUC)
+	}
+	fmt.Printf("\nStrongest features:\n")
+	for _, w := range model.TopWeights(15) {
+		fmt.Printf("  %+8.3f  %s\n", w.Value, w.Feature)
+	}
+
+	if dryRun {
+		return
+	}
+	if err := ranker.Save(db, model); err != nil {
+		fatal("failed to save ranker model", "err", err)
+	}
+	if !cfg.Ranker.Enabled {
+		fmt.Printf("\nModel saved; set ranker.enabled (RANKER_ENABLED) to order digests with it.\n")
+	} else {
+		fmt.Printf("\nModel saved; digests are ordered by it from the next run.\n")
+	}
+}
+
+func cmdEval(db *store.Store, cfg *config.Config, args []string) {
+	var fixturesPath, out string
+	format := eval.FormatMarkdown
+	models := []string{cfg.Ollama.Model}
+	var promptDirs []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			fixturesPath = arg
+			continue
+		}
+		if i+1 >= len(args) {
+			fatal("missing value for eval option", "option", arg)
+		}
+		i++
+		switch arg {
+		case "--models":
+			models = strings.Split(args[i], ",")
+		case "--prompts":
+			promptDirs = strings.Split(args[i], ",")
+		case "--format":
+			format = args[i]
+		case "--out":
+			out = args[i]
+		default:
+			fatal("unknown eval option", "option", arg)
+		}
+	}
+	if fixturesPath == "" {
+		fatal("usage: newsbot eval <fixtures.jsonl> [--models a,b] [--prompts dir1,dir2] [--format markdown|html] [--out file]")
+	}
+	if format != eval.FormatMarkdown && format != eval.FormatHTML {
+		fatal("unknown report format", "format", format)
+	}
+
+	fixtures, err := eval.LoadFixtures(fixturesPath)
+	if err != nil {
+		fatal("failed to load fixtures", "err", err)
+	}
+
+	// Without --prompts the configured prompts are evaluated; each prompt
+	// directory may hold score.tmpl, summary.tmpl and trends.tmpl.
+	promptSets := map[string]config.PromptsConfig{"": cfg.Prompts}
+	promptNames := []string{""}
+	if len(promptDirs) > 0 {
+		promptSets, promptNames = map[string]config.PromptsConfig{}, nil
+		for _, dir := range promptDirs {
+			pc := cfg.Prompts
+			pc.Version = ""
+			for name, field := range map[string]*string{"score.tmpl": &pc.Score, "summary.tmpl": &pc.Summary, "trends.tmpl": &pc.Trends} {
+				path := filepath.Join(dir, name)
+				if _, err := os.Stat(path); err == nil {
+					*field = path
+				}
+			}
+			name := filepath.Base(filepath.Clean(dir))
+			promptSets[name] = pc
+			promptNames = append(promptNames, name)
+		}
+	}
+
+	var variants []eval.Variant
+	for _, model := range models {
+		model = strings.TrimSpace(model)
+		for _, pname := range promptNames {
+			prompts, err := ai.LoadPrompts(promptSets[pname], interest.New(cfg.Interests))
+			if err != nil {
+				fatal("failed to load prompts", "prompts", pname, "err", err)
+			}
+			name := model
+			if pname != "" {
+				name += " · " + pname
+			}
+			// Responses are never cached so that latency is measured, but
+			// token usage is recorded as for any other call.
+			client := ai.NewClient(cfg.Ollama.Address, model, cfg.Ollama.Username, cfg.Ollama.Password).
+				WithPrompts(prompts).
+				WithUsage(db, cfg.Usage.DailyTokenBudget)
+			variants = append(variants, eval.Variant{Name: name, Client: client})
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	ctx = logging.WithRunID(ctx, logging.NewRunID())
+
+	report, err := eval.Run(ctx, fixtures, variants)
+	if err != nil {
+		fatal("evaluation failed", "err", err)
+	}
+
+	w := os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			fatal("failed to create report", "err", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := report.Write(w, format); err != nil {
+		fatal("failed to write report", "err", err)
+	}
+	if out != "" {
+		slog.Info("evaluation report written", "path", out, "variants", len(variants), "fixtures", len(fixtures))
 	}
 }